@@ -0,0 +1,8 @@
+// Package queries embeds the tree-sitter queries of the kamailio_cfg grammar, which Neovim also reads from
+// this directory, so that the server highlights a configuration the same way the editor does.
+package queries
+
+import _ "embed"
+
+//go:embed highlights.scm
+var Highlights string
@@ -2,17 +2,33 @@ package document_manager
 
 import (
 	"KamaiZen/settings"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
 	"iter"
 	"maps"
 	"os"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 )
 
-var moduleDocumentationMapInstance = &moduleDocumentationMap{
-	ModuleDocs: make(map[string]ModuleDocs),
+// ModuleIndex holds the documentation of the Kamailio modules indexed for a session,
+// so that sessions configured with different Kamailio sources do not share it.
+type ModuleIndex struct {
+	modules  moduleDocumentationMap
+	indexing sync.Mutex // serialises the runs of Initialise
+}
+
+// NewModuleIndex creates and returns an empty module documentation index.
+//
+// return: The new index, filled once Initialise completes.
+func NewModuleIndex() *ModuleIndex {
+	return &ModuleIndex{
+		modules: moduleDocumentationMap{ModuleDocs: make(map[string]ModuleDocs)},
+	}
 }
 
 const (
@@ -151,13 +167,30 @@ func extractFunctionDoc(lines []string) []FunctionDocumentation {
 	return functionDocs
 }
 
-// Initializes the document manager by reading the README files from the specified
-// Kamailio source path and extracting function documentation from them. It then adds the
-// extracted documentation to the module documentation map.
+// IndexProgress is called by Initialise once a module has been scanned.
+//
+// scanned: The number of modules scanned so far.
+// total: The number of modules to scan.
+type IndexProgress func(scanned int, total int)
+
+// IndexResult summarises a run of Initialise.
+type IndexResult struct {
+	Modules    int      // the number of modules scanned
+	Functions  int      // the number of functions documented
+	Unreadable []string // the modules whose README could not be read
+}
+
+// Initializes the index by reading the README files from the specified
+// Kamailio source path and extracting function documentation from them. It then replaces the
+// module documentation map with the extracted documentation, so it can be run again when the
+// source path changes. Requests are served with the previous documentation until it completes,
+// and the documentation is dropped if the indexing is cancelled in the meantime.
 //
 // The function expects the settings to provide a valid Kamailio source path.
 //
+// ctx: Cancels the indexing, the documentation in use is then kept.
 // s: An instance of settings.LSPSettings containing the configuration settings.
+// progress: Called once each module is scanned, may be nil.
 //
 // The function performs the following steps:
 // 1. Reads the directory specified by the Kamailio source path.
@@ -166,38 +199,91 @@ func extractFunctionDoc(lines []string) []FunctionDocumentation {
 // 4. Extracts function documentation from the README file.
 // 5. Adds the extracted function documentation to the function documentation map.
 // 6. Adds the function documentation map to the module documentation map.
+// 7. Replaces the documentation of all modules, unless the indexing was cancelled.
 //
-// return: An error if there was an issue reading the directory or file.
-func Initialise(s settings.LSPSettings) error {
+// return: A summary of the indexing, and an error if the source path is not set, the modules
+// directory cannot be read or holds no module, or the error of the context.
+func (i *ModuleIndex) Initialise(ctx context.Context, s settings.LSPSettings, progress IndexProgress) (IndexResult, error) {
+	i.indexing.Lock()
+	defer i.indexing.Unlock()
+	var result IndexResult
+	if s.KamailioSourcePath == "" {
+		return result, errors.New("kamailioSourcePath is not set")
+	}
+	modules := &moduleDocumentationMap{ModuleDocs: make(map[string]ModuleDocs)}
 	path := s.KamailioSourcePath + _MODULES_PATH
-	listOfModules, err := os.ReadDir(path)
+	entries, err := os.ReadDir(path)
 	if err != nil {
-		return err
+		return result, fmt.Errorf("cannot read the Kamailio modules: %w", err)
+	}
+	var listOfModules []os.DirEntry
+	for _, entry := range entries {
+		if entry.IsDir() {
+			listOfModules = append(listOfModules, entry)
+		}
+	}
+	if len(listOfModules) == 0 {
+		return result, fmt.Errorf("no Kamailio modules found in %s", path)
 	}
 	// Get All Modules
-	for _, module := range listOfModules {
-		readme, err := os.ReadFile(path + "/" + module.Name() + _READEME_FILE)
+	for n, module := range listOfModules {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		functions, err := modules.addModule(path, module.Name())
 		if err != nil {
-			log.Error().Err(err)
-			continue
+			log.Warn().Err(err).Str("module", module.Name()).Msg("Cannot read the module README")
+			result.Unreadable = append(result.Unreadable, module.Name())
 		}
-		functionDocs := extractFunctionDoc(strings.Split(string(readme), "\n"))
-		functionDocsMap := FunctionDocumentationMap{Functions: make(map[string]FunctionDocumentation)}
-		for _, functionDoc := range functionDocs {
-			// we are overwriting the function documentation if it already exists
-			err = functionDocsMap.AddFunctionDoc(functionDoc, true)
-			if err != nil {
-				log.Error().Str("function", functionDoc.Name).Msg("Error Adding function documentation...skipping")
-			}
+		result.Functions += functions
+		if progress != nil {
+			progress(n+1, len(listOfModules))
 		}
-		moduleDocs := newModuleDocs()
-		err = moduleDocs.AddFunctionDoc(module.Name(), functionDocsMap, true)
+	}
+	result.Modules = len(listOfModules)
+	if err := ctx.Err(); err != nil {
+		log.Info().Str("path", s.KamailioSourcePath).Msg("Indexing cancelled, dropping its documentation")
+		return result, err
+	}
+	i.modules.replace(modules.ModuleDocs)
+	return result, nil
+}
+
+// Clears the documentation of all modules, once the Kamailio source path is unset.
+// It waits for a running indexing to return, so cancel it first.
+func (i *ModuleIndex) Clear() {
+	i.indexing.Lock()
+	defer i.indexing.Unlock()
+	i.modules.replace(make(map[string]ModuleDocs))
+}
+
+// addModule extracts the function documentation from the README of a module and adds it to the map.
+//
+// path: The path of the modules directory.
+// name: The name of the module.
+//
+// return: The number of functions documented, and an error if the README cannot be read.
+func (m *moduleDocumentationMap) addModule(path string, name string) (int, error) {
+	readme, err := os.ReadFile(path + "/" + name + _READEME_FILE)
+	if err != nil {
+		return 0, err
+	}
+	functionDocs := extractFunctionDoc(strings.Split(string(readme), "\n"))
+	functionDocsMap := FunctionDocumentationMap{Functions: make(map[string]FunctionDocumentation)}
+	for _, functionDoc := range functionDocs {
+		// we are overwriting the function documentation if it already exists
+		err = functionDocsMap.AddFunctionDoc(functionDoc, true)
 		if err != nil {
-			log.Error().Str("module", module.Name()).Msg("Error Adding function documentation...skipping")
+			log.Error().Str("function", functionDoc.Name).Msg("Error Adding function documentation...skipping")
 		}
-		moduleDocumentationMapInstance.AddModuleDocs(module.Name(), moduleDocs, true)
 	}
-	return nil
+	moduleDocs := newModuleDocs()
+	err = moduleDocs.AddFunctionDoc(name, functionDocsMap, true)
+	if err != nil {
+		log.Error().Str("module", name).Msg("Error Adding function documentation...skipping")
+	}
+	m.AddModuleDocs(name, moduleDocs, true)
+	return len(functionDocsMap.Functions), nil
 }
 
 // Retrieves the documentation for a specific function within a specified module.
@@ -209,8 +295,8 @@ func Initialise(s settings.LSPSettings) error {
 // return: A string containing the documentation for the specified function. If the module is not found,
 //
 //	it returns "Module not found".
-func GetFunctionDoc(moduleName string, functionName string) string {
-	moduleDocs, exists := moduleDocumentationMapInstance.GetModuleDocs(moduleName)
+func (i *ModuleIndex) GetFunctionDoc(moduleName string, functionName string) string {
+	moduleDocs, exists := i.modules.GetModuleDocs(moduleName)
 	if !exists {
 		return "Module not found"
 	}
@@ -225,8 +311,10 @@ func GetFunctionDoc(moduleName string, functionName string) string {
 // return: A string containing the documentation for the specified function, including the module name.
 //
 //	If the function is not found in any module, it returns "Function not found".
-func FindFunctionInAllModules(functionName string) string {
-	for moduleName, moduleDocs := range moduleDocumentationMapInstance.ModuleDocs {
+func (i *ModuleIndex) FindFunctionInAllModules(functionName string) string {
+	i.modules.mu.RLock()
+	defer i.modules.mu.RUnlock()
+	for moduleName, moduleDocs := range i.modules.ModuleDocs {
 		if _, exists := moduleDocs.Functions[moduleName].Functions[functionName]; exists {
 			return "# Module: " + moduleName + "\n\n" + moduleDocs.GetFunctionDocAsString(moduleName, functionName)
 		}
@@ -238,8 +326,11 @@ func FindFunctionInAllModules(functionName string) string {
 // from the module documentation map.
 //
 // return: A slice of strings containing the names of all available modules.
-func GetAllAvailableModules() iter.Seq[string] {
-	return maps.Keys(moduleDocumentationMapInstance.ModuleDocs)
+func (i *ModuleIndex) GetAllAvailableModules() iter.Seq[string] {
+	i.modules.mu.RLock()
+	defer i.modules.mu.RUnlock()
+	// collect the keys while holding the lock, the iterator is consumed after returning
+	return slices.Values(slices.Collect(maps.Keys(i.modules.ModuleDocs)))
 }
 
 // GetAllFunctionsInModule retrieves all function documentation for a specific module.
@@ -248,8 +339,8 @@ func GetAllAvailableModules() iter.Seq[string] {
 // return: A FunctionDocumentationMap containing the documentation for all functions
 //
 //	in the specified module. If the module is not found, it returns an empty FunctionDocumentationMap.
-func GetAllFunctionsInModule(moduleName string) FunctionDocumentationMap {
-	moduleDocs, exists := moduleDocumentationMapInstance.GetModuleDocs(moduleName)
+func (i *ModuleIndex) GetAllFunctionsInModule(moduleName string) FunctionDocumentationMap {
+	moduleDocs, exists := i.modules.GetModuleDocs(moduleName)
 	if !exists {
 		return FunctionDocumentationMap{}
 	}
@@ -262,9 +353,11 @@ func GetAllFunctionsInModule(moduleName string) FunctionDocumentationMap {
 // return: A slice of FunctionDocumentation structs containing the documentation
 //
 //	for all functions across all modules.
-func GetAllAvailableFunctionDocs() []FunctionDocumentation {
+func (i *ModuleIndex) GetAllAvailableFunctionDocs() []FunctionDocumentation {
+	i.modules.mu.RLock()
+	defer i.modules.mu.RUnlock()
 	var functionDocs []FunctionDocumentation
-	for _, moduleDocs := range moduleDocumentationMapInstance.ModuleDocs {
+	for _, moduleDocs := range i.modules.ModuleDocs {
 		for _, functionDoc := range moduleDocs.Functions {
 			for _, doc := range functionDoc.Functions {
 				functionDocs = append(functionDocs, doc)
@@ -273,3 +366,17 @@ func GetAllAvailableFunctionDocs() []FunctionDocumentation {
 	}
 	return functionDocs
 }
+
+// IndexSize returns the size of the documentation index.
+//
+// return: The number of indexed modules, of their functions, and of core cookbook items.
+func (i *ModuleIndex) IndexSize() (modules int, functions int, cookbook int) {
+	i.modules.mu.RLock()
+	defer i.modules.mu.RUnlock()
+	for _, moduleDocs := range i.modules.ModuleDocs {
+		for _, functionDoc := range moduleDocs.Functions {
+			functions += len(functionDoc.Functions)
+		}
+	}
+	return len(i.modules.ModuleDocs), functions, len(CookBookDocs)
+}
@@ -2,11 +2,14 @@ package document_manager
 
 import (
 	"errors"
+	"sync"
 )
 
 // Holds the documentation for all modules.
 // It maps module names to their corresponding ModuleDocs structs.
+// The map is read by concurrent requests while it is being indexed, so every access goes through mu.
 type moduleDocumentationMap struct {
+	mu         sync.RWMutex
 	ModuleDocs map[string]ModuleDocs
 }
 
@@ -17,6 +20,8 @@ type moduleDocumentationMap struct {
 //
 //	whether the module was found. If the module is not found, it returns an empty ModuleDocs struct and false.
 func (m *moduleDocumentationMap) GetModuleDocs(moduleName string) (ModuleDocs, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
 	for key, value := range m.ModuleDocs {
 		if key == moduleName {
 			return value, true
@@ -34,6 +39,8 @@ func (m *moduleDocumentationMap) GetModuleDocs(moduleName string) (ModuleDocs, b
 // overwrite: A boolean indicating whether to overwrite existing documentation if it exists.
 // return: An error if the module documentation already exists and overwrite is false.
 func (m *moduleDocumentationMap) AddModuleDocs(moduleName string, moduleDocs ModuleDocs, overwrite bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	if _, exists := m.ModuleDocs[moduleName]; exists && !overwrite {
 		return errors.New("Module already exists")
 	}
@@ -81,3 +88,12 @@ func (m *ModuleDocs) GetFunctionDocAsString(moduleName string, functionName stri
 func newModuleDocs() ModuleDocs {
 	return ModuleDocs{Functions: make(map[string]FunctionDocumentationMap)}
 }
+
+// replace replaces the documentation of all modules with the given documentation.
+//
+// moduleDocs: The documentation of the modules, mapped by module name.
+func (m *moduleDocumentationMap) replace(moduleDocs map[string]ModuleDocs) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ModuleDocs = moduleDocs
+}
@@ -1,5 +1,7 @@
 package kamailio_cfg
 
+import "context"
+
 // Analyzer is a struct that holds the components necessary for analyzing Kamailio configurations.
 // It contains a builder for constructing the AST and a reference to the root AST node.
 type Analyzer struct {
@@ -25,6 +27,32 @@ func (a *Analyzer) Build(content []byte) {
 	a.ast = a.builder.BuildAST(content)
 }
 
+// BuildCtx constructs the AST like Build, but aborts as soon as the context is cancelled.
+// A cancelled build keeps the previous AST, a failed one leaves no AST, as Build does.
+//
+// Parameters:
+//
+//	ctx context.Context - The context bounding the build.
+//	content []byte - The content to be parsed into an AST.
+//
+// Returns:
+//
+//	error - The error that made the build fail, if any.
+func (a *Analyzer) BuildCtx(ctx context.Context, content []byte) error {
+	ast, err := a.builder.BuildASTCtx(ctx, content)
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+	a.ast = ast
+	return err
+}
+
+// Close frees the parser and the parse tree of the analyzer, which holds no AST afterwards.
+func (a *Analyzer) Close() {
+	a.ast = nil
+	a.builder.parser.Close()
+}
+
 // GetAST returns the root AST (Abstract Syntax Tree) node that was built by the analyzer.
 //
 // Returns:
@@ -1,6 +1,8 @@
 package kamailio_cfg
 
 import (
+	"context"
+
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
@@ -96,3 +98,24 @@ func (k *KamailioASTBuilder) BuildAST(sourceCode []byte) *ASTNode {
 		Node: n,
 	}
 }
+
+// BuildASTCtx parses the given source code like BuildAST, but aborts as soon as the context is cancelled.
+//
+// Parameters:
+//
+//	ctx context.Context - The context bounding the parse.
+//	sourceCode []byte - The source code to be parsed into an AST.
+//
+// Returns:
+//
+//	*ASTNode - The root node of the constructed AST, or nil if parsing fails.
+//	error - The error that made the parse fail, if any.
+func (k *KamailioASTBuilder) BuildASTCtx(ctx context.Context, sourceCode []byte) (*ASTNode, error) {
+	n, err := k.parser.ParseCtx(ctx, sourceCode)
+	if n == nil {
+		return nil, err
+	}
+	return &ASTNode{
+		Node: n,
+	}, nil
+}
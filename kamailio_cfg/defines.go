@@ -0,0 +1,78 @@
+package kamailio_cfg
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const _DEFINE_QUERY = `[
+    (preproc_def name: (identifier) @name)
+    (preproc_trydef name: (identifier) @name)
+    (preproc_redef name: (identifier) @name)
+    ]`
+
+// Define is a #!define, #!trydef or #!redefine directive of a document.
+type Define struct {
+	Name       string // The name of the define.
+	Value      string // The value of the define, empty if it has none.
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+}
+
+// ExtractDefines collects the #!define, #!trydef and #!redefine directives of the document parsed by the analyzer.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	[]Define - The directives, in document order, with the range of their name.
+func ExtractDefines(a *Analyzer, source_code []byte) []Define {
+	var defines []Define
+	if a.ast == nil {
+		return defines
+	}
+	q, err := NewQueryExecutor(_DEFINE_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return defines
+	}
+	for {
+		match, ok := q.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range match.Captures {
+			node := capture.Node
+			define := Define{
+				Name:       node.Content(source_code),
+				StartPoint: node.StartPoint(),
+				EndPoint:   node.EndPoint(),
+			}
+			if node.Parent() != nil {
+				if value := node.Parent().ChildByFieldName("value"); value != nil {
+					define.Value = strings.TrimSpace(value.Content(source_code))
+				}
+			}
+			defines = append(defines, define)
+		}
+	}
+	return defines
+}
+
+// GetDocs returns the documentation of the define.
+//
+// Returns:
+//
+//	string - The documentation in markdown.
+func (d Define) GetDocs() string {
+	value := d.Value
+	if value == "" {
+		value = "Defined without a value"
+	}
+	return "## Define\n\n\t" + d.Name + "\n\n### Value\n\n\t" + value + "\n"
+}
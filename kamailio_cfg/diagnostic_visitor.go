@@ -2,25 +2,35 @@ package kamailio_cfg
 
 import (
 	"KamaiZen/lsp"
-	"KamaiZen/settings"
 
 	"github.com/rs/zerolog/log"
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
 // DiagnosticVisitor is a struct that collects diagnostics during the visit of a Kamailio configuration.
-// It holds a slice of lsp.Diagnostic which contains the diagnostics found.
+// It holds a slice of lsp.Diagnostic which contains the diagnostics found,
+// and the line index used to convert the node points into diagnostic ranges.
 type DiagnosticVisitor struct {
-	diagnostics []lsp.Diagnostic
+	diagnostics            []lsp.Diagnostic
+	index                  *lsp.LineIndex
+	deprecatedCommentHints bool
 }
 
 // NewDiagnosticVisitor creates and returns a new instance of DiagnosticVisitor.
 //
+// Parameters:
+//
+//	index *lsp.LineIndex - The line index of the visited document.
+//	deprecatedCommentHints bool - Whether the deprecated comments are hinted.
+//
 // Returns:
 //
 //	*DiagnosticVisitor - A new instance of DiagnosticVisitor.
-func NewDiagnosticVisitor() *DiagnosticVisitor {
-	return &DiagnosticVisitor{}
+func NewDiagnosticVisitor(index *lsp.LineIndex, deprecatedCommentHints bool) *DiagnosticVisitor {
+	return &DiagnosticVisitor{
+		index:                  index,
+		deprecatedCommentHints: deprecatedCommentHints,
+	}
 }
 
 // createDiagnostic creates a new diagnostic message with the given parameters.
@@ -36,22 +46,13 @@ func NewDiagnosticVisitor() *DiagnosticVisitor {
 // Returns:
 //
 //	lsp.Diagnostic - The constructed diagnostic message.
-func createDiagnostic(
+func (d *DiagnosticVisitor) createDiagnostic(
 	message string,
 	start sitter.Point,
 	end sitter.Point,
 	severity lsp.DiagnosticSeverity) lsp.Diagnostic {
 	return lsp.Diagnostic{
-		Range: lsp.Range{
-			Start: lsp.Position{
-				Line:      int(start.Row),
-				Character: int(start.Column),
-			},
-			End: lsp.Position{
-				Line:      int(end.Row),
-				Character: int(end.Column),
-			},
-		},
+		Range:    d.index.Range(start, end),
 		Message:  message,
 		Severity: severity,
 	}
@@ -138,7 +139,7 @@ func (d *DiagnosticVisitor) addSyntaxErrors(node *ASTNode, a *Analyzer) {
 				continue
 			}
 			diagnostics = append(diagnostics,
-				createDiagnostic("Syntax error", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+				d.createDiagnostic("Syntax error", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 		}
 	}
 	d.diagnostics = append(d.diagnostics, diagnostics...)
@@ -166,7 +167,7 @@ func (d *DiagnosticVisitor) addDeprecatedCommentHints(node *ASTNode, a *Analyzer
 		for _, capture := range match.Captures {
 			node := capture.Node
 			diagnostics = append(diagnostics,
-				createDiagnostic("use /* comment */", node.StartPoint(), node.EndPoint(), lsp.HINT))
+				d.createDiagnostic("use /* comment */", node.StartPoint(), node.EndPoint(), lsp.HINT))
 		}
 	}
 	d.diagnostics = append(d.diagnostics, diagnostics...)
@@ -196,6 +197,9 @@ func (d *DiagnosticVisitor) addUnreachableCodeWarnings(node *ASTNode, a *Analyze
 		for _, capture := range match.Captures {
 			node := capture.Node
 			s := node.Parent()
+			if s == nil || s.Parent() == nil {
+				continue
+			}
 			if s.Type() == StatementNodeType && s.NextNamedSibling() != nil && s.NextNamedSibling().Type() == StatementNodeType {
 				// the next named siblings (statements) are unreachable
 				sibling_count := s.Parent().NamedChildCount()
@@ -204,7 +208,8 @@ func (d *DiagnosticVisitor) addUnreachableCodeWarnings(node *ASTNode, a *Analyze
 					continue
 				}
 				start_node := s.NextNamedSibling()
-				if start_node.Type() == BlockEndNodeType || start_node.NamedChild(0).Type() == CaseStatementNodeType {
+				if start_node.Type() == BlockEndNodeType ||
+					(start_node.NamedChild(0) != nil && start_node.NamedChild(0).Type() == CaseStatementNodeType) {
 					// not unreachable
 					continue
 				}
@@ -212,8 +217,11 @@ func (d *DiagnosticVisitor) addUnreachableCodeWarnings(node *ASTNode, a *Analyze
 				if end_node.Type() == BlockEndNodeType {
 					end_node = end_node.PrevNamedSibling()
 				}
+				if end_node == nil {
+					continue
+				}
 				diagnostics = append(diagnostics,
-					createDiagnostic("Unreachable code", start_node.StartPoint(), end_node.EndPoint(), lsp.WARNING))
+					d.createDiagnostic("Unreachable code", start_node.StartPoint(), end_node.EndPoint(), lsp.WARNING))
 			}
 		}
 	}
@@ -243,7 +251,7 @@ func (d *DiagnosticVisitor) addInvalidExpressionErrors(node *ASTNode, a *Analyze
 		for _, capture := range match.Captures {
 			node := capture.Node
 			// check if the statement has an expression
-			if node.Parent().Type() != StatementNodeType {
+			if node.Parent() == nil || node.Parent().Type() != StatementNodeType {
 				continue
 			}
 
@@ -252,7 +260,7 @@ func (d *DiagnosticVisitor) addInvalidExpressionErrors(node *ASTNode, a *Analyze
 				continue
 			}
 			// check if the expression has only one child
-			if node.ChildCount() > 1 {
+			if node.ChildCount() > 1 || node.Child(0) == nil {
 				continue
 			}
 
@@ -278,7 +286,7 @@ func (d *DiagnosticVisitor) addInvalidExpressionErrors(node *ASTNode, a *Analyze
 			// Invalid single expression statement
 			log.Debug().Str("node-type", node.Type()).Msg("invalid single expression statement found")
 			diagnostics = append(diagnostics,
-				createDiagnostic("Invalid statement", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+				d.createDiagnostic("Invalid statement", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 
 		}
 	}
@@ -310,31 +318,34 @@ func (d *DiagnosticVisitor) addInvalidAssignmentExpressionErrors(node *ASTNode,
 			node := capture.Node
 			// capture is statement -> expression -> assignment_expression
 			n := node.NamedChild(0)
-			n = n.NamedChild(0)
+			if n != nil {
+				n = n.NamedChild(0)
+			}
 			if n == nil {
 				diagnostics = append(diagnostics,
-					createDiagnostic("Invalid assignment expression", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+					d.createDiagnostic("Invalid assignment expression", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 				continue
 			}
 
 			if n.NamedChildCount() != 2 {
 				diagnostics = append(diagnostics,
-					createDiagnostic("Invalid assignment expression, left and right side are not valid", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+					d.createDiagnostic("Invalid assignment expression, left and right side are not valid", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 				continue
 			}
 
 			left := n.ChildByFieldName("left")
-			if left.Type() != PseudoVariableNodeType && left.Type() != PseudoVariableExpressionNodeType {
-				log.Debug().Str("left-hand-side", left.Type()).Msg("Invalid assignment")
+			if left == nil || left.Type() != PseudoVariableNodeType && left.Type() != PseudoVariableExpressionNodeType {
+				log.Debug().Str("assignment", n.Type()).Msg("Invalid assignment: left-hand-side")
 				diagnostics = append(diagnostics,
-					createDiagnostic("Invalid assignment: left-hand-side ", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+					d.createDiagnostic("Invalid assignment: left-hand-side ", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 				continue
 			}
 
 			right := n.ChildByFieldName("right")
-			if right.Type() != ExpressionNodeType || right.NamedChild(0).Type() == IdentifierNodeType {
+			if right == nil || right.Type() != ExpressionNodeType ||
+				right.NamedChild(0) == nil || right.NamedChild(0).Type() == IdentifierNodeType {
 				diagnostics = append(diagnostics,
-					createDiagnostic("Invalid value on the right side of expression", node.StartPoint(), node.EndPoint(), lsp.ERROR))
+					d.createDiagnostic("Invalid value on the right side of expression", node.StartPoint(), node.EndPoint(), lsp.ERROR))
 				continue
 			}
 		}
@@ -358,7 +369,7 @@ func (d *DiagnosticVisitor) GetQueryDiagnostics(node *ASTNode, a *Analyzer) {
 	d.addUnreachableCodeWarnings(node, a)
 	// FIXME: fix false positives
 	d.addSyntaxErrors(node, a)
-	if settings.GlobalSettings.DeprecatedCommentHints {
+	if d.deprecatedCommentHints {
 		d.addDeprecatedCommentHints(node, a)
 	}
 }
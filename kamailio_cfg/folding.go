@@ -0,0 +1,160 @@
+package kamailio_cfg
+
+import (
+	"slices"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const (
+	TopLevelItemNodeType     = "top_level_item"
+	CommentNodeType          = "comment"
+	MultilineCommentNodeType = "multiline_comment"
+	SwitchStatementNodeType  = "switch_statement"
+	PreprocIfdefNodeType     = "preproc_ifdef"
+	PreprocIfndefNodeType    = "preproc_ifndef"
+	PreprocElseNodeType      = "preproc_else"
+)
+
+// FoldKind is the kind of a foldable region of a document.
+type FoldKind int
+
+const (
+	CodeFold    FoldKind = iota // a routing block, a compound statement or a case
+	CommentFold                 // consecutive comment lines or a multiline comment
+	ImportsFold                 // consecutive loadmodule and modparam statements
+	RegionFold                  // a #!ifdef, #!ifndef or #!else region
+)
+
+// Fold is a foldable region of a document, spanning whole lines.
+type Fold struct {
+	Kind     FoldKind
+	StartRow uint32 // The first line of the region, which stays visible once folded.
+	EndRow   uint32 // The last line of the region.
+}
+
+// Folds returns the foldable regions of the document parsed by the analyzer.
+// The closing brace of a block and the #!else or #!endif ending a region stay visible once folded.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//
+// Returns:
+//
+//	[]Fold - The foldable regions, sorted by first line, at most one per first line.
+func Folds(a *Analyzer) []Fold {
+	var folds []Fold
+	if a.ast == nil {
+		return folds
+	}
+	add := func(kind FoldKind, start uint32, end uint32) {
+		if end > start {
+			folds = append(folds, Fold{Kind: kind, StartRow: start, EndRow: end})
+		}
+	}
+	walk(a.ast.Node, func(node *sitter.Node) {
+		switch node.Type() {
+		case RoutingBlockNodeType:
+			if body := node.ChildByFieldName("body"); body != nil {
+				add(CodeFold, node.StartPoint().Row, rowBefore(body.EndPoint().Row))
+			}
+		case CompoundStatementNodeType:
+			if parent := node.Parent(); parent == nil || parent.Type() != RoutingBlockNodeType {
+				add(CodeFold, node.StartPoint().Row, rowBefore(node.EndPoint().Row))
+			}
+		case CaseStatementNodeType:
+			add(CodeFold, node.StartPoint().Row, lastRow(node))
+		case PreprocIfdefNodeType, PreprocIfndefNodeType:
+			end := rowBefore(lastRow(node))
+			if alternative := node.ChildByFieldName("alternative"); alternative != nil {
+				end = rowBefore(alternative.StartPoint().Row)
+			}
+			add(RegionFold, node.StartPoint().Row, end)
+		case PreprocElseNodeType:
+			// the #!endif closing the region ends the enclosing #!ifdef
+			if parent := node.Parent(); parent != nil {
+				add(RegionFold, node.StartPoint().Row, rowBefore(lastRow(parent)))
+			}
+		case MultilineCommentNodeType:
+			add(CommentFold, node.StartPoint().Row, lastRow(node))
+		}
+		foldRuns(node, add)
+	})
+	slices.SortStableFunc(folds, func(a, b Fold) int {
+		if a.StartRow != b.StartRow {
+			return int(a.StartRow) - int(b.StartRow)
+		}
+		return int(b.EndRow) - int(a.EndRow)
+	})
+	return slices.CompactFunc(folds, func(a, b Fold) bool {
+		return a.StartRow == b.StartRow
+	})
+}
+
+// foldRuns adds the folds of the runs of consecutive comment lines, and of the runs of
+// loadmodule and modparam statements, among the children of the node.
+// A run of statements may contain comments, but starts and ends with a statement.
+func foldRuns(node *sitter.Node, add func(kind FoldKind, start uint32, end uint32)) {
+	var comments, imports []*sitter.Node
+	flushComments := func() {
+		if len(comments) > 0 {
+			add(CommentFold, comments[0].StartPoint().Row, lastRow(comments[len(comments)-1]))
+		}
+		comments = nil
+	}
+	flushImports := func() {
+		if len(imports) > 0 {
+			add(ImportsFold, imports[0].StartPoint().Row, lastRow(imports[len(imports)-1]))
+		}
+		imports = nil
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		child := node.NamedChild(i)
+		if child.Type() == TopLevelItemNodeType && child.NamedChildCount() == 1 {
+			child = child.NamedChild(0)
+		}
+		switch child.Type() {
+		case CommentNodeType:
+			if len(comments) > 0 && child.StartPoint().Row != lastRow(comments[len(comments)-1])+1 {
+				flushComments()
+			}
+			comments = append(comments, child)
+			continue
+		case LoadModuleNodeType, ModparamNodeType:
+			imports = append(imports, child)
+		case MultilineCommentNodeType:
+		default:
+			flushImports()
+		}
+		flushComments()
+	}
+	flushComments()
+	flushImports()
+}
+
+// walk calls f for the node and each of its named descendants, parents first.
+func walk(node *sitter.Node, f func(node *sitter.Node)) {
+	f(node)
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		walk(node.NamedChild(i), f)
+	}
+}
+
+// rowBefore returns the line before the given one, the first line has none and is returned as is,
+// so that a region ending before the first line is not folded.
+func rowBefore(row uint32) uint32 {
+	if row == 0 {
+		return 0
+	}
+	return row - 1
+}
+
+// lastRow returns the last line of a node, a node ending with a line break ends on the line before.
+func lastRow(node *sitter.Node) uint32 {
+	end := node.EndPoint()
+	if end.Column == 0 && end.Row > node.StartPoint().Row {
+		return end.Row - 1
+	}
+	return end.Row
+}
@@ -0,0 +1,72 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"testing"
+)
+
+const foldingSource = `# one
+# two
+/* multi
+line */
+#!ifdef WITH_A
+loadmodule "a.so"
+# tm
+loadmodule "tm.so"
+modparam("tm", "fr_timer", 30)
+#!else
+loadmodule "b.so"
+#!endif
+request_route {
+  switch ($rm) {
+    case "INVITE":
+      xlog("i");
+      break;
+    default:
+      break;
+  }
+  if (1) {
+    xlog("a");
+  }
+}
+`
+
+func TestFolds(t *testing.T) {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(foldingSource))
+	folds := kamailio_cfg.Folds(analyzer)
+	expected := []kamailio_cfg.Fold{
+		{Kind: kamailio_cfg.CommentFold, StartRow: 0, EndRow: 1},
+		{Kind: kamailio_cfg.CommentFold, StartRow: 2, EndRow: 3},
+		{Kind: kamailio_cfg.RegionFold, StartRow: 4, EndRow: 8},
+		{Kind: kamailio_cfg.ImportsFold, StartRow: 5, EndRow: 8},
+		{Kind: kamailio_cfg.RegionFold, StartRow: 9, EndRow: 10},
+		{Kind: kamailio_cfg.CodeFold, StartRow: 12, EndRow: 22},
+		{Kind: kamailio_cfg.CodeFold, StartRow: 13, EndRow: 18},
+		{Kind: kamailio_cfg.CodeFold, StartRow: 14, EndRow: 16},
+		{Kind: kamailio_cfg.CodeFold, StartRow: 17, EndRow: 18},
+		{Kind: kamailio_cfg.CodeFold, StartRow: 20, EndRow: 21},
+	}
+	if len(folds) != len(expected) {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, folds)
+	}
+	for i, e := range expected {
+		if folds[i] != e {
+			t.Fatalf("Expected: %+v,\ngot: %+v", e, folds[i])
+		}
+	}
+}
+
+func TestFoldsSingleLineBlocks(t *testing.T) {
+	for _, source := range []string{
+		"route[X] { return; }\n",
+		"request_route { if (1) { exit; } }\n",
+		"#!ifdef A\n#!endif\n",
+	} {
+		analyzer := kamailio_cfg.NewAnalyzer()
+		analyzer.Build([]byte(source))
+		if folds := kamailio_cfg.Folds(analyzer); len(folds) != 0 {
+			t.Fatalf("Expected: no folds for %q,\ngot: %+v", source, folds)
+		}
+	}
+}
@@ -12,10 +12,13 @@ type ASTVisitor interface {
 
 type FormattingVisitor struct {
 	edits []lsp.TextEdit
+	index *lsp.LineIndex
 }
 
-func NewFormattingVisitor() *FormattingVisitor {
-	return &FormattingVisitor{}
+func NewFormattingVisitor(index *lsp.LineIndex) *FormattingVisitor {
+	return &FormattingVisitor{
+		index: index,
+	}
 }
 
 func (v *FormattingVisitor) GetEdits() []lsp.TextEdit {
@@ -47,7 +50,7 @@ func (v *FormattingVisitor) Visit(node *ASTNode, a *Analyzer) error {
 		formattedContent.WriteString(" = ") // Ensure exactly one space on both sides
 		formattedContent.WriteString(rightNodeContent)
 		log.Info().Str("Formatted content", formattedContent.String())
-		edit := lsp.NewTextEdit(leftNode, rightNode, formattedContent.String())
+		edit := lsp.NewTextEdit(v.index, leftNode, rightNode, formattedContent.String())
 		edits = append(edits, edit)
 		// update the tree as well to reflect the changes
 		// kamailio_cfg.UpdateTree(parser.GetTree(), leftNode, rightNode, formattedContent.String())
@@ -65,7 +68,7 @@ func (v *FormattingVisitor) Visit(node *ASTNode, a *Analyzer) error {
 		formattedContent.WriteString("(")
 		formattedContent.WriteString(string(expression.Content(nil)))
 		formattedContent.WriteString(") ")
-		edit := lsp.NewTextEdit(leftBrace, rightBrace, formattedContent.String())
+		edit := lsp.NewTextEdit(v.index, leftBrace, rightBrace, formattedContent.String())
 		edits = append(edits, edit)
 		// kamailio_cfg.UpdateTree(parser.GetTree(), leftBrace, rightBrace, formattedContent.String())
 		// parser.UpdateTree([]byte(formattedContent.String()))
@@ -76,18 +79,18 @@ func (v *FormattingVisitor) Visit(node *ASTNode, a *Analyzer) error {
 		formattedContent.WriteString(string(key.Content(nil)))
 		formattedContent.WriteString("=")
 		formattedContent.WriteString(string(value.Content(nil)))
-		edit := lsp.NewTextEdit(key, value, formattedContent.String())
+		edit := lsp.NewTextEdit(v.index, key, value, formattedContent.String())
 		edits = append(edits, edit)
 		// kamailio_cfg.UpdateTree(parser.GetTree(), key, value, formattedContent.String())
 		// parser.UpdateTree([]byte(formattedContent.String()))
 	case CompoundStatementNodeType:
 		leftBrace := n.Child(0)
 		rightBrace := n.Child(int(n.ChildCount() - 1))
-		edit := lsp.NewTextEdit(leftBrace, leftBrace, "{")
+		edit := lsp.NewTextEdit(v.index, leftBrace, leftBrace, "{")
 		edits = append(edits, edit)
 		// kamailio_cfg.UpdateTree(parser.GetTree(), leftBrace, leftBrace, "{")
 		// parser.UpdateTree([]byte("{"))
-		edit = lsp.NewTextEdit(rightBrace, rightBrace, "}")
+		edit = lsp.NewTextEdit(v.index, rightBrace, rightBrace, "}")
 		edits = append(edits, edit)
 		// kamailio_cfg.UpdateTree(parser.GetTree(), rightBrace, rightBrace, "}")
 		// parser.UpdateTree([]byte("}"))
@@ -96,7 +99,7 @@ func (v *FormattingVisitor) Visit(node *ASTNode, a *Analyzer) error {
 		formattedContent := strings.Builder{}
 		content := "if "
 		formattedContent.WriteString(content)
-		edit := lsp.NewTextEdit(ifKeyword, ifKeyword, formattedContent.String())
+		edit := lsp.NewTextEdit(v.index, ifKeyword, ifKeyword, formattedContent.String())
 		edits = append(edits, edit)
 	case "block_start":
 		block_level++
@@ -106,7 +109,7 @@ func (v *FormattingVisitor) Visit(node *ASTNode, a *Analyzer) error {
 		log.Info().Msgf("DECREASING Block level: %d", block_level)
 	case "core_function":
 		content := string(n.Content(nil))
-		edit := lsp.NewTextEdit(n, n, content)
+		edit := lsp.NewTextEdit(v.index, n, n, content)
 		edits = append(edits, edit)
 	case "call_expression":
 		// content := string(sourceCode[node.StartByte():node.EndByte()])
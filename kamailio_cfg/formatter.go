@@ -6,7 +6,10 @@ import (
 	"strings"
 )
 
-func FixIndent(content string) []lsp.TextEdit {
+// FixIndent returns an edit replacing the whole content with its lines indented by tab, one level per open brace.
+// No edit is returned if the braces are not balanced.
+// The range of the edit is in the encoding of the given line index of the content.
+func FixIndent(content string, index *lsp.LineIndex) []lsp.TextEdit {
 	indentStr := "\t"
 	lines := strings.Split(content, "\n")
 	var formatted []string
@@ -57,10 +60,7 @@ func FixIndent(content string) []lsp.TextEdit {
 				Line:      0,
 				Character: 0,
 			},
-			End: lsp.Position{
-				Line:      len(lines),
-				Character: len(lines[len(lines)-1]),
-			},
+			End: index.Position(index.Point(len(content))),
 		},
 		NewText: strings.Join(formatted, "\n"),
 	}
@@ -0,0 +1,23 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"testing"
+)
+
+func TestFixIndentRange(t *testing.T) {
+	content := "request_route {\nxlog(\"😀\");\n}  # 😀"
+	edits := kamailio_cfg.FixIndent(content, lsp.NewLineIndex(content, lsp.UTF16))
+	if len(edits) != 1 {
+		t.Fatalf("Expected: 1 edit,\ngot: %+v", edits)
+	}
+	// the edit ends at the end of the last line, counted in UTF-16 code units
+	expected := lsp.Position{Line: 2, Character: 7}
+	if edits[0].Range.End != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, edits[0].Range.End)
+	}
+	if edits[0].NewText != "request_route {\n\txlog(\"😀\");\n}  # 😀" {
+		t.Fatalf("Expected: the block indented,\ngot: %q", edits[0].NewText)
+	}
+}
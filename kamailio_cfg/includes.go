@@ -0,0 +1,69 @@
+package kamailio_cfg
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const _INCLUDE_QUERY = `[
+    (include_file file_name: (string) @file)
+    (import_file file_name: (string) @file)
+    ]`
+
+const (
+	IncludeFileNodeType = "include_file"
+	ImportFileNodeType  = "import_file"
+)
+
+// Include is an include_file or import_file directive of a document.
+// A file included with include_file must exist, a file imported with import_file may not.
+type Include struct {
+	Path       string // The path of the included file, as written in the directive.
+	Import     bool   // Whether the directive is an import_file.
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+}
+
+// ExtractIncludes collects the include_file and import_file directives of the document parsed by the analyzer.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	[]Include - The directives, in document order.
+func ExtractIncludes(a *Analyzer, source_code []byte) []Include {
+	var includes []Include
+	if a.ast == nil {
+		return includes
+	}
+	q, err := NewQueryExecutor(_INCLUDE_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return includes
+	}
+	for {
+		match, ok := q.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range match.Captures {
+			node := capture.Node
+			path := strings.Trim(node.Content(source_code), "\"")
+			if path == "" {
+				continue
+			}
+			includes = append(includes, Include{
+				Path:       path,
+				Import:     node.Parent() != nil && node.Parent().Type() == ImportFileNodeType,
+				StartPoint: node.StartPoint(),
+				EndPoint:   node.EndPoint(),
+			})
+		}
+	}
+	return includes
+}
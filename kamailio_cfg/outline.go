@@ -0,0 +1,247 @@
+package kamailio_cfg
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const (
+	LoadModuleNodeType       = "loadmodule"
+	ModparamNodeType         = "modparam"
+	PreprocDefNodeType       = "preproc_def"
+	PreprocTrydefNodeType    = "preproc_trydef"
+	PreprocRedefNodeType     = "preproc_redef"
+	PreprocSubstdefNodeType  = "preproc_substdef"
+	PreprocSubstdefsNodeType = "preproc_substdefs"
+)
+
+// _NOT_LOADED is the detail of a module set with modparam before, or without, being loaded.
+const _NOT_LOADED = "Not loaded in this document"
+
+// OutlineKind is the kind of an item of the outline of a document.
+type OutlineKind int
+
+const (
+	RouteOutline     OutlineKind = iota // a routing block
+	ModuleOutline                       // a module, with its loadmodule and modparam statements
+	ModparamOutline                     // a modparam statement, nested under its module
+	DefineOutline                       // a #!define, #!trydef, #!redefine, #!substdef or #!substdefs directive
+	ParameterOutline                    // a core parameter assignment, e.g. debug=2
+)
+
+// _ROUTE_DETAILS describes the routing blocks by type.
+var _ROUTE_DETAILS = map[string]string{
+	"request_route": "Main SIP request route",
+	"route":         "Sub-route",
+	"branch_route":  "Branch route, run for each branch of the request",
+	"failure_route": "Failure route, run when a transaction fails",
+	"onreply_route": "Reply route, run when a reply is received",
+	"reply_route":   "Stateless reply route",
+	"onsend_route":  "Send route, run before the request is sent",
+	"event_route":   "Event route, run by Kamailio or a module",
+}
+
+// OutlineItem is an item of the outline of a document.
+type OutlineItem struct {
+	Kind     OutlineKind   // The kind of the item.
+	Name     string        // The name of the item, e.g. route[AUTH], tm or debug.
+	Detail   string        // The detail of the item, e.g. the value of a define.
+	Children []OutlineItem // The nested items, the modparam statements of a module.
+
+	StartPoint     sitter.Point // The start of the whole item.
+	EndPoint       sitter.Point // The end of the whole item.
+	NameStartPoint sitter.Point // The start of the name of the item.
+	NameEndPoint   sitter.Point // The end of the name of the item.
+}
+
+// Route returns the type and the unquoted name of the routing block of a route item,
+// e.g. failure_route and MANAGE_FAILURE, the name is empty for the unnamed blocks such as request_route.
+//
+// Returns:
+//
+//	string - The type of the routing block.
+//	string - The name of the routing block.
+func (item OutlineItem) Route() (string, string) {
+	routeType, name, _ := strings.Cut(item.Name, "[")
+	return routeType, routeNameValue(strings.TrimSuffix(name, "]"))
+}
+
+// Contains checks whether the item range contains the given point.
+func (item OutlineItem) Contains(point sitter.Point) bool {
+	return !pointBefore(point, item.StartPoint) && !pointBefore(item.EndPoint, point)
+}
+
+// Outline returns the outline of the document parsed by the analyzer: its routing blocks,
+// its modules with their parameters, its defines and its core parameters.
+// A module is placed at its first loadmodule statement, and spans its modparam statements.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	[]OutlineItem - The items, in document order.
+func Outline(a *Analyzer, source_code []byte) []OutlineItem {
+	var items []OutlineItem
+	if a.ast == nil {
+		return items
+	}
+	modules := make(map[string]int)
+	module := func(name string) *OutlineItem {
+		if i, found := modules[name]; found {
+			return &items[i]
+		}
+		return nil
+	}
+	root := a.ast.Node
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		item := root.NamedChild(i)
+		if item.NamedChildCount() == 0 {
+			continue
+		}
+		node := item.NamedChild(0)
+		switch node.Type() {
+		case RoutingBlockNodeType:
+			if outline, ok := routeOutline(node, source_code); ok {
+				items = append(items, outline)
+			}
+		case LoadModuleNodeType:
+			path := node.ChildByFieldName("module_name")
+			if path == nil {
+				continue
+			}
+			name := moduleName(path.Content(source_code))
+			if name == "" {
+				continue
+			}
+			if loaded := module(name); loaded != nil {
+				if loaded.Detail == _NOT_LOADED {
+					loaded.Detail = strings.Trim(path.Content(source_code), "\"'")
+					loaded.NameStartPoint, loaded.NameEndPoint = path.StartPoint(), path.EndPoint()
+				}
+				loaded.EndPoint = latestPoint(loaded.EndPoint, node.EndPoint())
+				continue
+			}
+			modules[name] = len(items)
+			items = append(items, newOutlineItem(ModuleOutline, name, strings.Trim(path.Content(source_code), "\"'"), node, path))
+		case ModparamNodeType:
+			path := node.ChildByFieldName("module_name")
+			parameter := node.ChildByFieldName("parameter_name")
+			if path == nil || parameter == nil {
+				continue
+			}
+			name := moduleName(path.Content(source_code))
+			if name == "" {
+				continue
+			}
+			detail := ""
+			if value := node.ChildByFieldName("value"); value != nil {
+				detail = value.Content(source_code)
+			}
+			outline := newOutlineItem(ModparamOutline, strings.Trim(parameter.Content(source_code), "\"'"), detail, node, parameter)
+			loaded := module(name)
+			if loaded == nil {
+				modules[name] = len(items)
+				items = append(items, newOutlineItem(ModuleOutline, name, _NOT_LOADED, node, path))
+				loaded = &items[len(items)-1]
+			}
+			loaded.EndPoint = latestPoint(loaded.EndPoint, node.EndPoint())
+			loaded.Children = append(loaded.Children, outline)
+		case PreprocDefNodeType, PreprocTrydefNodeType, PreprocRedefNodeType:
+			name := node.ChildByFieldName("name")
+			if name == nil {
+				continue
+			}
+			detail := directive(node, source_code)
+			if value := node.ChildByFieldName("value"); value != nil {
+				detail += " " + strings.TrimSpace(value.Content(source_code))
+			}
+			items = append(items, newOutlineItem(DefineOutline, name.Content(source_code), detail, node, name))
+		case PreprocSubstdefNodeType, PreprocSubstdefsNodeType:
+			value := node.ChildByFieldName("value")
+			if value == nil {
+				continue
+			}
+			match, replacement, ok := substitution(value.Content(source_code))
+			if !ok {
+				continue
+			}
+			items = append(items, newOutlineItem(DefineOutline, match, directive(node, source_code)+" "+replacement, node, value))
+		case TopLevelAssignmentNodeType:
+			key := node.ChildByFieldName("key")
+			if key == nil {
+				continue
+			}
+			detail := ""
+			if value := node.ChildByFieldName("value"); value != nil {
+				detail = value.Content(source_code)
+			}
+			items = append(items, newOutlineItem(ParameterOutline, key.Content(source_code), detail, node, key))
+		}
+	}
+	return items
+}
+
+// routeOutline returns the outline item of a routing block.
+func routeOutline(node *sitter.Node, source_code []byte) (OutlineItem, bool) {
+	route := node.ChildByFieldName("route")
+	if route == nil {
+		return OutlineItem{}, false
+	}
+	name := route
+	if routeName := node.ChildByFieldName("route_name"); routeName != nil {
+		name = routeName
+	}
+	detail, found := _ROUTE_DETAILS[route.Content(source_code)]
+	if !found {
+		detail = route.Content(source_code)
+	}
+	return newOutlineItem(RouteOutline, RouteName(node, source_code), detail, node, name), true
+}
+
+// newOutlineItem creates an outline item spanning the node, named by the name node.
+func newOutlineItem(kind OutlineKind, name string, detail string, node *sitter.Node, nameNode *sitter.Node) OutlineItem {
+	return OutlineItem{
+		Kind:           kind,
+		Name:           name,
+		Detail:         detail,
+		StartPoint:     node.StartPoint(),
+		EndPoint:       node.EndPoint(),
+		NameStartPoint: nameNode.StartPoint(),
+		NameEndPoint:   nameNode.EndPoint(),
+	}
+}
+
+// directive returns the directive of a preprocessor node, e.g. #!define.
+func directive(node *sitter.Node, source_code []byte) string {
+	fields := strings.Fields(node.Content(source_code))
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
+// substitution splits the value of a #!substdef directive, e.g. "/MY_IP/127.0.0.1/",
+// into the matched text and its replacement. The first character is the separator.
+func substitution(value string) (string, string, bool) {
+	value = strings.Trim(strings.TrimSpace(value), "\"'")
+	if len(value) < 2 {
+		return "", "", false
+	}
+	parts := strings.Split(value[1:], value[:1])
+	if len(parts) < 2 || parts[0] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// latestPoint returns the latest of two points.
+func latestPoint(a, b sitter.Point) sitter.Point {
+	if pointBefore(a, b) {
+		return b
+	}
+	return a
+}
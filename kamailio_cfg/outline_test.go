@@ -0,0 +1,51 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"testing"
+)
+
+const outlineSource = `debug=2
+#!define WITH_AUTH
+#!substdef "/MY_IP/127.0.0.1/"
+loadmodule "/usr/lib/kamailio/modules/tm.so"
+modparam("tm", "fr_timer", 30)
+modparam("rr", "enable_full_lr", 1)
+request_route {
+}
+event_route[xhttp:request] {
+}
+modparam("tm", "fr_inv_timer", 120)
+`
+
+func TestOutline(t *testing.T) {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(outlineSource))
+	items := kamailio_cfg.Outline(analyzer, []byte(outlineSource))
+	expected := []struct {
+		kind     kamailio_cfg.OutlineKind
+		name     string
+		detail   string
+		children int
+	}{
+		{kamailio_cfg.ParameterOutline, "debug", "2", 0},
+		{kamailio_cfg.DefineOutline, "WITH_AUTH", "#!define", 0},
+		{kamailio_cfg.DefineOutline, "MY_IP", "#!substdef 127.0.0.1", 0},
+		{kamailio_cfg.ModuleOutline, "tm", "/usr/lib/kamailio/modules/tm.so", 2},
+		{kamailio_cfg.ModuleOutline, "rr", "Not loaded in this document", 1},
+		{kamailio_cfg.RouteOutline, "request_route", "Main SIP request route", 0},
+		{kamailio_cfg.RouteOutline, "event_route[xhttp:request]", "Event route, run by Kamailio or a module", 0},
+	}
+	if len(items) != len(expected) {
+		t.Fatalf("Expected: %d items,\ngot: %+v", len(expected), items)
+	}
+	for i, e := range expected {
+		item := items[i]
+		if item.Kind != e.kind || item.Name != e.name || item.Detail != e.detail || len(item.Children) != e.children {
+			t.Fatalf("Expected: %+v,\ngot: %+v", e, item)
+		}
+	}
+	if tm := items[3]; tm.EndPoint.Row != 10 || tm.Children[1].Name != "fr_inv_timer" {
+		t.Fatalf("Expected: tm spanning its last modparam,\ngot: %+v", tm)
+	}
+}
@@ -2,6 +2,7 @@ package kamailio_cfg
 
 import (
 	"context"
+	"errors"
 
 	"github.com/rs/zerolog/log"
 	sitter "github.com/smacker/go-tree-sitter"
@@ -21,6 +22,9 @@ import (
 
 // Implement Memento for parser to undo changes in case of error.
 
+// ErrParserClosed is returned when parsing with a parser that was closed.
+var ErrParserClosed = errors.New("the parser is closed")
+
 // Parser is a struct that encapsulates the tree-sitter parser and its associated state.
 // It holds references to the current and previous parse trees, as well as the language
 // used for parsing.
@@ -69,24 +73,79 @@ func (p *Parser) GetLanguage() *sitter.Language {
 //
 //	*sitter.Node - The root node of the constructed AST, or nil if parsing fails.
 func (p *Parser) Parse(sourceCode []byte) *sitter.Node {
+	n, _ := p.ParseCtx(context.Background(), sourceCode)
+	return n
+}
+
+// ParseCtx parses the given source code like Parse, but aborts as soon as the context is cancelled.
+// A cancelled parse leaves the current parse tree untouched.
+//
+// Parameters:
+//
+//	ctx context.Context - The context bounding the parse.
+//	sourceCode []byte - The source code to be parsed into an AST.
+//
+// Returns:
+//
+//	*sitter.Node - The root node of the constructed AST, or nil if parsing fails.
+//	error - The context error if the parse was cancelled, the parser error if it failed.
+func (p *Parser) ParseCtx(ctx context.Context, sourceCode []byte) (*sitter.Node, error) {
 	if p.language == nil {
 		log.Fatal().Msg("Parser not initialized")
-		return nil
+		return nil, nil
+	}
+	if p.parser == nil {
+		return nil, ErrParserClosed
 	}
-	tree, err := p.parser.ParseCtx(context.Background(), p.oldTree, sourceCode)
+	tree, err := p.parser.ParseCtx(ctx, p.oldTree, sourceCode)
 	if err != nil {
+		if ctx.Err() != nil {
+			log.Debug().Err(err).Msg("Parsing cancelled")
+			return nil, err
+		}
 		log.Error().Err(err).Msg("Error parsing the source code")
 		p.oldTree = nil
-		return nil
+		return nil, err
 	}
+	// the old tree only matches the source code it was edited for
+	p.oldTree = nil
+	p.tree = tree
+	n := p.tree.RootNode()
+	return n, nil
+}
+
+// Edit applies an edit of the source code to the current parse tree.
+// The next parse reuses the edited tree, so only the changed parts of the source code are parsed again.
+// Edits must be applied in the order they were made to the source code.
+//
+// Parameters:
+//
+//	edit sitter.EditInput - The edit of the source code, in bytes and tree-sitter points.
+func (p *Parser) Edit(edit sitter.EditInput) {
 	if p.tree == nil {
-		p.tree = tree
-	} else {
-		p.oldTree = p.tree
-		p.tree = tree
+		return
 	}
-	n := p.tree.RootNode()
-	return n
+	p.tree.Edit(edit)
+	p.oldTree = p.tree
+}
+
+// Reset drops the previous parse tree, so that the next parse starts from scratch.
+// It must be called before parsing source code the current parse tree was not edited for.
+func (p *Parser) Reset() {
+	p.oldTree = nil
+}
+
+// Close frees the parser and its parse tree. Parsing afterwards fails with ErrParserClosed.
+func (p *Parser) Close() {
+	if p.parser == nil {
+		return
+	}
+	if p.tree != nil {
+		p.tree.Close()
+	}
+	p.tree, p.oldTree = nil, nil
+	p.parser.Close()
+	p.parser = nil
 }
 
 // GetTree returns the current parse tree.
@@ -0,0 +1,350 @@
+package kamailio_cfg
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const _REFERENCE_QUERY = `[
+    (routing_block route: (predef_route) @route.type route_name: (_) @route.declaration)
+    (route_call route_name: (_) @route)
+    (call_expression
+        function: (expression (identifier) @route.setter)
+        arguments: (argument_list . (expression (string) @route.string)))
+    (preproc_def name: (identifier) @define.declaration)
+    (preproc_trydef name: (identifier) @define.declaration)
+    (preproc_redef name: (identifier) @define.declaration)
+    (preproc_ifdef name: (identifier) @define)
+    (preproc_ifndef name: (identifier) @define)
+    (expression (identifier) @define)
+    (loadmodule module_name: (string) @module.declaration)
+    (modparam module_name: (string) @module)
+    (call_expression function: (expression (identifier) @trigger))
+    ]`
+
+// _ROUTE_SETTERS maps the functions arming a routing block to the type of the block,
+// e.g. t_on_failure("MANAGE_FAILURE") refers to failure_route[MANAGE_FAILURE].
+var _ROUTE_SETTERS = map[string]string{
+	"t_on_failure":     "failure_route",
+	"t_on_branch":      "branch_route",
+	"t_on_reply":       "onreply_route",
+	"async_route":      RouteBlockType,
+	"async_ms_route":   RouteBlockType,
+	"async_task_route": RouteBlockType,
+}
+
+// _EVENT_ROUTE_SETTERS maps the functions arming an event_route to the prefix of its name,
+// e.g. t_on_branch_failure("RETRY") refers to event_route[tm:branch-failure:RETRY].
+var _EVENT_ROUTE_SETTERS = map[string]string{
+	"t_on_branch_failure": "tm:branch-failure:",
+}
+
+// _EVENT_TRIGGERS maps the module functions to the event_route blocks they trigger,
+// e.g. the dialog created by dlg_manage() triggers event_route[dialog:start] once it is confirmed.
+var _EVENT_TRIGGERS = map[string][]string{
+	"dlg_manage":    {"dialog:start", "dialog:end", "dialog:failed"},
+	"sl_send_reply": {"sl:local-response"},
+	"send_reply":    {"sl:local-response"},
+	"t_reply":       {"tm:local-response"},
+	"t_send_reply":  {"tm:local-response"},
+	"uac_req_send":  {"uac:reply"},
+}
+
+// ReferenceKind is the kind of the name a reference refers to.
+type ReferenceKind int
+
+const (
+	RouteReference    ReferenceKind = iota // a route called with route(...)
+	VariableReference                      // a user defined variable, see Symbol
+	DefineReference                        // a #!define name
+	ModuleReference                        // a module loaded with loadmodule
+)
+
+// Reference is a declaration or a use of a route, a variable, a define or a module in a document.
+type Reference struct {
+	Kind        ReferenceKind // The kind of the name.
+	Name        string        // The name, unquoted, e.g. AUTH, $var(x), WITH_AUTH or tm.
+	Declaration bool          // Whether the name is declared here: a route block, an assignment, a #!define or a loadmodule.
+	RouteType   string        // The type of the routing block of a route reference, e.g. route or failure_route.
+	Variable    Symbol        // The symbol of a variable reference.
+	StartPoint  sitter.Point
+	EndPoint    sitter.Point
+}
+
+// Contains checks whether the reference range contains the given point.
+func (r Reference) Contains(point sitter.Point) bool {
+	return !pointBefore(point, r.StartPoint) && !pointBefore(r.EndPoint, point)
+}
+
+// NameRange returns the range of the name alone, e.g. x of $var(x), which is the range
+// of the reference for the routes, the defines and the modules.
+//
+// Returns:
+//
+//	sitter.Point - The start of the name.
+//	sitter.Point - The end of the name.
+func (r Reference) NameRange() (sitter.Point, sitter.Point) {
+	if r.Kind == VariableReference {
+		return r.Variable.IdentifierStartPoint, r.Variable.IdentifierEndPoint
+	}
+	return r.StartPoint, r.EndPoint
+}
+
+// Local checks whether the reference only refers to names of its own document,
+// which is the case of the local variables.
+func (r Reference) Local() bool {
+	return r.Kind == VariableReference && r.Variable.Kind == LocalVariable
+}
+
+// Refers checks whether two references refer to the same name.
+//
+// Parameters:
+//
+//	other Reference - The reference to compare with.
+//
+// Returns:
+//
+//	bool - True if both references refer to the same name.
+func (r Reference) Refers(other Reference) bool {
+	if r.Kind != other.Kind {
+		return false
+	}
+	switch r.Kind {
+	case VariableReference:
+		return r.Variable.SameVariable(other.Variable)
+	case RouteReference:
+		return r.RouteType == other.RouteType && r.Name == other.Name
+	}
+	return r.Name == other.Name
+}
+
+// ReferenceIndex records the declarations and uses of the routes, variables, defines and modules of a document.
+type ReferenceIndex struct {
+	references []Reference
+	triggers   []Reference // the event_route blocks triggered by module functions, see RouteCalls
+}
+
+// NewReferenceIndex creates and returns an empty reference index.
+//
+// Returns:
+//
+//	*ReferenceIndex - The empty reference index.
+func NewReferenceIndex() *ReferenceIndex {
+	return &ReferenceIndex{}
+}
+
+// BuildReferenceIndex collects the references of the document parsed by the analyzer.
+// The variables are taken from the symbol table of the document.
+// Identifiers used in expressions are recorded as define uses, except the names of the called functions.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//	symbols *SymbolTable - The symbol table of the document.
+//
+// Returns:
+//
+//	*ReferenceIndex - The reference index of the document.
+func BuildReferenceIndex(a *Analyzer, source_code []byte, symbols *SymbolTable) *ReferenceIndex {
+	index := NewReferenceIndex()
+	if a.ast == nil {
+		return index
+	}
+	q, err := NewQueryExecutor(_REFERENCE_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return index
+	}
+	for {
+		match, ok := q.NextMatch()
+		if !ok {
+			break
+		}
+		routeType, setter := RouteBlockType, ""
+		for _, capture := range match.Captures {
+			node := capture.Node
+			switch q.query.CaptureNameForId(capture.Index) {
+			case "route.type":
+				routeType = node.Content(source_code)
+			case "route.setter":
+				setter = node.Content(source_code)
+			case "route.declaration":
+				index.addRoute(routeType, "", true, node, source_code)
+			case "route":
+				index.addRoute(RouteBlockType, "", false, node, source_code)
+			case "route.string":
+				if routeType, found := _ROUTE_SETTERS[setter]; found {
+					index.addRoute(routeType, "", false, node, source_code)
+				} else if prefix, found := _EVENT_ROUTE_SETTERS[setter]; found {
+					index.addRoute(EventRouteType, prefix, false, node, source_code)
+				}
+			case "trigger":
+				for _, event := range _EVENT_TRIGGERS[node.Content(source_code)] {
+					if reference, ok := newReference(RouteReference, event, false, node, source_code); ok {
+						reference.RouteType = EventRouteType
+						index.triggers = append(index.triggers, reference)
+					}
+				}
+			case "define.declaration":
+				index.add(DefineReference, node.Content(source_code), true, node, source_code)
+			case "define":
+				if !isCalledFunction(node) {
+					index.add(DefineReference, node.Content(source_code), false, node, source_code)
+				}
+			case "module.declaration":
+				index.add(ModuleReference, moduleName(node.Content(source_code)), true, node, source_code)
+			case "module":
+				index.add(ModuleReference, moduleName(node.Content(source_code)), false, node, source_code)
+			}
+		}
+	}
+	for _, symbol := range symbols.Symbols() {
+		index.references = append(index.references, Reference{
+			Kind:        VariableReference,
+			Name:        symbol.Name,
+			Declaration: symbol.Definition,
+			Variable:    symbol,
+			StartPoint:  symbol.StartPoint,
+			EndPoint:    symbol.EndPoint,
+		})
+	}
+	return index
+}
+
+// addRoute records a reference to a routing block of the given type, named by the node
+// with the given prefix.
+func (i *ReferenceIndex) addRoute(routeType string, prefix string, declaration bool, node *sitter.Node, source_code []byte) {
+	name := routeNameValue(node.Content(source_code))
+	if name == "" {
+		return
+	}
+	if reference, ok := newReference(RouteReference, prefix+name, declaration, node, source_code); ok {
+		reference.RouteType = routeType
+		i.references = append(i.references, reference)
+	}
+}
+
+// add records a reference to a define or a module.
+func (i *ReferenceIndex) add(kind ReferenceKind, name string, declaration bool, node *sitter.Node, source_code []byte) {
+	if reference, ok := newReference(kind, name, declaration, node, source_code); ok {
+		i.references = append(i.references, reference)
+	}
+}
+
+// newReference creates a reference, its range is the node range without the quotes of a string.
+func newReference(kind ReferenceKind, name string, declaration bool, node *sitter.Node, source_code []byte) (Reference, bool) {
+	if name == "" {
+		return Reference{}, false
+	}
+	start, end := node.StartPoint(), node.EndPoint()
+	content := node.Content(source_code)
+	if len(content) >= 2 && strings.ContainsRune("\"'", rune(content[0])) && start.Row == end.Row {
+		start.Column++
+		end.Column--
+	}
+	return Reference{
+		Kind:        kind,
+		Name:        name,
+		Declaration: declaration,
+		StartPoint:  start,
+		EndPoint:    end,
+	}, true
+}
+
+// At returns the reference at the given point, the innermost one if references are nested,
+// e.g. a $var(...) used as the key of a $sht(...).
+//
+// Parameters:
+//
+//	point sitter.Point - The point within the document.
+//
+// Returns:
+//
+//	*Reference - The reference at the point, or nil if there is none.
+func (i *ReferenceIndex) At(point sitter.Point) *Reference {
+	var found *Reference
+	for j := range i.references {
+		reference := &i.references[j]
+		if reference.Contains(point) && (found == nil || !pointBefore(reference.StartPoint, found.StartPoint)) {
+			found = reference
+		}
+	}
+	return found
+}
+
+// Find returns the references of the document referring to the same name as the given reference.
+//
+// Parameters:
+//
+//	target Reference - A declaration or a use of the name.
+//
+// Returns:
+//
+//	[]Reference - The declarations and uses of the name, in document order.
+func (i *ReferenceIndex) Find(target Reference) []Reference {
+	var references []Reference
+	for _, reference := range i.references {
+		if reference.Refers(target) {
+			references = append(references, reference)
+		}
+	}
+	sortReferences(references)
+	return references
+}
+
+// RouteCalls returns the calls to routing blocks of the document: the route(...) calls,
+// the routes armed by a function such as t_on_failure("...") and the event_route blocks
+// triggered by module functions such as dlg_manage().
+//
+// Returns:
+//
+//	[]Reference - The route references of the calls, in document order.
+func (i *ReferenceIndex) RouteCalls() []Reference {
+	var calls []Reference
+	for _, reference := range i.references {
+		if reference.Kind == RouteReference && !reference.Declaration {
+			calls = append(calls, reference)
+		}
+	}
+	calls = append(calls, i.triggers...)
+	sortReferences(calls)
+	return calls
+}
+
+// sortReferences sorts references in document order.
+func sortReferences(references []Reference) {
+	slices.SortStableFunc(references, func(a, b Reference) int {
+		switch {
+		case pointBefore(a.StartPoint, b.StartPoint):
+			return -1
+		case pointBefore(b.StartPoint, a.StartPoint):
+			return 1
+		}
+		return 0
+	})
+}
+
+// isCalledFunction checks whether an identifier is the name of a called function, e.g. sl_send_reply.
+func isCalledFunction(identifier *sitter.Node) bool {
+	expression := identifier.Parent()
+	if expression == nil || expression.Parent() == nil || expression.Parent().Type() != CallExpressionNodeType {
+		return false
+	}
+	function := expression.Parent().ChildByFieldName("function")
+	return function != nil && function.StartByte() == expression.StartByte() && function.EndByte() == expression.EndByte()
+}
+
+// moduleName returns the name of a module as given to loadmodule or modparam,
+// loadmodule "/usr/lib/kamailio/modules/tm.so" and modparam("tm", ...) refer to the same module.
+func moduleName(name string) string {
+	name = strings.Trim(strings.TrimSpace(name), "\"'")
+	if name == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path.Base(name), ".so")
+}
@@ -0,0 +1,60 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const referencesSource = `#!define WITH_AUTH
+loadmodule "/usr/lib/kamailio/modules/tm.so"
+modparam("tm", "fr_timer", 30)
+#!ifdef WITH_AUTH
+#!endif
+request_route {
+  $var(x) = WITH_AUTH;
+  sl_send_reply("200", "OK");
+  route("AUTH");
+}
+route[AUTH] {
+  $var(x) = 1;
+}
+`
+
+func TestReferenceIndex(t *testing.T) {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	source := []byte(referencesSource)
+	analyzer.Build(source)
+	index := kamailio_cfg.BuildReferenceIndex(analyzer, source, kamailio_cfg.BuildSymbolTable(analyzer, source))
+	for _, test := range []struct {
+		point    sitter.Point
+		expected []sitter.Point
+	}{
+		// the define, its #!ifdef and its use in code, not the called function
+		{sitter.Point{Row: 0, Column: 10}, []sitter.Point{{Row: 0, Column: 9}, {Row: 3, Column: 8}, {Row: 6, Column: 12}}},
+		// the module, loaded by path
+		{sitter.Point{Row: 2, Column: 10}, []sitter.Point{{Row: 1, Column: 12}, {Row: 2, Column: 10}}},
+		// the route, called with a quoted name
+		{sitter.Point{Row: 8, Column: 10}, []sitter.Point{{Row: 8, Column: 9}, {Row: 10, Column: 6}}},
+		// the local variable of request_route only
+		{sitter.Point{Row: 6, Column: 4}, []sitter.Point{{Row: 6, Column: 2}}},
+	} {
+		target := index.At(test.point)
+		if target == nil {
+			t.Fatalf("Expected: a reference at %+v,\ngot: nil", test.point)
+		}
+		references := index.Find(*target)
+		if len(references) != len(test.expected) {
+			t.Fatalf("Expected: %v,\ngot: %+v", test.expected, references)
+		}
+		for i, e := range test.expected {
+			if references[i].StartPoint != e {
+				t.Fatalf("Expected: %v,\ngot: %v", e, references[i].StartPoint)
+			}
+		}
+	}
+	if target := index.At(sitter.Point{Row: 7, Column: 4}); target != nil {
+		t.Fatalf("Expected: no reference on a called function,\ngot: %+v", target)
+	}
+}
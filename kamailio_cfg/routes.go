@@ -1,64 +1,141 @@
 package kamailio_cfg
 
 import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
 	sitter "github.com/smacker/go-tree-sitter"
 )
 
+const (
+	RouteBlockType = "route"       // The type of the routing blocks called with route(...).
+	EventRouteType = "event_route" // The type of the routing blocks run by Kamailio or a module on an event.
+)
+
+// NamedRoute is a named routing block of a document, e.g. route[AUTH] or failure_route[MANAGE_FAILURE].
 type NamedRoute struct {
-	Name       string
-	Content    string
+	Type       string // The type of the route, e.g. route or failure_route.
+	Name       string // The name of the route, unquoted.
+	Content    string // The text of the routing block.
 	StartPoint sitter.Point
 	EndPoint   sitter.Point
 }
 
 func (nr NamedRoute) String() string {
-	return nr.Name
+	return nr.Type + "[" + nr.Name + "]"
 }
 
-func (nr NamedRoute) addContent(content string) {
+func (nr *NamedRoute) addContent(content string) {
 	nr.Content = content
 }
 
-func QueryRoute(a *Analyzer, source_code []byte) *NamedRoute {
+// RouteName returns the name of a routing block, as written in the configuration.
+//
+// Parameters:
+//
+//	node *sitter.Node - The routing_block node.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	string - The route name, e.g. request_route or route[AUTH].
+func RouteName(node *sitter.Node, source_code []byte) string {
+	route := node.ChildByFieldName("route")
+	if route == nil {
+		return ""
+	}
+	name := route.Content(source_code)
+	if routeName := node.ChildByFieldName("route_name"); routeName != nil {
+		name += "[" + routeName.Content(source_code) + "]"
+	}
+	return name
+}
+
+// QueryRoutes collects the named routing blocks of the document parsed by the analyzer.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	[]NamedRoute - The routing blocks, in document order, with the range of their name.
+func QueryRoutes(a *Analyzer, source_code []byte) []NamedRoute {
+	var routes []NamedRoute
+	if a.ast == nil {
+		return routes
+	}
 	q, err := NewQueryExecutor(
 		_ROUTE_DECLARATION_QUERY,
 		a.ast.Node,
 		a.builder.parser.language,
 	)
 	if err != nil {
-		return nil
+		log.Error().Err(err).Msg("Error creating query executor")
+		return routes
 	}
 
 	_routeTag := "definition.function"
 	_nameTag := "name"
 
-	var nodeContent string
-	var route *NamedRoute
 	for {
 		match, ok := q.NextMatch()
 		if !ok {
 			break
 		}
+		var route NamedRoute
+		var content string
 		for _, capture := range match.Captures {
 			node := capture.Node
-			captureName := q.query.CaptureNameForId(capture.Index)
-			if captureName == _routeTag {
-				nodeText := string(capture.Node.Content(source_code))
-				nodeContent = nodeText
-			}
-			if captureName == _nameTag {
-				nodeText := string(capture.Node.Content(source_code))
-				route = &NamedRoute{
-					Name:       nodeText,
-					StartPoint: node.StartPoint(),
-					EndPoint:   node.EndPoint(),
+			switch q.query.CaptureNameForId(capture.Index) {
+			case _routeTag:
+				content = node.Content(source_code)
+				if predefined := node.ChildByFieldName("route"); predefined != nil {
+					route.Type = predefined.Content(source_code)
 				}
+			case _nameTag:
+				route.Name = routeNameValue(node.Content(source_code))
+				route.StartPoint = node.StartPoint()
+				route.EndPoint = node.EndPoint()
 			}
 		}
+		route.addContent(content)
+		routes = append(routes, route)
+	}
+	return routes
+}
+
+// RouteCallAt returns the name of the route called by the route(...) statement at the given point.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//	point sitter.Point - The point within the document.
+//
+// Returns:
+//
+//	string - The name of the called route, unquoted.
+//	bool - False if there is no route call at the point.
+func RouteCallAt(a *Analyzer, source_code []byte, point sitter.Point) (string, bool) {
+	if a.ast == nil {
+		return "", false
 	}
-	if route != nil {
-		route.addContent(nodeContent)
-		return route
+	for node := a.ast.Node.NamedDescendantForPointRange(point, point); node != nil; node = node.Parent() {
+		if node.Type() != RouteCallNodeType {
+			continue
+		}
+		name := node.ChildByFieldName("route_name")
+		if name == nil {
+			return "", false
+		}
+		return routeNameValue(name.Content(source_code)), true
 	}
-	return nil
+	return "", false
+}
+
+// routeNameValue returns a route name without its quotes, route["AUTH"] and route(AUTH) call the same route.
+func routeNameValue(name string) string {
+	return strings.Trim(strings.TrimSpace(name), "\"'")
 }
@@ -0,0 +1,58 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const routesSource = `request_route {
+  route(1);
+  route("AUTH");
+}
+route[AUTH] {
+}
+route[1] {
+}
+failure_route[1] {
+}
+`
+
+func TestQueryRoutes(t *testing.T) {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(routesSource))
+	routes := kamailio_cfg.QueryRoutes(analyzer, []byte(routesSource))
+	expected := []string{"route[AUTH]", "route[1]", "failure_route[1]"}
+	if len(routes) != len(expected) {
+		t.Fatalf("Expected: %v,\ngot: %v", expected, routes)
+	}
+	for i, e := range expected {
+		if routes[i].String() != e {
+			t.Fatalf("Expected: %s,\ngot: %s", e, routes[i].String())
+		}
+	}
+	if routes[1].StartPoint != (sitter.Point{Row: 6, Column: 6}) {
+		t.Fatalf("Expected: the range of the name 1,\ngot: %+v", routes[1].StartPoint)
+	}
+}
+
+func TestRouteCallAt(t *testing.T) {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(routesSource))
+	for _, test := range []struct {
+		point    sitter.Point
+		expected string
+		found    bool
+	}{
+		{sitter.Point{Row: 1, Column: 8}, "1", true},
+		{sitter.Point{Row: 1, Column: 3}, "1", true},
+		{sitter.Point{Row: 2, Column: 10}, "AUTH", true},
+		{sitter.Point{Row: 4, Column: 2}, "", false},
+	} {
+		name, found := kamailio_cfg.RouteCallAt(analyzer, []byte(routesSource), test.point)
+		if name != test.expected || found != test.found {
+			t.Fatalf("Expected: %q %v,\ngot: %q %v", test.expected, test.found, name, found)
+		}
+	}
+}
@@ -0,0 +1,295 @@
+package kamailio_cfg
+
+import (
+	queries "KamaiZen/after/queries/kamailio_cfg"
+	"bytes"
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const (
+	PvarNodeType              = "pvar"
+	HdrNodeType               = "hdr"
+	DeprecatedCommentNodeType = "deprecated_comment"
+)
+
+// SemanticTokenType is the type of a semantic token, the class a range of the document is highlighted with.
+type SemanticTokenType int
+
+const (
+	NamespaceToken SemanticTokenType = iota // the #!KAMAILIO file starter
+	ParameterToken                          // a variable name, a route name or a function argument
+	VariableToken                           // a pseudo-variable
+	PropertyToken                           // a core parameter or an XAVP field
+	FunctionToken                           // a function call, a route call or a modparam statement
+	MacroToken                              // a preprocessor directive
+	KeywordToken                            // a keyword, e.g. request_route, if or return
+	CommentToken                            // a comment
+	StringToken                             // a string
+	NumberToken                             // a number
+	RegexpToken                             // a regular expression
+	OperatorToken                           // an operator, or the $ of a pseudo-variable
+)
+
+// SemanticTokenModifiers is a set of modifiers of a semantic token.
+type SemanticTokenModifiers int
+
+const (
+	ReadonlyModifier       SemanticTokenModifiers = 1 << iota // a pseudo-variable that cannot be assigned
+	DeprecatedModifier                                        // a deprecated # comment
+	DefaultLibraryModifier                                    // a core or module function, or a builtin pseudo-variable
+)
+
+// SemanticToken is a highlighted range of a document, within a single line.
+type SemanticToken struct {
+	Type       SemanticTokenType
+	Modifiers  SemanticTokenModifiers
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+}
+
+// Overlaps reports whether the token overlaps the range from start to end.
+func (t SemanticToken) Overlaps(start sitter.Point, end sitter.Point) bool {
+	return pointBefore(t.StartPoint, end) && pointBefore(start, t.EndPoint)
+}
+
+// tokenStyle is the type and the modifiers of the semantic tokens of a capture of the highlights query.
+type tokenStyle struct {
+	Type      SemanticTokenType
+	Modifiers SemanticTokenModifiers
+}
+
+// _HIGHLIGHT_CAPTURES maps the capture names of the highlights query to the styles of the semantic tokens.
+// A capture name missing from the map falls back to its parent name, e.g. keyword.return to keyword,
+// and the captures without a style, e.g. punctuation.bracket, are not highlighted.
+var _HIGHLIGHT_CAPTURES = map[string]tokenStyle{
+	"module":             {NamespaceToken, 0},
+	"comment":            {CommentToken, 0},
+	"keyword":            {KeywordToken, 0},
+	"keyword.operator":   {OperatorToken, 0},
+	"keyword.directive":  {MacroToken, 0},
+	"function":           {FunctionToken, 0},
+	"function.builtin":   {FunctionToken, DefaultLibraryModifier},
+	"string":             {StringToken, 0},
+	"string.regexp":      {RegexpToken, 0},
+	"number":             {NumberToken, 0},
+	"variable":           {VariableToken, 0},
+	"variable.parameter": {ParameterToken, 0},
+	"variable.builtin":   {VariableToken, DefaultLibraryModifier},
+	"variable.member":    {PropertyToken, 0},
+	"property":           {PropertyToken, 0},
+	"attribute.builtin":  {VariableToken, DefaultLibraryModifier},
+	"operator":           {OperatorToken, 0},
+	"character.special":  {OperatorToken, 0},
+}
+
+// _WRITABLE_PSEUDO_VARIABLES lists the builtin pseudo-variables that can be assigned,
+// the others are read-only. Named pseudo-variables such as $var(...) or $avp(...) are writable.
+var _WRITABLE_PSEUDO_VARIABLES = map[string]bool{
+	"ru": true, "rU": true, "rd": true, "rp": true, "rP": true, "rz": true,
+	"du": true, "fs": true, "bf": true, "bF": true, "br": true,
+	"fu": true, "fU": true, "fd": true, "fn": true,
+	"tu": true, "tU": true, "td": true, "tn": true,
+	"sf": true, "sF": true, "mf": true, "mF": true,
+}
+
+// _HIGHLIGHT_REGIONS lists the node types whose content is not highlighted by their own capture,
+// e.g. (preproc_ifdef) @keyword.directive highlights the #!ifdef, #!else and #!endif lines only.
+var _HIGHLIGHT_REGIONS = map[string]bool{
+	PreprocIfdefNodeType:  true,
+	PreprocIfndefNodeType: true,
+	PreprocElseNodeType:   true,
+}
+
+// captureStyle returns the style of a capture name, see _HIGHLIGHT_CAPTURES.
+func captureStyle(name string) (tokenStyle, bool) {
+	for {
+		if style, found := _HIGHLIGHT_CAPTURES[name]; found {
+			return style, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			return tokenStyle{}, false
+		}
+		name = name[:i]
+	}
+}
+
+// SemanticTokens highlights the document parsed by the analyzer with the bundled highlights query,
+// the query Neovim highlights the configuration with. As in Neovim, the innermost capture of a range
+// wins, and of the captures of a same range the last pattern of the query wins.
+// The modifiers come from the analysis: read-only pseudo-variables, deprecated comments,
+// and the calls of core and module functions.
+//
+// Parameters:
+//
+//	ctx context.Context - The context bounding the query, the highlighting stops once it is done.
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//	library func(name string) bool - Reports whether a function is a core or module function.
+//
+// Returns:
+//
+//	[]SemanticToken - The tokens of the document, in document order.
+//	error - The context error if the highlighting stopped.
+func SemanticTokens(ctx context.Context, a *Analyzer, source_code []byte, library func(name string) bool) ([]SemanticToken, error) {
+	var tokens []SemanticToken
+	if a.ast == nil {
+		return tokens, nil
+	}
+	q, err := NewQueryExecutor(queries.Highlights, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return tokens, nil
+	}
+	type capture struct {
+		node    *sitter.Node
+		pattern uint16
+		style   tokenStyle
+		styled  bool
+	}
+	var captures []capture
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		match, ok := q.NextMatch()
+		if !ok {
+			break
+		}
+		for _, c := range match.Captures {
+			style, styled := captureStyle(q.query.CaptureNameForId(c.Index))
+			if styled {
+				style.Modifiers |= nodeModifiers(c.Node, style.Type, source_code, library)
+			}
+			captures = append(captures, capture{c.Node, match.PatternIndex, style, styled})
+		}
+	}
+	// paint the outer captures first, so that the inner ones override them
+	slices.SortStableFunc(captures, func(a, b capture) int {
+		aSpan, bSpan := a.node.EndByte()-a.node.StartByte(), b.node.EndByte()-b.node.StartByte()
+		if aSpan != bSpan {
+			return int(bSpan) - int(aSpan)
+		}
+		return int(a.pattern) - int(b.pattern)
+	})
+	styles := []tokenStyle{{}}
+	paint := make([]int32, len(source_code))
+	for _, c := range captures {
+		id := int32(0)
+		if c.styled {
+			id = int32(len(styles))
+			styles = append(styles, c.style)
+		}
+		paintNode(paint, c.node, id)
+	}
+	var row uint32
+	for start := 0; start < len(source_code); row++ {
+		end := len(source_code)
+		if i := bytes.IndexByte(source_code[start:], '\n'); i >= 0 {
+			end = start + i
+		}
+		for i := start; i < end; {
+			id := paint[i]
+			j := i + 1
+			for j < end && paint[j] == id {
+				j++
+			}
+			from, to := i, j
+			for from < to && isBlank(source_code[from]) {
+				from++
+			}
+			for to > from && isBlank(source_code[to-1]) {
+				to--
+			}
+			if id != 0 && from < to {
+				tokens = append(tokens, SemanticToken{
+					Type:       styles[id].Type,
+					Modifiers:  styles[id].Modifiers,
+					StartPoint: sitter.Point{Row: row, Column: uint32(from - start)},
+					EndPoint:   sitter.Point{Row: row, Column: uint32(to - start)},
+				})
+			}
+			i = j
+		}
+		start = end + 1
+	}
+	return tokens, nil
+}
+
+// paintNode marks the bytes of a captured node with the style of the capture,
+// but the content of the regions, see _HIGHLIGHT_REGIONS.
+func paintNode(paint []int32, node *sitter.Node, id int32) {
+	from := node.StartByte()
+	if _HIGHLIGHT_REGIONS[node.Type()] {
+		name := node.ChildByFieldName("name")
+		for i := 0; i < int(node.ChildCount()); i++ {
+			child := node.Child(i)
+			if !child.IsNamed() || name != nil && child.StartByte() == name.StartByte() {
+				continue
+			}
+			fill(paint, from, child.StartByte(), id)
+			if child.Type() == PreprocElseNodeType {
+				paintNode(paint, child, id)
+			}
+			from = child.EndByte()
+		}
+	}
+	fill(paint, from, node.EndByte(), id)
+}
+
+// fill marks the bytes from start to end with the given style.
+func fill(paint []int32, start uint32, end uint32, id int32) {
+	for i := start; i < end && int(i) < len(paint); i++ {
+		paint[i] = id
+	}
+}
+
+// nodeModifiers returns the modifiers the analysis adds to the token of a captured node.
+func nodeModifiers(node *sitter.Node, tokenType SemanticTokenType, source_code []byte, library func(name string) bool) SemanticTokenModifiers {
+	var modifiers SemanticTokenModifiers
+	switch {
+	case tokenType == CommentToken:
+		if child := node.NamedChild(0); child != nil && child.Type() == DeprecatedCommentNodeType {
+			modifiers |= DeprecatedModifier
+		}
+	case node.Type() == PseudoContentNodeType:
+		if readonlyPseudoVariable(node, source_code) {
+			modifiers |= ReadonlyModifier
+		}
+	case tokenType == FunctionToken && node.Type() == ExpressionNodeType:
+		if parent := node.Parent(); parent != nil && parent.Type() == CallExpressionNodeType && library(node.Content(source_code)) {
+			modifiers |= DefaultLibraryModifier
+		}
+	}
+	return modifiers
+}
+
+// readonlyPseudoVariable reports whether the pseudo-variable of the content cannot be assigned:
+// a builtin pseudo-variable missing from _WRITABLE_PSEUDO_VARIABLES, a header or a transformation.
+func readonlyPseudoVariable(content *sitter.Node, source_code []byte) bool {
+	if parent := content.Parent(); parent != nil && parent.Type() == PseudoVariableExpressionNodeType &&
+		parent.ChildByFieldName("transformations") != nil {
+		return true
+	}
+	child := content.NamedChild(0)
+	if child == nil {
+		return false
+	}
+	switch child.Type() {
+	case PvarNodeType:
+		return !_WRITABLE_PSEUDO_VARIABLES[child.Content(source_code)]
+	case HdrNodeType:
+		return true
+	}
+	return false
+}
+
+// isBlank reports whether the byte is a space, a tab or a carriage return.
+func isBlank(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r'
+}
@@ -0,0 +1,50 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	source := "#!ifdef WITH_TM\nloadmodule \"tm.so\"\n#!endif\n# old\nrequest_route {\n  $ru = $rm;\n  xlog(\"L_INFO\", \"$var(a)\");\n}\n"
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(source))
+	lines := strings.Split(source, "\n")
+	highlighted, err := kamailio_cfg.SemanticTokens(context.Background(), analyzer, []byte(source), func(name string) bool { return name == "xlog" })
+	if err != nil {
+		t.Fatalf("Expected: no error,\ngot: %s", err)
+	}
+	tokens := make(map[string]kamailio_cfg.SemanticToken)
+	for _, token := range highlighted {
+		if token.StartPoint.Row != token.EndPoint.Row {
+			t.Fatalf("Expected: single line tokens,\ngot: %+v", token)
+		}
+		tokens[lines[token.StartPoint.Row][token.StartPoint.Column:token.EndPoint.Column]] = token
+	}
+	for _, test := range []struct {
+		text      string
+		tokenType kamailio_cfg.SemanticTokenType
+		modifiers kamailio_cfg.SemanticTokenModifiers
+	}{
+		{"#!ifdef WITH_TM", kamailio_cfg.MacroToken, 0},
+		{"#!endif", kamailio_cfg.MacroToken, 0},
+		{"loadmodule", kamailio_cfg.KeywordToken, 0},
+		{"\"tm.so\"", kamailio_cfg.StringToken, 0},
+		{"# old", kamailio_cfg.CommentToken, kamailio_cfg.DeprecatedModifier},
+		{"request_route", kamailio_cfg.KeywordToken, 0},
+		{"ru", kamailio_cfg.VariableToken, kamailio_cfg.DefaultLibraryModifier},
+		{"rm", kamailio_cfg.VariableToken, kamailio_cfg.DefaultLibraryModifier | kamailio_cfg.ReadonlyModifier},
+		{"xlog", kamailio_cfg.FunctionToken, kamailio_cfg.DefaultLibraryModifier},
+		{"\"L_INFO\"", kamailio_cfg.StringToken, 0},
+	} {
+		token, found := tokens[test.text]
+		if !found {
+			t.Fatalf("Expected: a token for %s,\ngot: %+v", test.text, tokens)
+		}
+		if token.Type != test.tokenType || token.Modifiers != test.modifiers {
+			t.Fatalf("Expected: %s as %d %d,\ngot: %d %d", test.text, test.tokenType, test.modifiers, token.Type, token.Modifiers)
+		}
+	}
+}
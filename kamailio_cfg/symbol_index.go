@@ -0,0 +1,149 @@
+package kamailio_cfg
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const _SET_QUERY = `(call_expression
+    function: (expression (identifier) @function)
+    arguments: (argument_list . (expression [(string) (number_literal)] @set)))`
+
+// _SET_FUNCTIONS maps the functions taking a set ID as their first argument to the kind of the set.
+var _SET_FUNCTIONS = map[string]string{
+	"ds_select":        "dispatcher set",
+	"ds_select_dst":    "dispatcher set",
+	"ds_select_domain": "dispatcher set",
+	"ds_is_from_list":  "dispatcher set",
+	"ds_list_exists":   "dispatcher set",
+	"ds_count":         "dispatcher set",
+	"dp_translate":     "dialplan",
+	"dp_match":         "dialplan",
+	"load_gws":         "lcr instance",
+}
+
+// IndexedSymbolKind is the kind of a symbol of the workspace symbol index.
+type IndexedSymbolKind int
+
+const (
+	RouteSymbol      IndexedSymbolKind = iota // a routing block, e.g. route[AUTH]
+	EventRouteSymbol                          // an event_route block, e.g. event_route[xhttp:request]
+	DefineSymbol                              // a #!define or #!substdef name
+	HTableSymbol                              // a hash table declared with modparam("htable", "htable", ...)
+	SetSymbol                                 // a set ID given as a literal, e.g. the dispatcher set of ds_select_dst("1", "4")
+)
+
+// IndexedSymbol is a named declaration of a document, searched by name across the workspace.
+type IndexedSymbol struct {
+	Kind       IndexedSymbolKind // The kind of the symbol.
+	Name       string            // The name of the symbol, e.g. route[AUTH], WITH_AUTH or dispatcher set 1.
+	Container  string            // What declares the symbol, e.g. failure_route, #!define or ds_select_dst.
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+}
+
+// IndexSymbols collects the symbols of the document parsed by the analyzer that are searched
+// across the workspace: its routing blocks, its defines, its hash tables and the literal set IDs
+// of its dispatcher, dialplan and lcr function calls. A set ID is only indexed at its first use.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//	outline []OutlineItem - The outline of the document, see Outline.
+//
+// Returns:
+//
+//	[]IndexedSymbol - The symbols of the document.
+func IndexSymbols(a *Analyzer, source_code []byte, outline []OutlineItem) []IndexedSymbol {
+	var symbols []IndexedSymbol
+	if a.ast == nil {
+		return symbols
+	}
+	for _, item := range outline {
+		symbol := IndexedSymbol{
+			Name:       item.Name,
+			Container:  item.Detail,
+			StartPoint: item.NameStartPoint,
+			EndPoint:   item.NameEndPoint,
+		}
+		switch item.Kind {
+		case RouteOutline:
+			symbol.Kind = RouteSymbol
+			symbol.Container, _, _ = strings.Cut(item.Name, "[")
+			if symbol.Container == EventRouteType {
+				symbol.Kind = EventRouteSymbol
+			}
+		case DefineOutline:
+			symbol.Kind = DefineSymbol
+			symbol.Container, _, _ = strings.Cut(item.Detail, " ")
+		case ModuleOutline:
+			if item.Name == "htable" {
+				symbols = append(symbols, hashTables(item)...)
+			}
+			continue
+		default:
+			continue
+		}
+		symbols = append(symbols, symbol)
+	}
+	q, err := NewQueryExecutor(_SET_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return symbols
+	}
+	seen := make(map[string]bool)
+	for {
+		match, ok := q.NextMatch()
+		if !ok {
+			break
+		}
+		var function string
+		for _, capture := range match.Captures {
+			node := capture.Node
+			if q.query.CaptureNameForId(capture.Index) == "function" {
+				function = node.Content(source_code)
+				continue
+			}
+			set, found := _SET_FUNCTIONS[function]
+			id := strings.Trim(node.Content(source_code), "\"'")
+			if !found || id == "" || strings.Trim(id, "0123456789") != "" || seen[set+id] {
+				continue
+			}
+			seen[set+id] = true
+			symbols = append(symbols, IndexedSymbol{
+				Kind:       SetSymbol,
+				Name:       set + " " + id,
+				Container:  function,
+				StartPoint: node.StartPoint(),
+				EndPoint:   node.EndPoint(),
+			})
+		}
+	}
+	return symbols
+}
+
+// hashTables returns the hash tables declared by the modparam statements of the htable module,
+// e.g. modparam("htable", "htable", "ipban=>size=8;autoexpire=300;") declares ipban.
+func hashTables(module OutlineItem) []IndexedSymbol {
+	var symbols []IndexedSymbol
+	for _, parameter := range module.Children {
+		if parameter.Name != "htable" {
+			continue
+		}
+		name, _, found := strings.Cut(strings.Trim(parameter.Detail, "\"'"), "=>")
+		if name = strings.TrimSpace(name); !found || name == "" {
+			continue
+		}
+		symbols = append(symbols, IndexedSymbol{
+			Kind:       HTableSymbol,
+			Name:       name,
+			Container:  "htable",
+			StartPoint: parameter.StartPoint,
+			EndPoint:   parameter.EndPoint,
+		})
+	}
+	return symbols
+}
@@ -0,0 +1,393 @@
+package kamailio_cfg
+
+import (
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const (
+	_AVP_IDENTIFIER     = "$avp"
+	_VAR_IDENTIFIER     = "$var"
+	_DLG_VAR_IDENTIFIER = "$dlg_var"
+	_XAVP_IDENTIFIER    = "$xavp"
+	_SHT_IDENTIFIER     = "$sht"
+)
+
+const (
+	_AVP_SCOPE = "Transaction"
+	_DLG_SCOPE = "Dialog"
+	_VAR_SCOPE = "Local"
+	_SHT_SCOPE = "Shared"
+)
+
+const (
+	_VARIABLE_QUERY = "[(var_) (avp_var) (dlg_var) (xavp_var) (htable)] @variable"
+	_ROUTE_QUERY    = "(routing_block) @route"
+)
+
+// VariableKind is the kind of a user defined variable, which determines its scope.
+type VariableKind int
+
+const (
+	AVPVariable   VariableKind = iota // $avp, scoped to the transaction
+	LocalVariable                     // $var, scoped to the route block
+	DlgVariable                       // $dlg_var, scoped to the dialog
+	XAVPVariable                      // $xavp, scoped to the transaction, named by its root
+	SHTVariable                       // $sht, shared by all processes, named by its hash table
+)
+
+// Symbol is a definition or a use of a user defined variable in a document.
+type Symbol struct {
+	Name       string       // The full name of the variable, e.g. $var(x).
+	Identifier string       // The name given to the variable, e.g. x.
+	Kind       VariableKind // The kind of the variable.
+	Definition bool         // Whether the variable is assigned here.
+	Value      string       // The assigned value, for definitions.
+	Route      string       // The enclosing route, e.g. route[AUTH], empty outside routes.
+	StartPoint sitter.Point
+	EndPoint   sitter.Point
+
+	IdentifierStartPoint sitter.Point // The start of the identifier, e.g. x of $var(x).
+	IdentifierEndPoint   sitter.Point // The end of the identifier.
+}
+
+// Scope returns the scope of the symbol.
+//
+// Returns:
+//
+//	string - The scope, the local scope names the enclosing route.
+func (s Symbol) Scope() string {
+	switch s.Kind {
+	case AVPVariable, XAVPVariable:
+		return _AVP_SCOPE
+	case DlgVariable:
+		return _DLG_SCOPE
+	case SHTVariable:
+		return _SHT_SCOPE
+	}
+	if s.Route == "" {
+		return _VAR_SCOPE
+	}
+	return _VAR_SCOPE + " to " + s.Route
+}
+
+// SameVariable checks whether two symbols refer to the same variable.
+// Local variables are only the same within the same route block.
+//
+// Parameters:
+//
+//	other Symbol - The symbol to compare with.
+//
+// Returns:
+//
+//	bool - True if both symbols refer to the same variable.
+func (s Symbol) SameVariable(other Symbol) bool {
+	if s.Kind != other.Kind || s.Name != other.Name {
+		return false
+	}
+	return s.Kind != LocalVariable || s.Route == other.Route
+}
+
+// Contains checks whether the symbol range contains the given point.
+func (s Symbol) Contains(point sitter.Point) bool {
+	return !pointBefore(point, s.StartPoint) && !pointBefore(s.EndPoint, point)
+}
+
+// routeRange is the range of a route block.
+type routeRange struct {
+	name       string
+	startPoint sitter.Point
+	endPoint   sitter.Point
+}
+
+// SymbolTable records the definitions and uses of the user defined variables of a document,
+// in document order, along with the route blocks enclosing them.
+type SymbolTable struct {
+	symbols []Symbol
+	routes  []routeRange
+}
+
+// NewSymbolTable creates and returns an empty symbol table.
+//
+// Returns:
+//
+//	*SymbolTable - The empty symbol table.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{}
+}
+
+// BuildSymbolTable collects the variables defined and used in the document parsed by the analyzer.
+//
+// Parameters:
+//
+//	a *Analyzer - The analyzer holding the parse tree of the document.
+//	source_code []byte - The source code of the document.
+//
+// Returns:
+//
+//	*SymbolTable - The symbol table of the document.
+func BuildSymbolTable(a *Analyzer, source_code []byte) *SymbolTable {
+	table := NewSymbolTable()
+	if a.ast == nil {
+		return table
+	}
+	routes, err := NewQueryExecutor(_ROUTE_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return table
+	}
+	for {
+		match, ok := routes.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range match.Captures {
+			table.routes = append(table.routes, routeRange{
+				name:       RouteName(capture.Node, source_code),
+				startPoint: capture.Node.StartPoint(),
+				endPoint:   capture.Node.EndPoint(),
+			})
+		}
+	}
+	variables, err := NewQueryExecutor(_VARIABLE_QUERY, a.ast.Node, a.builder.parser.language)
+	if err != nil {
+		log.Error().Err(err).Msg("Error creating query executor")
+		return table
+	}
+	for {
+		match, ok := variables.NextMatch()
+		if !ok {
+			break
+		}
+		for _, capture := range match.Captures {
+			if symbol, ok := newSymbol(capture.Node, source_code); ok {
+				symbol.Route = table.RouteAt(symbol.StartPoint)
+				table.symbols = append(table.symbols, symbol)
+			}
+		}
+	}
+	return table
+}
+
+// newSymbol creates the symbol of a var_, avp_var or dlg_var node.
+func newSymbol(node *sitter.Node, source_code []byte) (Symbol, bool) {
+	var name *sitter.Node
+	switch node.Type() {
+	case XAVPNodeType:
+		// $xavp(root[index]=>field) is named by its root
+		if values := node.ChildByFieldName("name"); values != nil {
+			name = values.ChildByFieldName("name")
+		}
+	case HTableNodeType:
+		// $sht(table=>key) is named by its hash table, the keys are usually computed
+		name = node.ChildByFieldName("htable")
+	default:
+		name = node.ChildByFieldName("name")
+	}
+	if name == nil {
+		return Symbol{}, false
+	}
+	identifier := name.Content(source_code)
+	var symbol Symbol
+	switch node.Type() {
+	case AVPNodeType:
+		// $avp(s:name) and $avp(name) are the same AVP
+		identifier = strings.TrimPrefix(identifier, "s:")
+		symbol = Symbol{Name: _AVP_IDENTIFIER + "(" + identifier + ")", Kind: AVPVariable}
+	case VARNodeType:
+		symbol = Symbol{Name: _VAR_IDENTIFIER + "(" + identifier + ")", Kind: LocalVariable}
+	case DlgVarNodeType:
+		symbol = Symbol{Name: _DLG_VAR_IDENTIFIER + "(" + identifier + ")", Kind: DlgVariable}
+	case XAVPNodeType:
+		symbol = Symbol{Name: _XAVP_IDENTIFIER + "(" + identifier + ")", Kind: XAVPVariable}
+	case HTableNodeType:
+		symbol = Symbol{Name: _SHT_IDENTIFIER + "(" + identifier + ")", Kind: SHTVariable}
+	default:
+		return Symbol{}, false
+	}
+	symbol.Identifier = identifier
+	symbol.IdentifierStartPoint = name.StartPoint()
+	symbol.IdentifierEndPoint = name.EndPoint()
+	if prefix := len(name.Content(source_code)) - len(identifier); prefix > 0 && name.StartPoint().Row == name.EndPoint().Row {
+		symbol.IdentifierStartPoint.Column += uint32(prefix)
+	}
+	// the variable node is wrapped in a pseudo_content, itself wrapped in $name or $(name)
+	variable := node
+	if parent := node.Parent(); parent != nil && parent.Type() == PseudoContentNodeType {
+		if wrapper := parent.Parent(); wrapper != nil &&
+			(wrapper.Type() == PseudoVariableNodeType || wrapper.Type() == PseudoVariableExpressionNodeType) {
+			variable = wrapper
+		}
+	}
+	symbol.StartPoint = variable.StartPoint()
+	symbol.EndPoint = variable.EndPoint()
+	if assignment := variable.Parent(); assignment != nil && assignment.Type() == AssignmentExpressionNodeType {
+		left := assignment.ChildByFieldName("left")
+		if left != nil && left.StartByte() == variable.StartByte() && left.EndByte() == variable.EndByte() {
+			symbol.Definition = true
+			if right := assignment.ChildByFieldName("right"); right != nil {
+				symbol.Value = right.Content(source_code)
+			}
+		}
+	}
+	return symbol, true
+}
+
+// Symbols returns every definition and use recorded in the table, in document order.
+//
+// Returns:
+//
+//	[]Symbol - The symbols of the document.
+func (t *SymbolTable) Symbols() []Symbol {
+	return t.symbols
+}
+
+// RouteAt returns the name of the route block enclosing the given point.
+//
+// Parameters:
+//
+//	point sitter.Point - The point within the document.
+//
+// Returns:
+//
+//	string - The route name, e.g. route[AUTH], or an empty string outside routes.
+func (t *SymbolTable) RouteAt(point sitter.Point) string {
+	for _, route := range t.routes {
+		if !pointBefore(point, route.startPoint) && !pointBefore(route.endPoint, point) {
+			return route.name
+		}
+	}
+	return ""
+}
+
+// SymbolAt returns the symbol at the given point.
+//
+// Parameters:
+//
+//	point sitter.Point - The point within the document.
+//
+// Returns:
+//
+//	*Symbol - The symbol at the point, or nil if there is none.
+func (t *SymbolTable) SymbolAt(point sitter.Point) *Symbol {
+	for i := range t.symbols {
+		if t.symbols[i].Contains(point) {
+			return &t.symbols[i]
+		}
+	}
+	return nil
+}
+
+// Occurrences returns the definitions and uses of the variable of the given symbol.
+//
+// Parameters:
+//
+//	symbol Symbol - A definition or a use of the variable.
+//
+// Returns:
+//
+//	[]Symbol - The definitions and uses of the variable, in document order.
+func (t *SymbolTable) Occurrences(symbol Symbol) []Symbol {
+	var occurrences []Symbol
+	for _, s := range t.symbols {
+		if s.SameVariable(symbol) {
+			occurrences = append(occurrences, s)
+		}
+	}
+	return occurrences
+}
+
+// Definitions returns the definitions of the variable of the given symbol.
+//
+// Parameters:
+//
+//	symbol Symbol - A definition or a use of the variable.
+//
+// Returns:
+//
+//	[]Symbol - The definitions of the variable, in document order.
+func (t *SymbolTable) Definitions(symbol Symbol) []Symbol {
+	var definitions []Symbol
+	for _, s := range t.Occurrences(symbol) {
+		if s.Definition {
+			definitions = append(definitions, s)
+		}
+	}
+	return definitions
+}
+
+// VisibleDefinitions returns the first definition of every variable visible from the given route:
+// the transaction and dialog variables of the document, and the local variables of the route.
+//
+// Parameters:
+//
+//	route string - The name of the route, or an empty string outside routes.
+//
+// Returns:
+//
+//	[]Symbol - One definition per visible variable, in document order.
+func (t *SymbolTable) VisibleDefinitions(route string) []Symbol {
+	var definitions []Symbol
+	seen := make(map[string]bool)
+	for _, s := range t.symbols {
+		if !s.Definition || seen[s.Name] || (s.Kind == LocalVariable && s.Route != route) {
+			continue
+		}
+		seen[s.Name] = true
+		definitions = append(definitions, s)
+	}
+	return definitions
+}
+
+// GetDocs returns the documentation of the variable of the given symbol,
+// listing the values it is assigned in its scope. The transaction and dialog variables
+// are also looked up in the tables of the documents the document shares its configuration with.
+//
+// Parameters:
+//
+//	symbol Symbol - A definition or a use of the variable.
+//	included ...*SymbolTable - The tables of the documents sharing the configuration.
+//
+// Returns:
+//
+//	string - The documentation in markdown.
+func (t *SymbolTable) GetDocs(symbol Symbol, included ...*SymbolTable) string {
+	var header string
+	switch symbol.Kind {
+	case AVPVariable:
+		header = "## User defined AVP\n\n\t" + symbol.Name + "\n\n"
+	case DlgVariable:
+		header = "## User defined Dialog Variable\n\n\t" + symbol.Name + "\n\n"
+	case LocalVariable:
+		header = "## User defined Local Variable\n\n\t" + symbol.Name + "\n\n"
+	case XAVPVariable:
+		header = "## User defined XAVP\n\n\t" + symbol.Name + "\n\n"
+	case SHTVariable:
+		header = "## Hash Table\n\n\t" + symbol.Name + "\n\n"
+	}
+	values := ""
+	for _, definition := range t.Definitions(symbol) {
+		values += "\t" + definition.Value + "\n"
+	}
+	if symbol.Kind != LocalVariable {
+		for _, table := range included {
+			for _, definition := range table.Definitions(symbol) {
+				values += "\t" + definition.Value + "\n"
+			}
+		}
+	}
+	if values == "" {
+		values = "\tNot assigned in this document\n"
+		if len(included) > 0 {
+			values = "\tNot assigned in this configuration\n"
+		}
+	}
+	return header + "### Value\n\n" + values + "\n" + "### Scope\n\n\t" + symbol.Scope() + "\n"
+}
+
+// pointBefore checks whether point a comes before point b.
+func pointBefore(a, b sitter.Point) bool {
+	return a.Row < b.Row || (a.Row == b.Row && a.Column < b.Column)
+}
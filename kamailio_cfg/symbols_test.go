@@ -0,0 +1,77 @@
+package kamailio_cfg_test
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const symbolsSource = `request_route {
+  $var(x) = 1;
+  if ($var(x) == 1) {
+    $avp(s:caller) = $dlg_var(leg);
+  }
+  route(AUTH);
+}
+route[AUTH] {
+  $(var(x)) = $avp(caller);
+}
+`
+
+func buildSymbolTable(source string) *kamailio_cfg.SymbolTable {
+	analyzer := kamailio_cfg.NewAnalyzer()
+	analyzer.Build([]byte(source))
+	return kamailio_cfg.BuildSymbolTable(analyzer, []byte(source))
+}
+
+func TestSymbolTableRecordsDefinitionsAndUses(t *testing.T) {
+	table := buildSymbolTable(symbolsSource)
+	expected := []kamailio_cfg.Symbol{
+		{Name: "$var(x)", Definition: true, Route: "request_route", StartPoint: sitter.Point{Row: 1, Column: 2}},
+		{Name: "$var(x)", Definition: false, Route: "request_route", StartPoint: sitter.Point{Row: 2, Column: 6}},
+		{Name: "$avp(caller)", Definition: true, Route: "request_route", StartPoint: sitter.Point{Row: 3, Column: 4}},
+		{Name: "$dlg_var(leg)", Definition: false, Route: "request_route", StartPoint: sitter.Point{Row: 3, Column: 21}},
+		{Name: "$var(x)", Definition: true, Route: "route[AUTH]", StartPoint: sitter.Point{Row: 8, Column: 2}},
+		{Name: "$avp(caller)", Definition: false, Route: "route[AUTH]", StartPoint: sitter.Point{Row: 8, Column: 14}},
+	}
+	symbols := table.Symbols()
+	if len(symbols) != len(expected) {
+		t.Fatalf("Expected: %d symbols,\ngot: %d", len(expected), len(symbols))
+	}
+	for i, e := range expected {
+		s := symbols[i]
+		if s.Name != e.Name || s.Definition != e.Definition || s.Route != e.Route || s.StartPoint != e.StartPoint {
+			t.Fatalf("Expected: %+v,\ngot: %+v", e, s)
+		}
+	}
+}
+
+func TestSymbolTableScopes(t *testing.T) {
+	table := buildSymbolTable(symbolsSource)
+	use := table.SymbolAt(sitter.Point{Row: 2, Column: 8})
+	if use == nil || use.Name != "$var(x)" {
+		t.Fatalf("Expected: $var(x),\ngot: %+v", use)
+	}
+	// $var is scoped to the route block
+	definitions := table.Definitions(*use)
+	if len(definitions) != 1 || definitions[0].Value != "1" {
+		t.Fatalf("Expected: one definition with value 1,\ngot: %+v", definitions)
+	}
+	// $avp is scoped to the transaction
+	avp := table.SymbolAt(sitter.Point{Row: 8, Column: 16})
+	if avp == nil || len(table.Occurrences(*avp)) != 2 {
+		t.Fatalf("Expected: 2 occurrences of $avp(caller),\ngot: %+v", avp)
+	}
+	visible := table.VisibleDefinitions("route[AUTH]")
+	if len(visible) != 2 || visible[0].Name != "$avp(caller)" || visible[1].Route != "route[AUTH]" {
+		t.Fatalf("Expected: $avp(caller) and the local $var(x),\ngot: %+v", visible)
+	}
+}
+
+func TestSymbolTableForgetsRemovedVariables(t *testing.T) {
+	table := buildSymbolTable("request_route {\n  xlog(\"no variables\");\n}\n")
+	if len(table.Symbols()) != 0 {
+		t.Fatalf("Expected: no symbols,\ngot: %+v", table.Symbols())
+	}
+}
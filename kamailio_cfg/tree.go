@@ -18,6 +18,8 @@ const (
 	VARNodeType                      = "var_"
 	AVPNodeType                      = "avp_var"
 	DlgVarNodeType                   = "dlg_var"
+	XAVPNodeType                     = "xavp_var"
+	HTableNodeType                   = "htable"
 	PseudoContentNodeType            = "pseudo_content"
 	PseudoVariableExpressionNodeType = "pvar_expression"
 	ExpressionNodeType               = "expression"
@@ -26,6 +28,8 @@ const (
 	BinaryExpressionNodeType         = "binary_expression"
 	CaseStatementNodeType            = "case_statement"
 	IFStatementNodeType              = "if_statement"
+	RoutingBlockNodeType             = "routing_block"
+	RouteCallNodeType                = "route_call"
 )
 
 // UpdateTree updates the given parse tree by applying an edit operation.
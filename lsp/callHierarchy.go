@@ -0,0 +1,143 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// CallHierarchyItem represents a function of the call hierarchy, a routing block of the configuration.
+// The range spans the whole routing block, the selection range its name.
+type CallHierarchyItem struct {
+	Name           string      `json:"name"`
+	Kind           SymbolKind  `json:"kind"`
+	Detail         string      `json:"detail,omitempty"`
+	URI            DocumentURI `json:"uri"`
+	Range          Range       `json:"range"`
+	SelectionRange Range       `json:"selectionRange"`
+}
+
+// PrepareCallHierarchyRequest represents a request for the call hierarchy items at a position.
+// It contains the request metadata and the parameters for the prepare call hierarchy request.
+type PrepareCallHierarchyRequest struct {
+	Request
+	Params PrepareCallHierarchyParams `json:"params"`
+}
+
+// PrepareCallHierarchyParams contains the parameters for the PrepareCallHierarchyRequest.
+// It includes the text document position parameters.
+type PrepareCallHierarchyParams struct {
+	TextDocuemntPositionParams
+}
+
+// PrepareCallHierarchyResponse represents the response to a PrepareCallHierarchyRequest.
+// It contains the response metadata and the items at the position, null if there are none.
+type PrepareCallHierarchyResponse struct {
+	Response
+	Result []CallHierarchyItem `json:"result"`
+}
+
+// NewPrepareCallHierarchyResponse creates and returns a new PrepareCallHierarchyResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	items []CallHierarchyItem - The items at the position, none if there is no routing block.
+//
+// Returns:
+//
+//	PrepareCallHierarchyResponse - The initialized response.
+func NewPrepareCallHierarchyResponse(id ID, items []CallHierarchyItem) PrepareCallHierarchyResponse {
+	return PrepareCallHierarchyResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: items,
+	}
+}
+
+// CallHierarchyCallsParams contains the parameters of the incoming and outgoing calls requests,
+// the item returned by the PrepareCallHierarchyRequest.
+type CallHierarchyCallsParams struct {
+	Item CallHierarchyItem `json:"item"`
+}
+
+// CallHierarchyIncomingCallsRequest represents a request for the callers of a call hierarchy item.
+type CallHierarchyIncomingCallsRequest struct {
+	Request
+	Params CallHierarchyCallsParams `json:"params"`
+}
+
+// CallHierarchyIncomingCall represents a caller of a call hierarchy item, with the ranges of its calls.
+type CallHierarchyIncomingCall struct {
+	From       CallHierarchyItem `json:"from"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyIncomingCallsResponse represents the response to a CallHierarchyIncomingCallsRequest.
+type CallHierarchyIncomingCallsResponse struct {
+	Response
+	Result []CallHierarchyIncomingCall `json:"result"`
+}
+
+// NewCallHierarchyIncomingCallsResponse creates and returns a new CallHierarchyIncomingCallsResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	calls []CallHierarchyIncomingCall - The callers of the item.
+//
+// Returns:
+//
+//	CallHierarchyIncomingCallsResponse - The initialized response.
+func NewCallHierarchyIncomingCallsResponse(id ID, calls []CallHierarchyIncomingCall) CallHierarchyIncomingCallsResponse {
+	if calls == nil {
+		calls = []CallHierarchyIncomingCall{}
+	}
+	return CallHierarchyIncomingCallsResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: calls,
+	}
+}
+
+// CallHierarchyOutgoingCallsRequest represents a request for the items called by a call hierarchy item.
+type CallHierarchyOutgoingCallsRequest struct {
+	Request
+	Params CallHierarchyCallsParams `json:"params"`
+}
+
+// CallHierarchyOutgoingCall represents an item called by a call hierarchy item,
+// with the ranges of the calls within the caller.
+type CallHierarchyOutgoingCall struct {
+	To         CallHierarchyItem `json:"to"`
+	FromRanges []Range           `json:"fromRanges"`
+}
+
+// CallHierarchyOutgoingCallsResponse represents the response to a CallHierarchyOutgoingCallsRequest.
+type CallHierarchyOutgoingCallsResponse struct {
+	Response
+	Result []CallHierarchyOutgoingCall `json:"result"`
+}
+
+// NewCallHierarchyOutgoingCallsResponse creates and returns a new CallHierarchyOutgoingCallsResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	calls []CallHierarchyOutgoingCall - The items called by the item.
+//
+// Returns:
+//
+//	CallHierarchyOutgoingCallsResponse - The initialized response.
+func NewCallHierarchyOutgoingCallsResponse(id ID, calls []CallHierarchyOutgoingCall) CallHierarchyOutgoingCallsResponse {
+	if calls == nil {
+		calls = []CallHierarchyOutgoingCall{}
+	}
+	return CallHierarchyOutgoingCallsResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: calls,
+	}
+}
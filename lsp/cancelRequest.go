@@ -0,0 +1,14 @@
+package lsp
+
+// CancelRequestNotification represents a notification sent by the client to cancel a pending request.
+// It contains the notification metadata and the parameters identifying the request.
+type CancelRequestNotification struct {
+	Notification
+	Params CancelParams `json:"params"`
+}
+
+// CancelParams contains the parameters for the CancelRequestNotification.
+// It includes the ID of the request to be cancelled.
+type CancelParams struct {
+	ID ID `json:"id"`
+}
@@ -0,0 +1,20 @@
+package lsp
+
+// RegistrationParams contains the parameters of the 'client/registerCapability' request.
+// It includes the list of capabilities to register.
+type RegistrationParams struct {
+	Registrations []Registration `json:"registrations"`
+}
+
+// Registration represents a capability registered dynamically on the client.
+// The ID can be used to unregister the capability again.
+type Registration struct {
+	ID              string `json:"id"`
+	Method          string `json:"method"`
+	RegisterOptions any    `json:"registerOptions,omitempty"`
+}
+
+// DynamicRegistrationCapability represents a client capability that supports dynamic registration.
+type DynamicRegistrationCapability struct {
+	DynamicRegistration bool `json:"dynamicRegistration"`
+}
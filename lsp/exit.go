@@ -0,0 +1,7 @@
+package lsp
+
+// ExitNotification represents a notification sent by the client asking the server to exit its process.
+// The server should exit with code 0 if a shutdown request has been received before, otherwise with code 1.
+type ExitNotification struct {
+	Notification
+}
@@ -10,9 +10,34 @@ type InitializeRequest struct {
 }
 
 // InitializeRequestParams contains the parameters for the InitializeRequest.
-// It includes information about the client.
+// It includes information about the client and the capabilities of the client.
 type InitializeRequestParams struct {
-	ClientInfo ClientInfo `json:"clientInfo"`
+	ClientInfo   ClientInfo         `json:"clientInfo"`
+	Capabilities ClientCapabilities `json:"capabilities"`
+	Trace        TraceValue         `json:"trace,omitempty"`
+	// The folders of the workspace, RootURI is only used by clients that do not send them.
+	WorkspaceFolders []WorkspaceFolder `json:"workspaceFolders,omitempty"`
+	RootURI          DocumentURI       `json:"rootUri,omitempty"`
+}
+
+// ClientCapabilities represents the capabilities of the client.
+// Only the capabilities the server makes use of are decoded.
+type ClientCapabilities struct {
+	General   GeneralClientCapabilities   `json:"general"`
+	Workspace WorkspaceClientCapabilities `json:"workspace"`
+	Window    WindowClientCapabilities    `json:"window"`
+}
+
+// WorkspaceClientCapabilities represents the workspace capabilities of the client.
+type WorkspaceClientCapabilities struct {
+	DidChangeConfiguration DynamicRegistrationCapability `json:"didChangeConfiguration"`
+	DidChangeWatchedFiles  DynamicRegistrationCapability `json:"didChangeWatchedFiles"`
+}
+
+// GeneralClientCapabilities represents the general capabilities of the client.
+// It includes the position encodings supported by the client, in order of preference.
+type GeneralClientCapabilities struct {
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
 }
 
 // ClientInfo represents information about the client making the request.
@@ -39,13 +64,21 @@ type InitializeResult struct {
 // ServerCapabilities represents the capabilities of the language server.
 // It includes various features supported by the server.
 type ServerCapabilities struct {
-	// TODO: incremental instead of full
-	TextDocumentSync           TextDocumentSyncOptions `json:"textDocumentSync"`
-	HoverProvider              bool                    `json:"hoverProvider"`
-	DefinitionProvider         bool                    `json:"definitionProvider"`
-	DocumentFormattingProvider bool                    `json:"documentFormattingProvider"`
-	CompletionProvider         map[string]any          `json:"completionProvider"`
-	DocumentHighlightProvider  bool                    `json:"documentHighlightProvider"`
+	PositionEncoding           PositionEncodingKind        `json:"positionEncoding"`
+	TextDocumentSync           TextDocumentSyncOptions     `json:"textDocumentSync"`
+	HoverProvider              bool                        `json:"hoverProvider"`
+	DefinitionProvider         bool                        `json:"definitionProvider"`
+	ReferencesProvider         bool                        `json:"referencesProvider"`
+	RenameProvider             RenameOptions               `json:"renameProvider"`
+	DocumentSymbolProvider     bool                        `json:"documentSymbolProvider"`
+	WorkspaceSymbolProvider    bool                        `json:"workspaceSymbolProvider"`
+	CallHierarchyProvider      bool                        `json:"callHierarchyProvider"`
+	FoldingRangeProvider       bool                        `json:"foldingRangeProvider"`
+	SemanticTokensProvider     SemanticTokensOptions       `json:"semanticTokensProvider"`
+	DocumentFormattingProvider bool                        `json:"documentFormattingProvider"`
+	CompletionProvider         map[string]any              `json:"completionProvider"`
+	DocumentHighlightProvider  bool                        `json:"documentHighlightProvider"`
+	Workspace                  WorkspaceServerCapabilities `json:"workspace"`
 	// TODO: Add more capabilities
 	// CodeActionProvider bool `json:"codeActionProvider"`
 }
@@ -62,12 +95,13 @@ type ServerInfo struct {
 //
 // Parameters:
 //
-//	id int - The ID of the response.
+//	id ID - The ID of the response.
+//	encoding PositionEncodingKind - The position encoding negotiated with the client.
 //
 // Returns:
 //
 //	InitializeResponse - The initialized response.
-func NewInitializeResponse(id int) InitializeResponse {
+func NewInitializeResponse(id ID, encoding PositionEncodingKind) InitializeResponse {
 	return InitializeResponse{
 		Response: Response{
 			RPC: "2.0",
@@ -75,18 +109,31 @@ func NewInitializeResponse(id int) InitializeResponse {
 		},
 		Result: InitializeResult{
 			Capabilities: ServerCapabilities{
+				PositionEncoding: encoding,
 				TextDocumentSync: TextDocumentSyncOptions{
 					OpenClose: true,
-					Change:    TEXT_DOCUMENT_SYNC_KIND_FULL,
-					// FIXME: update this to incremental
-					// Change:    TEXT_DOCUMENT_SYNC_KIND_INCREMENTAL,
+					Change:    TEXT_DOCUMENT_SYNC_KIND_INCREMENTAL,
+					Save:      &SaveOptions{IncludeText: false},
 				},
-				HoverProvider:      true,
-				DefinitionProvider: true,
+				HoverProvider:           true,
+				DefinitionProvider:      true,
+				ReferencesProvider:      true,
+				RenameProvider:          RenameOptions{PrepareProvider: true},
+				DocumentSymbolProvider:  true,
+				WorkspaceSymbolProvider: true,
+				CallHierarchyProvider:   true,
+				FoldingRangeProvider:    true,
+				SemanticTokensProvider:  NewSemanticTokensOptions(),
 				// FIXME: Update to a proper formatter
 				DocumentFormattingProvider: true,
 				CompletionProvider:         map[string]any{"resolveProvider": false},
-				DocumentHighlightProvider:  false,
+				DocumentHighlightProvider:  true,
+				Workspace: WorkspaceServerCapabilities{
+					WorkspaceFolders: WorkspaceFoldersServerCapabilities{
+						Supported:           true,
+						ChangeNotifications: true,
+					},
+				},
 			},
 			ServerInfo: ServerInfo{
 				Name:    settings.MY_NAME,
@@ -0,0 +1,74 @@
+package lsp
+
+import (
+	"KamaiZen/settings"
+	"time"
+)
+
+// StatusRequest represents the custom 'kamaizen/status' request, asking the server for its health.
+type StatusRequest struct {
+	Request
+}
+
+// StatusResponse represents the response to the 'kamaizen/status' request.
+type StatusResponse struct {
+	Response
+	Result Status `json:"result"`
+}
+
+// Status reports the health of the server and of the session of the client.
+type Status struct {
+	Version          string                      `json:"version"`
+	Uptime           string                      `json:"uptime"`
+	UptimeSeconds    float64                     `json:"uptimeSeconds"`
+	OpenDocuments    []DocumentURI               `json:"openDocuments"`
+	DiskDocuments    int                         `json:"diskDocuments"` // documents read from disk, included or found in the workspace
+	WorkspaceFolders []DocumentURI               `json:"workspaceFolders"`
+	Index            IndexStatus                 `json:"index"`
+	RecentErrors     []RecentError               `json:"recentErrors"`
+	Methods          map[string]MethodStatistics `json:"methods"`
+}
+
+// IndexStatus reports the size of the documentation index.
+type IndexStatus struct {
+	Modules   int `json:"modules"`
+	Functions int `json:"functions"`
+	Cookbook  int `json:"cookbook"`
+}
+
+// RecentError is an error raised by a handler of the session.
+type RecentError struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Message string    `json:"message"`
+	Panic   bool      `json:"panic"`
+}
+
+// MethodStatistics reports the latency of the handlers of a method, in milliseconds.
+type MethodStatistics struct {
+	Count     int     `json:"count"`
+	Errors    int     `json:"errors"`
+	AverageMs float64 `json:"averageMs"`
+	MaxMs     float64 `json:"maxMs"`
+	LastMs    float64 `json:"lastMs"`
+}
+
+// NewStatusResponse creates and returns a new StatusResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the status request.
+//	status Status - The status of the server.
+//
+// Returns:
+//
+//	StatusResponse - The initialized response.
+func NewStatusResponse(id ID, status Status) StatusResponse {
+	return StatusResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: status,
+	}
+}
@@ -0,0 +1,235 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// LineIndex maps the positions of a text document to byte offsets and tree-sitter points, and back.
+// It records the byte offset at which every line of the text starts, and the encoding
+// in which the characters of the positions are counted.
+type LineIndex struct {
+	text     string
+	lines    []int
+	encoding PositionEncodingKind
+}
+
+// NewLineIndex creates and returns a new LineIndex for the given text.
+//
+// Parameters:
+//
+//	text string - The text to be indexed.
+//	encoding PositionEncodingKind - The encoding of the position characters.
+//
+// Returns:
+//
+//	*LineIndex - The line index of the text.
+func NewLineIndex(text string, encoding PositionEncodingKind) *LineIndex {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return &LineIndex{
+		text:     text,
+		lines:    lines,
+		encoding: encoding,
+	}
+}
+
+// Encoding returns the encoding in which the characters of the positions are counted.
+//
+// Returns:
+//
+//	PositionEncodingKind - The encoding of the position characters.
+func (l *LineIndex) Encoding() PositionEncodingKind {
+	return l.encoding
+}
+
+// Text returns the indexed text.
+//
+// Returns:
+//
+//	string - The text.
+func (l *LineIndex) Text() string {
+	return l.text
+}
+
+// LineCount returns the number of lines of the text.
+//
+// Returns:
+//
+//	int - The number of lines.
+func (l *LineIndex) LineCount() int {
+	return len(l.lines)
+}
+
+// line returns the byte offsets at which the given line starts and ends, line break excluded,
+// be it \n or \r\n.
+func (l *LineIndex) line(line int) (int, int) {
+	start := l.lines[line]
+	end := len(l.text)
+	if line+1 < len(l.lines) {
+		end = l.lines[line+1] - 1
+		if end > start && l.text[end-1] == '\r' {
+			end--
+		}
+	}
+	return start, end
+}
+
+// Offset returns the byte offset of the given position.
+// Positions past the end of a line are clamped to the end of that line,
+// positions past the last line are clamped to the end of the text.
+// Positions pointing inside a character are moved to the start of that character.
+//
+// Parameters:
+//
+//	position Position - The position within the text.
+//
+// Returns:
+//
+//	int - The byte offset of the position.
+func (l *LineIndex) Offset(position Position) int {
+	if position.Line < 0 {
+		return 0
+	}
+	if position.Line >= len(l.lines) {
+		return len(l.text)
+	}
+	start, end := l.line(position.Line)
+	if l.encoding == UTF8 {
+		offset := min(start+max(position.Character, 0), end)
+		for offset > start && offset < end && !utf8.RuneStart(l.text[offset]) {
+			offset--
+		}
+		return offset
+	}
+	offset := start
+	units := 0
+	for offset < end {
+		r, size := utf8.DecodeRuneInString(l.text[offset:end])
+		width := utf16Width(r)
+		if units+width > position.Character {
+			break
+		}
+		units += width
+		offset += size
+	}
+	return offset
+}
+
+// Point returns the tree-sitter point of the given byte offset.
+//
+// Parameters:
+//
+//	offset int - The byte offset within the text.
+//
+// Returns:
+//
+//	sitter.Point - The row and byte column of the offset.
+func (l *LineIndex) Point(offset int) sitter.Point {
+	offset = max(0, min(offset, len(l.text)))
+	line := sort.SearchInts(l.lines, offset+1) - 1
+	return sitter.Point{
+		Row:    uint32(line),
+		Column: uint32(offset - l.lines[line]),
+	}
+}
+
+// PointAt returns the tree-sitter point of the given position.
+//
+// Parameters:
+//
+//	position Position - The position within the text.
+//
+// Returns:
+//
+//	sitter.Point - The row and byte column of the position.
+func (l *LineIndex) PointAt(position Position) sitter.Point {
+	return l.Point(l.Offset(position))
+}
+
+// Position returns the position of the given tree-sitter point.
+// Points past the end of a line are clamped to the end of that line.
+//
+// Parameters:
+//
+//	point sitter.Point - The row and byte column within the text.
+//
+// Returns:
+//
+//	Position - The position of the point, in the encoding of the index.
+func (l *LineIndex) Position(point sitter.Point) Position {
+	if int(point.Row) >= len(l.lines) {
+		point = l.Point(len(l.text))
+	}
+	start, end := l.line(int(point.Row))
+	column := min(start+int(point.Column), end) - start
+	if l.encoding == UTF8 {
+		return Position{Line: int(point.Row), Character: column}
+	}
+	units := 0
+	for _, r := range l.text[start : start+column] {
+		units += utf16Width(r)
+	}
+	return Position{Line: int(point.Row), Character: units}
+}
+
+// Range returns the range between the given tree-sitter points.
+//
+// Parameters:
+//
+//	start sitter.Point - The start of the range.
+//	end sitter.Point - The end of the range.
+//
+// Returns:
+//
+//	Range - The range, in the encoding of the index.
+func (l *LineIndex) Range(start sitter.Point, end sitter.Point) Range {
+	return Range{
+		Start: l.Position(start),
+		End:   l.Position(end),
+	}
+}
+
+// NodeRange returns the range covered by the given tree-sitter node.
+//
+// Parameters:
+//
+//	node *sitter.Node - The node of the parse tree of the text.
+//
+// Returns:
+//
+//	Range - The range of the node, in the encoding of the index.
+func (l *LineIndex) NodeRange(node *sitter.Node) Range {
+	return l.Range(node.StartPoint(), node.EndPoint())
+}
+
+// utf16Width returns the number of UTF-16 code units needed to encode the rune.
+// Invalid bytes are decoded as utf8.RuneError and count as one unit.
+func utf16Width(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// endPoint returns the point reached after inserting text at the given point.
+func endPoint(start sitter.Point, text string) sitter.Point {
+	rows := strings.Count(text, "\n")
+	if rows == 0 {
+		return sitter.Point{
+			Row:    start.Row,
+			Column: start.Column + uint32(len(text)),
+		}
+	}
+	return sitter.Point{
+		Row:    start.Row + uint32(rows),
+		Column: uint32(len(text) - strings.LastIndexByte(text, '\n') - 1),
+	}
+}
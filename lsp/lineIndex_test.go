@@ -0,0 +1,102 @@
+package lsp_test
+
+import (
+	"KamaiZen/lsp"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+const encodedText = "# café 😀\nxlog(\"é😀x\");\n"
+
+func TestUTF16Positions(t *testing.T) {
+	index := lsp.NewLineIndex(encodedText, lsp.UTF16)
+	// 'x' after the emoji: 6 bytes + "é" (2) + emoji (4) in bytes, 6 + 1 + 2 in UTF-16 code units
+	point := sitter.Point{Row: 1, Column: 12}
+	expected := lsp.Position{Line: 1, Character: 9}
+	if actual := index.Position(point); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+	if actual := index.PointAt(expected); actual != point {
+		t.Fatalf("Expected: %+v,\ngot: %+v", point, actual)
+	}
+	// end of the first line
+	point = sitter.Point{Row: 0, Column: 12}
+	expected = lsp.Position{Line: 0, Character: 9}
+	if actual := index.Position(point); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+}
+
+func TestUTF8Positions(t *testing.T) {
+	index := lsp.NewLineIndex(encodedText, lsp.UTF8)
+	point := sitter.Point{Row: 1, Column: 12}
+	expected := lsp.Position{Line: 1, Character: 12}
+	if actual := index.Position(point); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+	if actual := index.PointAt(expected); actual != point {
+		t.Fatalf("Expected: %+v,\ngot: %+v", point, actual)
+	}
+}
+
+func TestUTF16PositionInsideSurrogatePair(t *testing.T) {
+	index := lsp.NewLineIndex(encodedText, lsp.UTF16)
+	// the second code unit of the emoji is moved to the start of the emoji
+	expected := sitter.Point{Row: 1, Column: 8}
+	if actual := index.PointAt(lsp.Position{Line: 1, Character: 8}); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+}
+
+func TestCRLFLineEnd(t *testing.T) {
+	index := lsp.NewLineIndex("xlog(\"é\");\r\nexit;\r\n", lsp.UTF16)
+	// the end of the first line is before the \r
+	expected := sitter.Point{Row: 0, Column: 11}
+	if actual := index.PointAt(lsp.Position{Line: 0, Character: 20}); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+	position := lsp.Position{Line: 0, Character: 10}
+	if actual := index.Position(sitter.Point{Row: 0, Column: 12}); actual != position {
+		t.Fatalf("Expected: %+v,\ngot: %+v", position, actual)
+	}
+	index = lsp.NewLineIndex("exit;\r\nexit;", lsp.UTF8)
+	expected = sitter.Point{Row: 0, Column: 5}
+	if actual := index.PointAt(lsp.Position{Line: 0, Character: 6}); actual != expected {
+		t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+	}
+}
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	cases := map[lsp.PositionEncodingKind][]lsp.PositionEncodingKind{
+		lsp.UTF8:  {lsp.UTF16, lsp.UTF8},
+		lsp.UTF16: {lsp.UTF16, "utf-32"},
+	}
+	for expected, supported := range cases {
+		if actual := lsp.NegotiatePositionEncoding(supported); actual != expected {
+			t.Fatalf("Expected: %s,\ngot: %s", expected, actual)
+		}
+	}
+	if actual := lsp.NegotiatePositionEncoding(nil); actual != lsp.UTF16 {
+		t.Fatalf("Expected: %s,\ngot: %s", lsp.UTF16, actual)
+	}
+}
+
+func TestUTF8PositionInsideCharacter(t *testing.T) {
+	index := lsp.NewLineIndex(encodedText, lsp.UTF8)
+	// the bytes following the first one of "é" and of the emoji are moved to the start of the character
+	for _, test := range []struct {
+		character int
+		expected  uint32
+	}{
+		{7, 6},
+		{9, 8},
+		{11, 8},
+		{12, 12},
+	} {
+		expected := sitter.Point{Row: 1, Column: test.expected}
+		if actual := index.PointAt(lsp.Position{Line: 1, Character: test.character}); actual != expected {
+			t.Fatalf("Expected: %+v,\ngot: %+v", expected, actual)
+		}
+	}
+}
@@ -1,20 +1,138 @@
 package lsp
 
+import (
+	"KamaiZen/settings"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// ID represents the identifier of a JSON-RPC request.
+// The specification allows both numbers and strings as identifiers.
+// An ID without a value is used by notifications, which do not expect a response.
+type ID struct {
+	number   int64
+	text     string
+	isString bool
+	valid    bool
+}
+
+// NewIntID creates and returns a new numeric ID.
+//
+// Parameters:
+//
+//	n int - The numeric value of the ID.
+//
+// Returns:
+//
+//	ID - The initialized ID.
+func NewIntID(n int) ID {
+	return ID{number: int64(n), valid: true}
+}
+
+// NewStringID creates and returns a new string ID.
+//
+// Parameters:
+//
+//	s string - The string value of the ID.
+//
+// Returns:
+//
+//	ID - The initialized ID.
+func NewStringID(s string) ID {
+	return ID{text: s, isString: true, valid: true}
+}
+
+// IsValid reports whether the ID carries a value.
+func (id ID) IsValid() bool {
+	return id.valid
+}
+
+// String returns a printable representation of the ID.
+// String IDs are quoted, so that the numeric ID 1 and the string ID "1" never compare equal.
+func (id ID) String() string {
+	if !id.valid {
+		return "null"
+	}
+	if id.isString {
+		return strconv.Quote(id.text)
+	}
+	return strconv.FormatInt(id.number, 10)
+}
+
+// MarshalJSON encodes the ID as a JSON number, a JSON string or null.
+func (id ID) MarshalJSON() ([]byte, error) {
+	if !id.valid {
+		return []byte("null"), nil
+	}
+	if id.isString {
+		return json.Marshal(id.text)
+	}
+	return []byte(strconv.FormatInt(id.number, 10)), nil
+}
+
+// UnmarshalJSON decodes an ID from a JSON number, a JSON string or null.
+func (id *ID) UnmarshalJSON(data []byte) error {
+	*id = ID{}
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*id = NewStringID(s)
+		return nil
+	}
+	n, err := strconv.ParseInt(string(data), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid request id %s", data)
+	}
+	*id = ID{number: n, valid: true}
+	return nil
+}
+
+// Message represents the envelope shared by every JSON-RPC message.
+// It is used to tell requests, notifications and responses apart before decoding the rest of the message.
+type Message struct {
+	RPC    string `json:"jsonrpc"`
+	ID     ID     `json:"id"`
+	Method string `json:"method"`
+}
+
+// IsRequest reports whether the message is a request, i.e. it has a method and expects a response.
+func (m Message) IsRequest() bool {
+	return m.Method != "" && m.ID.IsValid()
+}
+
+// IsNotification reports whether the message is a notification, i.e. it has a method but no ID.
+func (m Message) IsNotification() bool {
+	return m.Method != "" && !m.ID.IsValid()
+}
+
+// IsResponse reports whether the message is a response to a request sent by the server.
+func (m Message) IsResponse() bool {
+	return m.Method == ""
+}
+
 // Request represents a JSON-RPC request message.
 // It contains the JSON-RPC version, the request ID, and the method to be invoked.
 type Request struct {
 	RPC    string `json:"jsonrpc"`
-	ID     int    `json:"id"`
+	ID     ID     `json:"id"`
 	Method string `json:"method"`
 }
 
 // Response represents a JSON-RPC response message.
-// It contains the JSON-RPC version and the response ID.
+// It contains the JSON-RPC version, the response ID and an optional error.
 type Response struct {
-	RPC string `json:"jsonrpc"`
-	ID  int    `json:"id,omitempty"`
+	RPC   string         `json:"jsonrpc"`
+	ID    ID             `json:"id"`
+	Error *ResponseError `json:"error,omitempty"`
 	// Result
-	// Error
 }
 
 // Notification represents a JSON-RPC notification message.
@@ -24,3 +142,111 @@ type Notification struct {
 	RPC    string `json:"jsonrpc"`
 	Method string `json:"method"`
 }
+
+// ErrorCode represents the code of a JSON-RPC response error.
+type ErrorCode int
+
+const (
+	// Codes defined by JSON-RPC
+	PARSE_ERROR      ErrorCode = -32700
+	INVALID_REQUEST  ErrorCode = -32600
+	METHOD_NOT_FOUND ErrorCode = -32601
+	INVALID_PARAMS   ErrorCode = -32602
+	INTERNAL_ERROR   ErrorCode = -32603
+
+	// Codes defined by the Language Server Protocol
+	SERVER_NOT_INITIALIZED ErrorCode = -32002
+	UNKNOWN_ERROR_CODE     ErrorCode = -32001
+	REQUEST_FAILED         ErrorCode = -32803
+	SERVER_CANCELLED       ErrorCode = -32802
+	CONTENT_MODIFIED       ErrorCode = -32801
+	REQUEST_CANCELLED      ErrorCode = -32800
+)
+
+// ResponseError represents the error object of a JSON-RPC response message.
+// It includes the error code and a short description of the error.
+type ResponseError struct {
+	Code    ErrorCode `json:"code"`
+	Message string    `json:"message"`
+	Data    any       `json:"data,omitempty"`
+}
+
+// Error returns the message of the error, so that a ResponseError can be used as a Go error.
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// NewResponseError creates and returns a new ResponseError.
+//
+// Parameters:
+//
+//	code ErrorCode - The JSON-RPC error code.
+//	message string - A short description of the error.
+//
+// Returns:
+//
+//	*ResponseError - The initialized error.
+func NewResponseError(code ErrorCode, message string) *ResponseError {
+	return &ResponseError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// NewErrorResponse creates and returns a new Response carrying an error.
+//
+// Parameters:
+//
+//	id ID - The ID of the request the error is reported for.
+//	code ErrorCode - The JSON-RPC error code.
+//	message string - A short description of the error.
+//
+// Returns:
+//
+//	Response - The initialized error response.
+func NewErrorResponse(id ID, code ErrorCode, message string) Response {
+	return Response{
+		RPC: settings.RPC_VERSION,
+		ID:  id,
+		Error: &ResponseError{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// RequestMessage represents a request sent by the server to the client.
+// It contains the request metadata and the parameters of the request.
+type RequestMessage struct {
+	Request
+	Params any `json:"params,omitempty"`
+}
+
+// NewRequestMessage creates and returns a new RequestMessage.
+//
+// Parameters:
+//
+//	id ID - The ID of the request.
+//	method string - The method to be invoked on the client.
+//	params any - The parameters of the request.
+//
+// Returns:
+//
+//	RequestMessage - The initialized request.
+func NewRequestMessage(id ID, method string, params any) RequestMessage {
+	return RequestMessage{
+		Request: Request{
+			RPC:    settings.RPC_VERSION,
+			ID:     id,
+			Method: method,
+		},
+		Params: params,
+	}
+}
+
+// ResponseMessage represents a response received from the client to a request sent by the server.
+// The result is kept raw, it is decoded by whoever sent the request.
+type ResponseMessage struct {
+	Response
+	Result json.RawMessage `json:"result,omitempty"`
+}
@@ -0,0 +1,67 @@
+package lsp_test
+
+import (
+	"KamaiZen/lsp"
+	"encoding/json"
+	"testing"
+)
+
+func TestIDRoundTrip(t *testing.T) {
+	for _, raw := range []string{`1`, `"abc"`, `"1"`, `null`} {
+		var id lsp.ID
+		if err := json.Unmarshal([]byte(raw), &id); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		encoded, err := json.Marshal(id)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if string(encoded) != raw {
+			t.Fatalf("Expected: %s,\ngot: %s", raw, encoded)
+		}
+	}
+}
+
+func TestIDsOfDifferentKindsDiffer(t *testing.T) {
+	if lsp.NewIntID(1).String() == lsp.NewStringID("1").String() {
+		t.Fatalf("Expected numeric and string IDs to differ")
+	}
+}
+
+func TestMessageKinds(t *testing.T) {
+	cases := map[string]string{
+		`{"jsonrpc":"2.0","id":"a","method":"textDocument/hover"}`: "request",
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/hover"}`:   "request",
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen"}`:        "notification",
+		`{"jsonrpc":"2.0","id":7,"result":null}`:                   "response",
+	}
+	for raw, expected := range cases {
+		var message lsp.Message
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		var actual string
+		switch {
+		case message.IsRequest():
+			actual = "request"
+		case message.IsNotification():
+			actual = "notification"
+		case message.IsResponse():
+			actual = "response"
+		}
+		if actual != expected {
+			t.Fatalf("Expected: %s,\ngot: %s for %s", expected, actual, raw)
+		}
+	}
+}
+
+func TestErrorResponse(t *testing.T) {
+	expected := `{"jsonrpc":"2.0","id":"x","error":{"code":-32601,"message":"Method not found"}}`
+	encoded, err := json.Marshal(lsp.NewErrorResponse(lsp.NewStringID("x"), lsp.METHOD_NOT_FOUND, "Method not found"))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if string(encoded) != expected {
+		t.Fatalf("Expected: %s,\ngot: %s", expected, encoded)
+	}
+}
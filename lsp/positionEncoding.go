@@ -0,0 +1,32 @@
+package lsp
+
+import "slices"
+
+// PositionEncodingKind is the encoding in which the characters of a Position are counted.
+type PositionEncodingKind string
+
+const (
+	// UTF8 counts characters in bytes, as tree-sitter does.
+	UTF8 PositionEncodingKind = "utf-8"
+	// UTF16 counts characters in UTF-16 code units, this is the LSP default.
+	UTF16 PositionEncodingKind = "utf-16"
+)
+
+// NegotiatePositionEncoding picks the position encoding used with a client
+// among the encodings the client supports.
+// UTF-8 is preferred, as it needs no conversion from the tree-sitter byte columns.
+// UTF-16 is used when the client does not support UTF-8 or does not announce any encoding.
+//
+// Parameters:
+//
+//	supported []PositionEncodingKind - The encodings announced in the client capabilities.
+//
+// Returns:
+//
+//	PositionEncodingKind - The encoding to use.
+func NegotiatePositionEncoding(supported []PositionEncodingKind) PositionEncodingKind {
+	if slices.Contains(supported, UTF8) {
+		return UTF8
+	}
+	return UTF16
+}
@@ -0,0 +1,36 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// ShutdownRequest represents a request sent by the client asking the server to shut down.
+// It contains only the request metadata, the shutdown request has no parameters.
+type ShutdownRequest struct {
+	Request
+}
+
+// ShutdownResponse represents the response to a ShutdownRequest.
+// The result of a shutdown request is always null.
+type ShutdownResponse struct {
+	Response
+	Result *struct{} `json:"result"`
+}
+
+// NewShutdownResponse creates and returns a new ShutdownResponse.
+// It initializes the response with the given ID and a null result.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//
+// Returns:
+//
+//	ShutdownResponse - The initialized response.
+func NewShutdownResponse(id ID) ShutdownResponse {
+	return ShutdownResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: nil,
+	}
+}
@@ -13,7 +13,9 @@ const (
 //
 //	OpenClose bool - Indicates whether the server should be notified when a text document is opened or closed.
 //	Change int - Specifies the type of change notifications (e.g., full or incremental).
+//	Save *SaveOptions - Indicates whether the server should be notified when a text document is saved.
 type TextDocumentSyncOptions struct {
-	OpenClose bool `json:"openClose,omitempty"`
-	Change    int  `json:"change"`
+	OpenClose bool         `json:"openClose,omitempty"`
+	Change    int          `json:"change"`
+	Save      *SaveOptions `json:"save,omitempty"`
 }
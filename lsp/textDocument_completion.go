@@ -65,13 +65,13 @@ type CompletionItem struct {
 //
 // Parameters:
 //
-//	id int - The ID of the response.
+//	id ID - The ID of the response.
 //	items []CompletionItem - The list of completion items.
 //
 // Returns:
 //
 //	CompletionResponse - The initialized response.
-func NewCompletionResponse(id int, items []CompletionItem) CompletionResponse {
+func NewCompletionResponse(id ID, items []CompletionItem) CompletionResponse {
 	return CompletionResponse{
 		Response: Response{
 			RPC: settings.RPC_VERSION,
@@ -1,6 +1,9 @@
 package lsp
 
-import "KamaiZen/settings"
+import (
+	"KamaiZen/settings"
+	"encoding/json"
+)
 
 // DefinitionProviderRequest represents a request for definition information.
 // It contains the request metadata and the parameters for the definition request.
@@ -16,10 +19,25 @@ type DefinitionProviderParams struct {
 }
 
 // DefinitionProviderResponse represents the response to a DefinitionProviderRequest.
-// It contains the response metadata and the location of the definition.
+// It contains the response metadata and the locations of the definition.
 type DefinitionProviderResponse struct {
 	Response
-	Result Location `json:"result"`
+	Result Definition `json:"result"`
+}
+
+// Definition is the result of a DefinitionProviderRequest: null if there is no definition,
+// a Location if there is a single one, and a Location[] if the definition is ambiguous.
+type Definition []Location
+
+// MarshalJSON encodes the definition as null, a Location or a Location[].
+func (d Definition) MarshalJSON() ([]byte, error) {
+	switch len(d) {
+	case 0:
+		return []byte("null"), nil
+	case 1:
+		return json.Marshal(d[0])
+	}
+	return json.Marshal([]Location(d))
 }
 
 // DefinitionProvider represents a provider for definition information.
@@ -30,28 +48,22 @@ type DefinitionProvider struct {
 }
 
 // NewDefinitionProviderResponse creates and returns a new DefinitionProviderResponse.
-// It initializes the response with the given ID and sets the location of the definition.
+// It initializes the response with the given ID and sets the locations of the definition.
 //
 // Parameters:
 //
-//	id int - The ID of the response.
-//	contents string - The contents of the definition.
+//	id ID - The ID of the response.
+//	locations []Location - The locations of the definition, none if it is not found.
 //
 // Returns:
 //
 //	DefinitionProviderResponse - The initialized response.
-func NewDefintionProviderResponse(id int, contents string, uri DocumentURI, start Position, end Position) DefinitionProviderResponse {
+func NewDefinitionProviderResponse(id ID, locations []Location) DefinitionProviderResponse {
 	return DefinitionProviderResponse{
 		Response: Response{
 			RPC: settings.RPC_VERSION,
 			ID:  id,
 		},
-		Result: Location{
-			URI: uri,
-			Range: Range{
-				Start: start,
-				End:   end,
-			},
-		},
+		Result: Definition(locations),
 	}
 }
@@ -1,5 +1,11 @@
 package lsp
 
+import (
+	"slices"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
 // DidChangeTextDocumentNotification represents a notification sent to the server
 // when a text document is changed. It contains the notification metadata and the
 // parameters for the change.
@@ -28,17 +34,75 @@ type TextDocumentContentChangeEvent struct {
 // Parameters:
 //
 //	text string - The original text to be modified.
+//	encoding PositionEncodingKind - The encoding of the range characters.
 //
 // Returns:
 //
 //	string - The modified text after applying the change event.
-func (change TextDocumentContentChangeEvent) Apply(text string) string {
-	if change.Range != nil {
-		start := change.Range.Start
-		end := change.Range.End
-		text = text[:start.Line] + change.Text + text[end.Line:]
-	} else {
-		text = change.Text
-	}
+func (change TextDocumentContentChangeEvent) Apply(text string, encoding PositionEncodingKind) string {
+	text, _ = change.Edit(text, encoding)
 	return text
 }
+
+// Edit applies the content change event to the given text like Apply,
+// and describes the edit so that it can be applied to a tree-sitter tree of the original text.
+//
+// Parameters:
+//
+//	text string - The original text to be modified.
+//	encoding PositionEncodingKind - The encoding of the range characters.
+//
+// Returns:
+//
+//	string - The modified text after applying the change event.
+//	*sitter.EditInput - The edit of the text, or nil if the change event replaces the whole text.
+func (change TextDocumentContentChangeEvent) Edit(text string, encoding PositionEncodingKind) (string, *sitter.EditInput) {
+	index, edit := change.Update(NewLineIndex(text, encoding))
+	return index.text, edit
+}
+
+// Update applies the content change event to the text of the given line index like Edit,
+// and returns the line index of the modified text. The new index is built from the given one
+// rather than by scanning the whole text again, so that the index is kept across the change events
+// of a notification. The given index is left untouched.
+//
+// Parameters:
+//
+//	index *LineIndex - The line index of the original text.
+//
+// Returns:
+//
+//	*LineIndex - The line index of the modified text, in the encoding of the given index.
+//	*sitter.EditInput - The edit of the text, or nil if the change event replaces the whole text.
+func (change TextDocumentContentChangeEvent) Update(index *LineIndex) (*LineIndex, *sitter.EditInput) {
+	if change.Range == nil {
+		return NewLineIndex(change.Text, index.encoding), nil
+	}
+	start := index.Offset(change.Range.Start)
+	end := max(start, index.Offset(change.Range.End))
+	startPoint, oldEndPoint := index.Point(start), index.Point(end)
+	edit := &sitter.EditInput{
+		StartIndex:  uint32(start),
+		OldEndIndex: uint32(end),
+		NewEndIndex: uint32(start + len(change.Text)),
+		StartPoint:  startPoint,
+		OldEndPoint: oldEndPoint,
+		NewEndPoint: endPoint(startPoint, change.Text),
+	}
+	// the lines up to the start of the change are kept, the lines after its end are moved
+	lines := slices.Clone(index.lines[:startPoint.Row+1])
+	for i := 0; i < len(change.Text); i++ {
+		if change.Text[i] == '\n' {
+			lines = append(lines, start+i+1)
+		}
+	}
+	delta := len(change.Text) - (end - start)
+	for _, line := range index.lines[oldEndPoint.Row+1:] {
+		lines = append(lines, line+delta)
+	}
+	return &LineIndex{
+		text:     index.text[:start] + change.Text + index.text[end:],
+		lines:    lines,
+		encoding: index.encoding,
+	}, edit
+}
@@ -0,0 +1,108 @@
+package lsp_test
+
+import (
+	"KamaiZen/lsp"
+	"testing"
+)
+
+func TestApplyRangeChanges(t *testing.T) {
+	text := "request_route {\n  route(AUTH);\n}\n"
+	changes := []lsp.TextDocumentContentChangeEvent{
+		{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 1, Character: 8},
+				End:   lsp.Position{Line: 1, Character: 12},
+			},
+			Text: "RELAY",
+		},
+		{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 2, Character: 1},
+				End:   lsp.Position{Line: 2, Character: 1},
+			},
+			Text: "\nroute[RELAY] {\n}",
+		},
+		{
+			Range: &lsp.Range{
+				Start: lsp.Position{Line: 0, Character: 15},
+				End:   lsp.Position{Line: 1, Character: 2},
+			},
+			Text: "\n\t",
+		},
+	}
+	expected := "request_route {\n\troute(RELAY);\n}\nroute[RELAY] {\n}\n"
+	for _, change := range changes {
+		text = change.Apply(text, lsp.UTF16)
+	}
+	if text != expected {
+		t.Fatalf("Expected: %q,\ngot: %q", expected, text)
+	}
+}
+
+func TestApplyFullChange(t *testing.T) {
+	expected := "route[AUTH] {\n}\n"
+	change := lsp.TextDocumentContentChangeEvent{Text: expected}
+	text, edit := change.Edit("request_route {\n}\n", lsp.UTF16)
+	if text != expected {
+		t.Fatalf("Expected: %q,\ngot: %q", expected, text)
+	}
+	if edit != nil {
+		t.Fatalf("Expected: no edit,\ngot: %+v", *edit)
+	}
+}
+
+func TestEditPoints(t *testing.T) {
+	change := lsp.TextDocumentContentChangeEvent{
+		Range: &lsp.Range{
+			Start: lsp.Position{Line: 1, Character: 2},
+			End:   lsp.Position{Line: 2, Character: 0},
+		},
+		Text: "xlog(\"a\");\n  xlog(\"b\");",
+	}
+	_, edit := change.Edit("a\nbcdef\n}\n", lsp.UTF16)
+	if edit.StartIndex != 4 || edit.OldEndIndex != 8 || edit.NewEndIndex != 27 {
+		t.Fatalf("Expected: 4, 8, 27,\ngot: %d, %d, %d", edit.StartIndex, edit.OldEndIndex, edit.NewEndIndex)
+	}
+	if edit.OldEndPoint.Row != 2 || edit.OldEndPoint.Column != 0 {
+		t.Fatalf("Expected: 2:0,\ngot: %d:%d", edit.OldEndPoint.Row, edit.OldEndPoint.Column)
+	}
+	if edit.NewEndPoint.Row != 2 || edit.NewEndPoint.Column != 12 {
+		t.Fatalf("Expected: 2:12,\ngot: %d:%d", edit.NewEndPoint.Row, edit.NewEndPoint.Column)
+	}
+}
+
+func TestLineIndexClampsPositions(t *testing.T) {
+	index := lsp.NewLineIndex("ab\ncd", lsp.UTF8)
+	cases := map[lsp.Position]int{
+		{Line: 0, Character: 10}: 2,
+		{Line: 1, Character: 1}:  4,
+		{Line: 5, Character: 0}:  5,
+	}
+	for position, expected := range cases {
+		if actual := index.Offset(position); actual != expected {
+			t.Fatalf("Expected: %d,\ngot: %d", expected, actual)
+		}
+	}
+}
+
+func TestUpdateLineIndex(t *testing.T) {
+	index := lsp.NewLineIndex("request_route {\r\n  route(AUTH);\n}\n", lsp.UTF16)
+	for _, change := range []lsp.TextDocumentContentChangeEvent{
+		{Range: &lsp.Range{Start: lsp.Position{Line: 1, Character: 8}, End: lsp.Position{Line: 1, Character: 12}}, Text: "RELAY"},
+		{Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 1}, End: lsp.Position{Line: 2, Character: 1}}, Text: "\nroute[RELAY] {\n}"},
+		{Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 15}, End: lsp.Position{Line: 2, Character: 0}}, Text: "\n\t"},
+		{Range: &lsp.Range{Start: lsp.Position{Line: 3, Character: 0}, End: lsp.Position{Line: 9, Character: 0}}, Text: "😀\n"},
+	} {
+		index, _ = change.Update(index)
+		// the updated index matches an index built from scratch
+		expected := lsp.NewLineIndex(index.Text(), lsp.UTF16)
+		if index.LineCount() != expected.LineCount() {
+			t.Fatalf("Expected: %d lines,\ngot: %d", expected.LineCount(), index.LineCount())
+		}
+		for offset := range len(index.Text()) + 1 {
+			if index.Point(offset) != expected.Point(offset) {
+				t.Fatalf("Expected: %+v at %d of %q,\ngot: %+v", expected.Point(offset), offset, index.Text(), index.Point(offset))
+			}
+		}
+	}
+}
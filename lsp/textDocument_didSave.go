@@ -0,0 +1,26 @@
+package lsp
+
+// DidSaveTextDocumentNotification represents a notification sent to the server
+// when a text document is saved. It contains the notification metadata and the
+// parameters for the save event.
+type DidSaveTextDocumentNotification struct {
+	Notification
+	Params DidSaveTextDocumentParams `json:"params"`
+}
+
+// DidSaveTextDocumentParams contains the parameters for the DidSaveTextDocumentNotification.
+// It includes the identifier of the text document that was saved, and its text if the
+// server asked for it when registering for save notifications.
+type DidSaveTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Text         *string                `json:"text,omitempty"`
+}
+
+// SaveOptions represents the options for save notifications.
+//
+// Fields:
+//
+//	IncludeText bool - Indicates whether the client should include the text content on save.
+type SaveOptions struct {
+	IncludeText bool `json:"includeText"`
+}
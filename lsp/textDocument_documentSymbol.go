@@ -0,0 +1,91 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// DocumentSymbolRequest represents a request for the outline of a document.
+// It contains the request metadata and the parameters for the document symbol request.
+type DocumentSymbolRequest struct {
+	Request
+	Params DocumentSymbolParams `json:"params"`
+}
+
+// DocumentSymbolParams contains the parameters for the DocumentSymbolRequest.
+// It includes the text document identifier.
+type DocumentSymbolParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// DocumentSymbolResponse represents the response to a DocumentSymbolRequest.
+// It contains the response metadata and the symbols of the document.
+type DocumentSymbolResponse struct {
+	Response
+	Result []DocumentSymbol `json:"result"`
+}
+
+// SymbolKind represents the kind of a symbol.
+// It is an enumeration of various kinds of symbols.
+type SymbolKind int
+
+const (
+	FILE_SYMBOL SymbolKind = iota + 1
+	MODULE_SYMBOL
+	NAMESPACE_SYMBOL
+	PACKAGE_SYMBOL
+	CLASS_SYMBOL
+	METHOD_SYMBOL
+	PROPERTY_SYMBOL
+	FIELD_SYMBOL
+	CONSTRUCTOR_SYMBOL
+	ENUM_SYMBOL
+	INTERFACE_SYMBOL
+	FUNCTION_SYMBOL
+	VARIABLE_SYMBOL
+	CONSTANT_SYMBOL
+	STRING_SYMBOL
+	NUMBER_SYMBOL
+	BOOLEAN_SYMBOL
+	ARRAY_SYMBOL
+	OBJECT_SYMBOL
+	KEY_SYMBOL
+	NULL_SYMBOL
+	ENUM_MEMBER_SYMBOL
+	STRUCT_SYMBOL
+	EVENT_SYMBOL
+	OPERATOR_SYMBOL
+	TYPE_PARAMETER_SYMBOL
+)
+
+// DocumentSymbol represents an item of the outline of a document.
+// The range spans the whole item, the selection range its name.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           SymbolKind       `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// NewDocumentSymbolResponse creates and returns a new DocumentSymbolResponse.
+// It initializes the response with the given ID and the symbols of the document.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	symbols []DocumentSymbol - The symbols of the document.
+//
+// Returns:
+//
+//	DocumentSymbolResponse - The initialized response.
+func NewDocumentSymbolResponse(id ID, symbols []DocumentSymbol) DocumentSymbolResponse {
+	if symbols == nil {
+		symbols = []DocumentSymbol{}
+	}
+	return DocumentSymbolResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: symbols,
+	}
+}
@@ -0,0 +1,64 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// FoldingRangeKind is the kind of a folding range, a folding range of code has no kind.
+type FoldingRangeKind string
+
+const (
+	COMMENT_FOLDING_RANGE FoldingRangeKind = "comment"
+	IMPORTS_FOLDING_RANGE FoldingRangeKind = "imports"
+	REGION_FOLDING_RANGE  FoldingRangeKind = "region"
+)
+
+// FoldingRangeRequest represents a request for the folding ranges of a document.
+// It contains the request metadata and the parameters for the folding range request.
+type FoldingRangeRequest struct {
+	Request
+	Params FoldingRangeParams `json:"params"`
+}
+
+// FoldingRangeParams contains the parameters for the FoldingRangeRequest.
+// It includes the text document identifier.
+type FoldingRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// FoldingRange represents a foldable range of lines of a document.
+// The start line stays visible once folded.
+type FoldingRange struct {
+	StartLine uint32           `json:"startLine"`
+	EndLine   uint32           `json:"endLine"`
+	Kind      FoldingRangeKind `json:"kind,omitempty"`
+}
+
+// FoldingRangeResponse represents the response to a FoldingRangeRequest.
+// It contains the response metadata and the folding ranges of the document.
+type FoldingRangeResponse struct {
+	Response
+	Result []FoldingRange `json:"result"`
+}
+
+// NewFoldingRangeResponse creates and returns a new FoldingRangeResponse.
+// It initializes the response with the given ID and the folding ranges of the document.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	ranges []FoldingRange - The folding ranges of the document.
+//
+// Returns:
+//
+//	FoldingRangeResponse - The initialized response.
+func NewFoldingRangeResponse(id ID, ranges []FoldingRange) FoldingRangeResponse {
+	if ranges == nil {
+		ranges = []FoldingRange{}
+	}
+	return FoldingRangeResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: ranges,
+	}
+}
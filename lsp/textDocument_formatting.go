@@ -49,13 +49,13 @@ type TextEdit struct {
 //
 // Parameters:
 //
-//	id int - The ID of the response.
+//	id ID - The ID of the response.
 //	edits []TextEdit - The list of text edits.
 //
 // Returns:
 //
 //	DocumentFormattingResponse - The initialized response.
-func NewDocumentFormattingResponse(id int, edits []TextEdit) DocumentFormattingResponse {
+func NewDocumentFormattingResponse(id ID, edits []TextEdit) DocumentFormattingResponse {
 	return DocumentFormattingResponse{
 		Response: Response{
 			RPC: settings.RPC_VERSION,
@@ -70,6 +70,7 @@ func NewDocumentFormattingResponse(id int, edits []TextEdit) DocumentFormattingR
 //
 // Parameters:
 //
+//	index *LineIndex - The line index of the edited document.
 //	start_node *sitter.Node - The starting node of the edit.
 //	end_node *sitter.Node - The ending node of the edit.
 //	new_text string - The new text to be inserted.
@@ -77,18 +78,9 @@ func NewDocumentFormattingResponse(id int, edits []TextEdit) DocumentFormattingR
 // Returns:
 //
 //	TextEdit - The initialized text edit.
-func NewTextEdit(start_node *sitter.Node, end_node *sitter.Node, new_text string) TextEdit {
+func NewTextEdit(index *LineIndex, start_node *sitter.Node, end_node *sitter.Node, new_text string) TextEdit {
 	return TextEdit{
-		Range: Range{
-			Start: Position{
-				Line:      int(start_node.StartPoint().Row),
-				Character: int(start_node.StartPoint().Column),
-			},
-			End: Position{
-				Line:      int(end_node.EndPoint().Row),
-				Character: int(end_node.EndPoint().Column),
-			},
-		},
+		Range:   index.Range(start_node.StartPoint(), end_node.EndPoint()),
 		NewText: new_text,
 	}
 }
@@ -1,3 +1,58 @@
 package lsp
 
-// TODO: Implement highlights
+import "KamaiZen/settings"
+
+// DocumentHighlightKind represents the kind of an occurrence of a symbol.
+type DocumentHighlightKind int
+
+const (
+	TEXT_HIGHLIGHT  DocumentHighlightKind = iota + 1 // a textual occurrence, e.g. a declaration
+	READ_HIGHLIGHT                                   // a read access, e.g. a route call
+	WRITE_HIGHLIGHT                                  // a write access, e.g. an assignment
+)
+
+// DocumentHighlightRequest represents a request for the occurrences of the symbol at a position.
+// It contains the request metadata and the parameters for the document highlight request.
+type DocumentHighlightRequest struct {
+	Request
+	Params DocumentHighlightParams `json:"params"`
+}
+
+// DocumentHighlightParams contains the parameters for the DocumentHighlightRequest.
+// It includes the text document position parameters.
+type DocumentHighlightParams struct {
+	TextDocuemntPositionParams
+}
+
+// DocumentHighlight represents an occurrence of a symbol in a document.
+type DocumentHighlight struct {
+	Range Range                 `json:"range"`
+	Kind  DocumentHighlightKind `json:"kind"`
+}
+
+// DocumentHighlightResponse represents the response to a DocumentHighlightRequest.
+// It contains the response metadata and the occurrences, null if there is no symbol at the position.
+type DocumentHighlightResponse struct {
+	Response
+	Result []DocumentHighlight `json:"result"`
+}
+
+// NewDocumentHighlightResponse creates and returns a new DocumentHighlightResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	highlights []DocumentHighlight - The occurrences of the symbol at the position.
+//
+// Returns:
+//
+//	DocumentHighlightResponse - The initialized response.
+func NewDocumentHighlightResponse(id ID, highlights []DocumentHighlight) DocumentHighlightResponse {
+	return DocumentHighlightResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: highlights,
+	}
+}
@@ -34,13 +34,13 @@ type Hover struct {
 //
 // Parameters:
 //
-//	id int - The ID of the response.
+//	id ID - The ID of the response.
 //	contents string - The contents of the hover.
 //
 // Returns:
 //
 //	HoverResponse - The initialized response.
-func NewHoverResponse(id int, contents string) HoverResponse {
+func NewHoverResponse(id ID, contents string) HoverResponse {
 	return HoverResponse{
 		Response: Response{
 			RPC: settings.RPC_VERSION,
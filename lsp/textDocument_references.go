@@ -0,0 +1,50 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// ReferencesRequest represents a request for the references to the name at a position.
+// It contains the request metadata and the parameters for the references request.
+type ReferencesRequest struct {
+	Request
+	Params ReferenceParams `json:"params"`
+}
+
+// ReferenceParams contains the parameters for the ReferencesRequest.
+// It includes the text document position parameters and the reference context.
+type ReferenceParams struct {
+	TextDocuemntPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+// ReferenceContext tells whether the declaration of the name is returned along with its uses.
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+// ReferencesResponse represents the response to a ReferencesRequest.
+// It contains the response metadata and the locations of the references, null if there are none.
+type ReferencesResponse struct {
+	Response
+	Result []Location `json:"result"`
+}
+
+// NewReferencesResponse creates and returns a new ReferencesResponse.
+// It initializes the response with the given ID and sets the locations of the references.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	locations []Location - The locations of the references, none if there is no name at the position.
+//
+// Returns:
+//
+//	ReferencesResponse - The initialized response.
+func NewReferencesResponse(id ID, locations []Location) ReferencesResponse {
+	return ReferencesResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: locations,
+	}
+}
@@ -0,0 +1,102 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// RenameOptions represents the rename capabilities of the server.
+type RenameOptions struct {
+	PrepareProvider bool `json:"prepareProvider"`
+}
+
+// PrepareRenameRequest represents a request checking whether the name at a position can be renamed.
+// It contains the request metadata and the parameters for the prepare rename request.
+type PrepareRenameRequest struct {
+	Request
+	Params PrepareRenameParams `json:"params"`
+}
+
+// PrepareRenameParams contains the parameters for the PrepareRenameRequest.
+// It includes the text document position parameters.
+type PrepareRenameParams struct {
+	TextDocuemntPositionParams
+}
+
+// PrepareRenameResponse represents the response to a PrepareRenameRequest.
+// It contains the response metadata and the range of the name to rename.
+type PrepareRenameResponse struct {
+	Response
+	Result *PrepareRenameResult `json:"result"`
+}
+
+// PrepareRenameResult represents the name to rename, with its range and the text the client
+// offers as the new name.
+type PrepareRenameResult struct {
+	Range       Range  `json:"range"`
+	Placeholder string `json:"placeholder"`
+}
+
+// NewPrepareRenameResponse creates and returns a new PrepareRenameResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	rng Range - The range of the name to rename.
+//	placeholder string - The current name.
+//
+// Returns:
+//
+//	PrepareRenameResponse - The initialized response.
+func NewPrepareRenameResponse(id ID, rng Range, placeholder string) PrepareRenameResponse {
+	return PrepareRenameResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: &PrepareRenameResult{Range: rng, Placeholder: placeholder},
+	}
+}
+
+// RenameRequest represents a request to rename the name at a position.
+// It contains the request metadata and the parameters for the rename request.
+type RenameRequest struct {
+	Request
+	Params RenameParams `json:"params"`
+}
+
+// RenameParams contains the parameters for the RenameRequest.
+// It includes the text document position parameters and the new name.
+type RenameParams struct {
+	TextDocuemntPositionParams
+	NewName string `json:"newName"`
+}
+
+// RenameResponse represents the response to a RenameRequest.
+// It contains the response metadata and the edits renaming the name.
+type RenameResponse struct {
+	Response
+	Result *WorkspaceEdit `json:"result"`
+}
+
+// WorkspaceEdit represents changes to several documents, by document URI.
+type WorkspaceEdit struct {
+	Changes map[DocumentURI][]TextEdit `json:"changes"`
+}
+
+// NewRenameResponse creates and returns a new RenameResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	changes map[DocumentURI][]TextEdit - The edits of every document using the name.
+//
+// Returns:
+//
+//	RenameResponse - The initialized response.
+func NewRenameResponse(id ID, changes map[DocumentURI][]TextEdit) RenameResponse {
+	return RenameResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: &WorkspaceEdit{Changes: changes},
+	}
+}
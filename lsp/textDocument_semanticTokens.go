@@ -0,0 +1,213 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// SemanticTokenType represents the type of a semantic token, its index in the legend of the server.
+type SemanticTokenType uint32
+
+const (
+	NAMESPACE_TOKEN SemanticTokenType = iota
+	PARAMETER_TOKEN
+	VARIABLE_TOKEN
+	PROPERTY_TOKEN
+	FUNCTION_TOKEN
+	MACRO_TOKEN
+	KEYWORD_TOKEN
+	COMMENT_TOKEN
+	STRING_TOKEN
+	NUMBER_TOKEN
+	REGEXP_TOKEN
+	OPERATOR_TOKEN
+)
+
+// SemanticTokenModifiers represents a set of modifiers of a semantic token, a bit per modifier of the legend.
+type SemanticTokenModifiers uint32
+
+const (
+	READONLY_MODIFIER SemanticTokenModifiers = 1 << iota
+	DEPRECATED_MODIFIER
+	DEFAULT_LIBRARY_MODIFIER
+)
+
+// SemanticTokensLegend represents the names of the token types and modifiers, in the order of their values.
+type SemanticTokensLegend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// SemanticTokensOptions represents the semantic tokens capabilities of the server.
+type SemanticTokensOptions struct {
+	Legend SemanticTokensLegend      `json:"legend"`
+	Range  bool                      `json:"range"`
+	Full   SemanticTokensFullOptions `json:"full"`
+}
+
+// SemanticTokensFullOptions represents whether the server answers semantic tokens delta requests.
+type SemanticTokensFullOptions struct {
+	Delta bool `json:"delta"`
+}
+
+// NewSemanticTokensOptions creates and returns the semantic tokens capabilities of the server,
+// with the legend of the token types and modifiers.
+//
+// Returns:
+//
+//	SemanticTokensOptions - The semantic tokens capabilities.
+func NewSemanticTokensOptions() SemanticTokensOptions {
+	return SemanticTokensOptions{
+		Legend: SemanticTokensLegend{
+			TokenTypes: []string{
+				"namespace", "parameter", "variable", "property", "function", "macro",
+				"keyword", "comment", "string", "number", "regexp", "operator",
+			},
+			TokenModifiers: []string{"readonly", "deprecated", "defaultLibrary"},
+		},
+		Range: true,
+		Full:  SemanticTokensFullOptions{Delta: true},
+	}
+}
+
+// SemanticTokensRequest represents a request for the semantic tokens of a document.
+// It contains the request metadata and the parameters for the semantic tokens request.
+type SemanticTokensRequest struct {
+	Request
+	Params SemanticTokensParams `json:"params"`
+}
+
+// SemanticTokensParams contains the parameters for the SemanticTokensRequest.
+// It includes the text document identifier.
+type SemanticTokensParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// SemanticTokensDeltaRequest represents a request for the changes of the semantic tokens of a document
+// since a previous result.
+type SemanticTokensDeltaRequest struct {
+	Request
+	Params SemanticTokensDeltaParams `json:"params"`
+}
+
+// SemanticTokensDeltaParams contains the parameters for the SemanticTokensDeltaRequest.
+// It includes the text document identifier and the ID of the previous result.
+type SemanticTokensDeltaParams struct {
+	TextDocument     TextDocumentIdentifier `json:"textDocument"`
+	PreviousResultID string                 `json:"previousResultId"`
+}
+
+// SemanticTokensRangeRequest represents a request for the semantic tokens of a range of a document.
+type SemanticTokensRangeRequest struct {
+	Request
+	Params SemanticTokensRangeParams `json:"params"`
+}
+
+// SemanticTokensRangeParams contains the parameters for the SemanticTokensRangeRequest.
+// It includes the text document identifier and the range.
+type SemanticTokensRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// SemanticTokens represents the semantic tokens of a document. Every token is encoded as five integers:
+// its line and start character relative to the previous token, its length, its type and its modifiers.
+type SemanticTokens struct {
+	ResultID string   `json:"resultId,omitempty"`
+	Data     []uint32 `json:"data"`
+}
+
+// SemanticTokensEdit represents a change of the data of semantic tokens.
+type SemanticTokensEdit struct {
+	Start       uint32   `json:"start"`
+	DeleteCount uint32   `json:"deleteCount"`
+	Data        []uint32 `json:"data"`
+}
+
+// SemanticTokensDelta represents the changes of the semantic tokens of a document since a previous result.
+type SemanticTokensDelta struct {
+	ResultID string               `json:"resultId,omitempty"`
+	Edits    []SemanticTokensEdit `json:"edits"`
+}
+
+// SemanticTokensResponse represents the response to a SemanticTokensRequest or a SemanticTokensRangeRequest.
+// It contains the response metadata and the semantic tokens.
+type SemanticTokensResponse struct {
+	Response
+	Result SemanticTokens `json:"result"`
+}
+
+// NewSemanticTokensResponse creates and returns a new SemanticTokensResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	tokens SemanticTokens - The semantic tokens.
+//
+// Returns:
+//
+//	SemanticTokensResponse - The initialized response.
+func NewSemanticTokensResponse(id ID, tokens SemanticTokens) SemanticTokensResponse {
+	if tokens.Data == nil {
+		tokens.Data = []uint32{}
+	}
+	return SemanticTokensResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: tokens,
+	}
+}
+
+// SemanticTokensDeltaResponse represents the response to a SemanticTokensDeltaRequest.
+// Its result is either the SemanticTokensDelta since the previous result, or all the SemanticTokens
+// when the previous result is not known.
+type SemanticTokensDeltaResponse struct {
+	Response
+	Result any `json:"result"`
+}
+
+// NewSemanticTokensDeltaResponse creates and returns a new SemanticTokensDeltaResponse.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	delta SemanticTokensDelta - The changes of the semantic tokens.
+//
+// Returns:
+//
+//	SemanticTokensDeltaResponse - The initialized response.
+func NewSemanticTokensDeltaResponse(id ID, delta SemanticTokensDelta) SemanticTokensDeltaResponse {
+	if delta.Edits == nil {
+		delta.Edits = []SemanticTokensEdit{}
+	}
+	return SemanticTokensDeltaResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: delta,
+	}
+}
+
+// NewSemanticTokensFullDeltaResponse creates and returns a new SemanticTokensDeltaResponse
+// with all the semantic tokens, when the previous result is not known.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	tokens SemanticTokens - The semantic tokens.
+//
+// Returns:
+//
+//	SemanticTokensDeltaResponse - The initialized response.
+func NewSemanticTokensFullDeltaResponse(id ID, tokens SemanticTokens) SemanticTokensDeltaResponse {
+	if tokens.Data == nil {
+		tokens.Data = []uint32{}
+	}
+	return SemanticTokensDeltaResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: tokens,
+	}
+}
@@ -0,0 +1,59 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// TraceValue is the level of the execution trace the server sends to the client with '$/logTrace'.
+type TraceValue string
+
+const (
+	TRACE_OFF      TraceValue = "off"
+	TRACE_MESSAGES TraceValue = "messages"
+	TRACE_VERBOSE  TraceValue = "verbose"
+)
+
+// SetTraceNotification represents a notification sent by the client to change the trace level.
+type SetTraceNotification struct {
+	Notification
+	Params SetTraceParams `json:"params"`
+}
+
+// SetTraceParams contains the parameters for the SetTraceNotification.
+type SetTraceParams struct {
+	Value TraceValue `json:"value"`
+}
+
+// LogTraceNotification represents a notification sent by the server to log its execution trace.
+type LogTraceNotification struct {
+	Notification
+	Params LogTraceParams `json:"params"`
+}
+
+// LogTraceParams contains the parameters for the LogTraceNotification.
+// Verbose is only set if the trace level is verbose.
+type LogTraceParams struct {
+	Message string `json:"message"`
+	Verbose string `json:"verbose,omitempty"`
+}
+
+// NewLogTraceNotification creates and returns a new LogTraceNotification.
+//
+// Parameters:
+//
+//	message string - The message to be logged.
+//	verbose string - The additional information, only sent at the verbose level.
+//
+// Returns:
+//
+//	LogTraceNotification - The initialized notification.
+func NewLogTraceNotification(message string, verbose string) LogTraceNotification {
+	return LogTraceNotification{
+		Notification: Notification{
+			Method: "$/logTrace",
+			RPC:    settings.RPC_VERSION,
+		},
+		Params: LogTraceParams{
+			Message: message,
+			Verbose: verbose,
+		},
+	}
+}
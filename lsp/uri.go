@@ -0,0 +1,44 @@
+package lsp
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+)
+
+// Path returns the file system path of a file URI.
+//
+// Returns:
+//
+//	string - The path of the file.
+//	error - An error if the URI is not a valid file URI.
+func (uri DocumentURI) Path() (string, error) {
+	u, err := url.Parse(string(uri))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("not a file URI: %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+// PathToURI returns the file URI of a file system path.
+//
+// Parameters:
+//
+//	path string - The path of the file, made absolute if it is relative.
+//
+// Returns:
+//
+//	DocumentURI - The URI of the file.
+func PathToURI(path string) DocumentURI {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	u := url.URL{
+		Scheme: "file",
+		Path:   filepath.ToSlash(path),
+	}
+	return DocumentURI(u.String())
+}
@@ -0,0 +1,147 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// MessageType is the type of a message shown or logged by the client.
+type MessageType int
+
+const (
+	MESSAGE_ERROR   MessageType = 1
+	MESSAGE_WARNING MessageType = 2
+	MESSAGE_INFO    MessageType = 3
+	MESSAGE_LOG     MessageType = 4
+)
+
+// WindowClientCapabilities represents the window capabilities of the client.
+type WindowClientCapabilities struct {
+	WorkDoneProgress bool `json:"workDoneProgress"`
+}
+
+// ShowMessageNotification represents a notification sent by the server to ask the client
+// to show a message to the user.
+type ShowMessageNotification struct {
+	Notification
+	Params MessageParams `json:"params"`
+}
+
+// LogMessageNotification represents a notification sent by the server to ask the client to log a message.
+type LogMessageNotification struct {
+	Notification
+	Params MessageParams `json:"params"`
+}
+
+// MessageParams contains the parameters for the ShowMessageNotification and the LogMessageNotification.
+type MessageParams struct {
+	Type    MessageType `json:"type"`
+	Message string      `json:"message"`
+}
+
+// NewShowMessageNotification creates and returns a new ShowMessageNotification.
+//
+// Parameters:
+//
+//	messageType MessageType - The type of the message.
+//	message string - The message to show.
+//
+// Returns:
+//
+//	ShowMessageNotification - The initialized notification.
+func NewShowMessageNotification(messageType MessageType, message string) ShowMessageNotification {
+	return ShowMessageNotification{
+		Notification: Notification{
+			Method: "window/showMessage",
+			RPC:    settings.RPC_VERSION,
+		},
+		Params: MessageParams{
+			Type:    messageType,
+			Message: message,
+		},
+	}
+}
+
+// NewLogMessageNotification creates and returns a new LogMessageNotification.
+//
+// Parameters:
+//
+//	messageType MessageType - The type of the message.
+//	message string - The message to log.
+//
+// Returns:
+//
+//	LogMessageNotification - The initialized notification.
+func NewLogMessageNotification(messageType MessageType, message string) LogMessageNotification {
+	return LogMessageNotification{
+		Notification: Notification{
+			Method: "window/logMessage",
+			RPC:    settings.RPC_VERSION,
+		},
+		Params: MessageParams{
+			Type:    messageType,
+			Message: message,
+		},
+	}
+}
+
+// WorkDoneProgressCreateParams contains the parameters for the 'window/workDoneProgress/create' request,
+// which asks the client to create a progress identified by the token.
+type WorkDoneProgressCreateParams struct {
+	Token string `json:"token"`
+}
+
+// ProgressNotification represents a '$/progress' notification reporting the progress of a work.
+type ProgressNotification struct {
+	Notification
+	Params ProgressParams `json:"params"`
+}
+
+// ProgressParams contains the parameters for the ProgressNotification.
+// Value is a WorkDoneProgressBegin, WorkDoneProgressReport or WorkDoneProgressEnd.
+type ProgressParams struct {
+	Token string `json:"token"`
+	Value any    `json:"value"`
+}
+
+// WorkDoneProgressBegin starts the progress of a work.
+type WorkDoneProgressBegin struct {
+	Kind        string `json:"kind"`
+	Title       string `json:"title"`
+	Cancellable bool   `json:"cancellable"`
+	Message     string `json:"message,omitempty"`
+	Percentage  *int   `json:"percentage,omitempty"`
+}
+
+// WorkDoneProgressReport reports the progress of a work.
+type WorkDoneProgressReport struct {
+	Kind       string `json:"kind"`
+	Message    string `json:"message,omitempty"`
+	Percentage *int   `json:"percentage,omitempty"`
+}
+
+// WorkDoneProgressEnd ends the progress of a work.
+type WorkDoneProgressEnd struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message,omitempty"`
+}
+
+// NewProgressNotification creates and returns a new ProgressNotification.
+//
+// Parameters:
+//
+//	token string - The token of the progress, as created by the client.
+//	value any - The WorkDoneProgressBegin, WorkDoneProgressReport or WorkDoneProgressEnd to report.
+//
+// Returns:
+//
+//	ProgressNotification - The initialized notification.
+func NewProgressNotification(token string, value any) ProgressNotification {
+	return ProgressNotification{
+		Notification: Notification{
+			Method: "$/progress",
+			RPC:    settings.RPC_VERSION,
+		},
+		Params: ProgressParams{
+			Token: token,
+			Value: value,
+		},
+	}
+}
@@ -19,10 +19,11 @@ type ConfigurationResponse struct {
 }
 
 type ConfigurationObject struct {
-	KamailioSourcePath          string `json:"kamailioSourcePath"`
-	Loglevel                    int    `json:"logLevel"`
-	EnableDeprecatedCommentHint bool   `json:"enableDeprecatedCommentHint"`
-	EnableDiagnostics           bool   `json:"enableDiagnostics"`
+	KamailioSourcePath          string   `json:"kamailioSourcePath"`
+	Loglevel                    int      `json:"logLevel"`
+	EnableDeprecatedCommentHint bool     `json:"enableDeprecatedCommentHint"`
+	EnableDiagnostics           bool     `json:"enableDiagnostics"`
+	ConfigGlobs                 []string `json:"configGlobs"`
 }
 
 type ConfigurationItemValue struct {
@@ -47,13 +48,3 @@ type WorkspaceConfigurationResponse struct {
 	Response
 	Result []ConfigurationObject `json:"result"`
 }
-
-func NewWorkspaceConfigurationRequest(id int, params ConfigurationParams) WorkspaceConfigurationRequest {
-	return WorkspaceConfigurationRequest{
-		Request: Request{
-			ID:     id,
-			Method: "workspace/configuration",
-		},
-		Params: params,
-	}
-}
@@ -0,0 +1,23 @@
+package lsp
+
+import "encoding/json"
+
+// DidChangeConfigurationNotification represents a notification sent to the server
+// when the configuration of the client changes.
+type DidChangeConfigurationNotification struct {
+	Notification
+	Params DidChangeConfigurationParams `json:"params"`
+}
+
+// DidChangeConfigurationParams contains the parameters for the DidChangeConfigurationNotification.
+// The settings pushed by the client are ignored, the server pulls the configuration
+// with a 'workspace/configuration' request instead.
+type DidChangeConfigurationParams struct {
+	Settings json.RawMessage `json:"settings,omitempty"`
+}
+
+// DidChangeConfigurationRegistrationOptions represents the options of the dynamic registration
+// of the 'workspace/didChangeConfiguration' notification.
+type DidChangeConfigurationRegistrationOptions struct {
+	Section string `json:"section,omitempty"`
+}
@@ -0,0 +1,41 @@
+package lsp
+
+// FileChangeType represents the kind of change of a watched file.
+type FileChangeType int
+
+const (
+	FILE_CREATED FileChangeType = iota + 1
+	FILE_CHANGED
+	FILE_DELETED
+)
+
+// DidChangeWatchedFilesNotification represents a notification sent to the server
+// when files watched by the client change on disk.
+type DidChangeWatchedFilesNotification struct {
+	Notification
+	Params DidChangeWatchedFilesParams `json:"params"`
+}
+
+// DidChangeWatchedFilesParams contains the parameters for the DidChangeWatchedFilesNotification.
+// It includes the list of file events.
+type DidChangeWatchedFilesParams struct {
+	Changes []FileEvent `json:"changes"`
+}
+
+// FileEvent represents a change of a watched file.
+type FileEvent struct {
+	URI  DocumentURI    `json:"uri"`
+	Type FileChangeType `json:"type"`
+}
+
+// DidChangeWatchedFilesRegistrationOptions represents the options of a 'workspace/didChangeWatchedFiles' registration.
+// It includes the watchers describing the files the client should watch.
+type DidChangeWatchedFilesRegistrationOptions struct {
+	Watchers []FileSystemWatcher `json:"watchers"`
+}
+
+// FileSystemWatcher represents a glob pattern of files the client should watch.
+// All kinds of changes are watched when Kind is omitted.
+type FileSystemWatcher struct {
+	GlobPattern string `json:"globPattern"`
+}
@@ -0,0 +1,36 @@
+package lsp
+
+// WorkspaceFolder represents a folder of the workspace open in the client.
+type WorkspaceFolder struct {
+	URI  DocumentURI `json:"uri"`
+	Name string      `json:"name"`
+}
+
+// WorkspaceFoldersServerCapabilities represents the support of the server for workspace folders.
+type WorkspaceFoldersServerCapabilities struct {
+	Supported           bool `json:"supported"`
+	ChangeNotifications bool `json:"changeNotifications"`
+}
+
+// WorkspaceServerCapabilities represents the workspace capabilities of the server.
+type WorkspaceServerCapabilities struct {
+	WorkspaceFolders WorkspaceFoldersServerCapabilities `json:"workspaceFolders"`
+}
+
+// DidChangeWorkspaceFoldersNotification represents a notification sent to the server
+// when folders are added to or removed from the workspace.
+type DidChangeWorkspaceFoldersNotification struct {
+	Notification
+	Params DidChangeWorkspaceFoldersParams `json:"params"`
+}
+
+// DidChangeWorkspaceFoldersParams contains the parameters for the DidChangeWorkspaceFoldersNotification.
+type DidChangeWorkspaceFoldersParams struct {
+	Event WorkspaceFoldersChangeEvent `json:"event"`
+}
+
+// WorkspaceFoldersChangeEvent represents the folders added to and removed from the workspace.
+type WorkspaceFoldersChangeEvent struct {
+	Added   []WorkspaceFolder `json:"added"`
+	Removed []WorkspaceFolder `json:"removed"`
+}
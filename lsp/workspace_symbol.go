@@ -0,0 +1,55 @@
+package lsp
+
+import "KamaiZen/settings"
+
+// WorkspaceSymbolRequest represents a request for the symbols of the workspace matching a query.
+// It contains the request metadata and the parameters for the workspace symbol request.
+type WorkspaceSymbolRequest struct {
+	Request
+	Params WorkspaceSymbolParams `json:"params"`
+}
+
+// WorkspaceSymbolParams contains the parameters for the WorkspaceSymbolRequest.
+// It includes the query, matched fuzzily against the names of the symbols.
+type WorkspaceSymbolParams struct {
+	Query string `json:"query"`
+}
+
+// WorkspaceSymbolResponse represents the response to a WorkspaceSymbolRequest.
+// It contains the response metadata and the matching symbols, best matches first.
+type WorkspaceSymbolResponse struct {
+	Response
+	Result []SymbolInformation `json:"result"`
+}
+
+// SymbolInformation represents a symbol of the workspace, with its location.
+type SymbolInformation struct {
+	Name          string     `json:"name"`
+	Kind          SymbolKind `json:"kind"`
+	Location      Location   `json:"location"`
+	ContainerName string     `json:"containerName,omitempty"`
+}
+
+// NewWorkspaceSymbolResponse creates and returns a new WorkspaceSymbolResponse.
+// It initializes the response with the given ID and the matching symbols.
+//
+// Parameters:
+//
+//	id ID - The ID of the response.
+//	symbols []SymbolInformation - The matching symbols.
+//
+// Returns:
+//
+//	WorkspaceSymbolResponse - The initialized response.
+func NewWorkspaceSymbolResponse(id ID, symbols []SymbolInformation) WorkspaceSymbolResponse {
+	if symbols == nil {
+		symbols = []SymbolInformation{}
+	}
+	return WorkspaceSymbolResponse{
+		Response: Response{
+			RPC: settings.RPC_VERSION,
+			ID:  id,
+		},
+		Result: symbols,
+	}
+}
@@ -4,53 +4,99 @@ import (
 	"KamaiZen/rpc"
 	"github.com/rs/zerolog/log"
 	"io"
-	"os"
 	"sync"
 )
 
-var writer io.Writer
-
 const buffered_channel_size = 24
 
-var writer_channel = make(chan []byte, buffered_channel_size)
+// Writer writes the messages sent to a client connection.
+// Messages are queued on a channel and written, in order, by a single goroutine.
+type Writer struct {
+	out      io.Writer
+	messages chan []byte
+	stop     chan struct{} // closed to ask the writer goroutine to flush and return
+}
+
+// NewWriter creates and returns a new Writer writing to the given output.
+// The messages are only written once Start is called.
+//
+// Parameters:
+//
+//	out io.Writer - The output of the client connection.
+//
+// Returns:
+//
+//	*Writer - The initialized writer.
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{
+		out:      out,
+		messages: make(chan []byte, buffered_channel_size),
+		stop:     make(chan struct{}),
+	}
+}
 
 // WriteResponse encodes the given response and sends it to the writer channel.
+// The response is dropped once the writer is stopped.
 //
 // Parameters:
 //
 //	response interface{} - The response to be encoded and written.
-func WriteResponse(response interface{}) {
+func (w *Writer) WriteResponse(response interface{}) {
 	reply := rpc.EncodeMessage(response)
-	writer_channel <- []byte(reply)
+	select {
+	case w.messages <- []byte(reply):
+	case <-w.stop:
+		log.Debug().Msg("Writer stopped, dropping message")
+	}
 }
 
-// Write writes the given message to the writer.
+// Write writes the given message to the output.
 //
 // Parameters:
 //
 //	message []byte - The message to be written.
-func Write(message []byte) {
-	writer.Write(message)
+func (w *Writer) Write(message []byte) {
+	if _, err := w.out.Write(message); err != nil {
+		log.Error().Err(err).Msg("Error writing message")
+	}
 }
 
 // Start starts the writer goroutine that listens for messages on the writer channel
-// and writes them to the writer. It signals the wait group when done.
+// and writes them to the output. Once Stop is called, the pending messages are flushed
+// and the wait group is signalled.
 //
 // Parameters:
 //
 //	wg *sync.WaitGroup - The wait group to signal when done.
-func Start(wg *sync.WaitGroup) {
+func (w *Writer) Start(wg *sync.WaitGroup) {
 	defer wg.Done()
 	log.Info().Msg("Starting writer")
 	for {
 		select {
-		case message := <-writer_channel:
-			Write(message)
+		case message := <-w.messages:
+			w.Write(message)
+		case <-w.stop:
+			w.flush()
+			log.Info().Msg("Writer stopped")
+			return
+		}
+	}
+}
+
+// flush writes all the messages still pending on the writer channel.
+func (w *Writer) flush() {
+	for {
+		select {
+		case message := <-w.messages:
+			w.Write(message)
+		default:
+			return
 		}
 	}
 }
 
-// Initialise initializes the writer to use os.Stdout.
-func Initialise() {
-	writer = os.Stdout
+// Stop asks the writer goroutine to flush the pending messages and return.
+// Messages written after Stop are dropped.
+func (w *Writer) Stop() {
+	close(w.stop)
 }
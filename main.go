@@ -1,16 +1,14 @@
 package main
 
 import (
-	"KamaiZen/lsp"
+	"KamaiZen/rpc"
 	"KamaiZen/server"
 	"KamaiZen/settings"
-	"KamaiZen/state_manager"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
-	"sync"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -18,30 +16,89 @@ import (
 
 func main() {
 	v := flag.Bool("version", false, "print version")
+	flag.Bool("stdio", true, "communicate over stdin and stdout, the default")
+	listen := flag.String("listen", "", "accept client connections on `tcp://host:port`, each with its own session")
+	pipe := flag.String("pipe", "", "connect to the named pipe, or unix socket, created by the client at `path`")
+	logFile := flag.String("log-file", "/tmp/kamaizen.log", "write the logs to `path`")
+	record := flag.String("record", "", "record every message exchanged with the clients to `session.jsonl`")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s [flags] replay session.jsonl\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 	if *v {
 		fmt.Printf("version %s\n", settings.KAMAIZEN_VERSION)
 		return
 	}
-	initialise()
-	defer log.Info().Msg("KamaiZen stopped")
-	server := server.GetServerInstance()
+	if flag.Arg(0) == "replay" {
+		if flag.NArg() != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		initialise(*logFile)
+		os.Exit(replay(flag.Arg(1)))
+	}
+	if *listen != "" && *pipe != "" {
+		fmt.Fprintln(os.Stderr, "--listen and --pipe are mutually exclusive")
+		os.Exit(2)
+	}
+	initialise(*logFile)
+	var recorder *rpc.Recorder
+	if *record != "" {
+		var err error
+		if recorder, err = rpc.NewRecorder(*record); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		server.SetRecorder(recorder)
+	}
 
-	var wg sync.WaitGroup
-	wg.Add(2)
-	go server.StartServer(&wg)
-	go lsp.Start(&wg)
-	wg.Wait()
+	var code int
+	switch {
+	case *listen != "":
+		if err := server.Listen(*listen); err != nil {
+			log.Error().Err(err).Msg("Error listening for client connections")
+			fmt.Fprintln(os.Stderr, err)
+			code = 1
+		}
+	case *pipe != "":
+		var err error
+		if code, err = server.DialPipe(*pipe); err != nil {
+			log.Error().Err(err).Msg("Error connecting to the client pipe")
+			fmt.Fprintln(os.Stderr, err)
+		}
+	default:
+		code = server.Serve(os.Stdin, os.Stdout)
+	}
+	log.Info().Int("code", code).Msg("KamaiZen stopped")
+	if recorder != nil {
+		recorder.Close()
+	}
+	os.Exit(code)
+}
+
+// replay replays the recording at the given path and prints the differences with the recorded responses.
+// It returns 1 if there are differences, 0 otherwise.
+func replay(path string) int {
+	records, err := rpc.ReadRecords(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if server.Replay(records, os.Stdout) > 0 {
+		return 1
+	}
+	return 0
 }
 
-func initialise() {
+func initialise(logFile string) {
 	lev := zerolog.InfoLevel
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnixMicro
 	zerolog.CallerMarshalFunc = func(pc uintptr, file string, line int) string {
 		return filepath.Base(file) + ":" + strconv.Itoa(line)
 	}
 	file, err := os.OpenFile(
-		"/tmp/kamaizen.log",
+		logFile,
 		os.O_APPEND|os.O_CREATE|os.O_WRONLY,
 		0664,
 	)
@@ -51,6 +108,4 @@ func initialise() {
 	zerolog.TimeFieldFormat = zerolog.TimestampFunc().UTC().Format("2006-01-02T15:04:05.000Z")
 	log.Logger = zerolog.New(file).With().Caller().Timestamp().Logger().Level(lev)
 	log.Info().Msg("Starting KamaiZen!")
-	state_manager.InitializeState()
-	lsp.Initialise()
 }
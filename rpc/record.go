@@ -0,0 +1,124 @@
+package rpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Direction tells whether a recorded message was received from or sent to the client.
+type Direction string
+
+const (
+	Incoming Direction = "in"
+	Outgoing Direction = "out"
+)
+
+// Record is a message exchanged with a client, as written to a recording.
+// Each record is a line of the recording, in the order the messages were read or written.
+type Record struct {
+	Time      time.Time       `json:"time"`
+	Session   int             `json:"session"`
+	Direction Direction       `json:"direction"`
+	Message   json.RawMessage `json:"message"`
+}
+
+// Recorder writes the messages exchanged with the clients to a recording, one JSON record per line.
+// It is safe for concurrent use.
+type Recorder struct {
+	mu  sync.Mutex
+	out io.WriteCloser
+}
+
+// NewRecorder creates the recording at the given path and returns a recorder writing to it.
+//
+// Parameters:
+//
+//	path string - The path of the recording, truncated if it exists.
+//
+// Returns:
+//
+//	*Recorder - The recorder.
+//	error - An error if the recording cannot be created.
+func NewRecorder(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{out: file}, nil
+}
+
+// Record writes a framed message to the recording.
+// The content of the frame is recorded as is, or as a JSON string if it is not valid JSON.
+//
+// Parameters:
+//
+//	session int - The session the message belongs to.
+//	direction Direction - Whether the message was received from or sent to the client.
+//	frame []byte - The framed message, header included.
+func (r *Recorder) Record(session int, direction Direction, frame []byte) {
+	content := frame
+	if _, c, found := bytes.Cut(frame, []byte{'\r', '\n', '\r', '\n'}); found {
+		content = c
+	}
+	message := json.RawMessage(content)
+	if !json.Valid(content) {
+		message, _ = json.Marshal(string(content))
+	}
+	line, err := json.Marshal(Record{
+		Time:      time.Now().UTC(),
+		Session:   session,
+		Direction: direction,
+		Message:   message,
+	})
+	if err != nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.out.Write(append(line, '\n'))
+}
+
+// Close closes the recording.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.out.Close()
+}
+
+// ReadRecords reads the records of the recording at the given path.
+//
+// Parameters:
+//
+//	path string - The path of the recording.
+//
+// Returns:
+//
+//	[]Record - The records, in the order they were recorded.
+//	error - An error if the recording cannot be read or a line is not a record.
+func ReadRecords(path string) ([]Record, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
@@ -0,0 +1,32 @@
+package rpc_test
+
+import (
+	"KamaiZen/rpc"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder, err := rpc.NewRecorder(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	recorder.Record(1, rpc.Incoming, []byte(rpc.EncodeMessage(EncodingExample{Method: "hello"})))
+	recorder.Record(1, rpc.Outgoing, []byte("Content-Length: 3\r\n\r\nbad"))
+	recorder.Close()
+
+	records, err := rpc.ReadRecords(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected: %d,\ngot: %d", 2, len(records))
+	}
+	if records[0].Direction != rpc.Incoming || string(records[0].Message) != `{"Method":"hello"}` {
+		t.Fatalf("Expected: %s,\ngot: %s %s", `in {"Method":"hello"}`, records[0].Direction, records[0].Message)
+	}
+	if records[1].Direction != rpc.Outgoing || string(records[1].Message) != `"bad"` {
+		t.Fatalf("Expected: %s,\ngot: %s %s", `out "bad"`, records[1].Direction, records[1].Message)
+	}
+}
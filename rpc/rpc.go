@@ -26,11 +26,13 @@ func DecodeMessage(msg []byte) (string, []byte, error) {
 	if !found {
 		return "", nil, errors.New("Did not find the separator")
 	}
-	contentLengthBytes := bytes.Split(header, []byte{' '})[1]
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	contentLength, err := parseContentLength(header)
 	if err != nil {
 		return "", nil, err
 	}
+	if contentLength > len(content) {
+		return "", nil, errors.New("Content is shorter than its Content-Length")
+	}
 
 	var baseMessage BaseMessage
 	if err := json.Unmarshal(content[:contentLength], &baseMessage); err != nil {
@@ -44,8 +46,7 @@ func Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	if !found {
 		return 0, nil, nil
 	}
-	contentLengthBytes := bytes.Split(header, []byte{' '})[1]
-	contentLength, err := strconv.Atoi(string(contentLengthBytes))
+	contentLength, err := parseContentLength(header)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -59,3 +60,22 @@ func Split(data []byte, atEOF bool) (advance int, token []byte, err error) {
 	return totalLength, data[:totalLength], nil
 
 }
+
+// parseContentLength returns the value of the Content-Length field of the given header.
+func parseContentLength(header []byte) (int, error) {
+	for _, field := range bytes.Split(header, []byte{'\r', '\n'}) {
+		name, value, found := bytes.Cut(field, []byte{':'})
+		if !found || !bytes.EqualFold(bytes.TrimSpace(name), []byte("Content-Length")) {
+			continue
+		}
+		contentLength, err := strconv.Atoi(string(bytes.TrimSpace(value)))
+		if err != nil {
+			return 0, err
+		}
+		if contentLength < 0 {
+			return 0, fmt.Errorf("invalid Content-Length %d", contentLength)
+		}
+		return contentLength, nil
+	}
+	return 0, errors.New("Did not find the Content-Length header")
+}
@@ -33,3 +33,15 @@ func TestDecode(t *testing.T) {
 		t.Fatalf("Expected: hello,\ngot: %s", method)
 	}
 }
+
+func TestDecodeInvalidHeader(t *testing.T) {
+	for _, value := range []string{
+		"Content-Length:\r\n\r\n{}",
+		"Content-Type: json\r\n\r\n{}",
+		"Content-Length: 10\r\n\r\n{}",
+	} {
+		if _, _, err := rpc.DecodeMessage([]byte(value)); err == nil {
+			t.Fatalf("Expected: error,\ngot: nil for %q", value)
+		}
+	}
+}
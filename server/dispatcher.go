@@ -0,0 +1,203 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const concurrent_queue_size = 64
+
+// Dispatcher runs the handlers of the messages received from the client.
+// Document synchronisation notifications and lifecycle requests run one after the other on
+// a single goroutine, so that edits are applied strictly in the order they were sent.
+// Other requests run on a pool of workers, each with its own context that is cancelled
+// when the client sends '$/cancelRequest' for it. A request only starts once every document
+// update received before it has been applied.
+type Dispatcher struct {
+	ordered     chan func()
+	concurrent  chan func()
+	lastOrdered chan struct{} // closed once the last enqueued ordered job has run
+	workers     sync.WaitGroup
+	writer      *lsp.Writer // the writer of the connection the replies are sent to
+	tracer      *Tracer
+
+	mu       sync.Mutex
+	inflight map[string]context.CancelFunc
+}
+
+// NewDispatcher creates and returns a new Dispatcher instance.
+// The workers are started right away.
+//
+// Parameters:
+//
+//	workers int - The number of workers running the concurrent requests.
+//	writer *lsp.Writer - The writer the replies are sent to.
+//	tracer *Tracer - The tracer of the session the replies are traced to.
+//
+// Returns:
+//
+//	*Dispatcher - The initialized dispatcher.
+func NewDispatcher(workers int, writer *lsp.Writer, tracer *Tracer) *Dispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &Dispatcher{
+		ordered:     make(chan func(), concurrent_queue_size),
+		concurrent:  make(chan func(), concurrent_queue_size),
+		lastOrdered: make(chan struct{}),
+		inflight:    make(map[string]context.CancelFunc),
+		writer:      writer,
+		tracer:      tracer,
+	}
+	close(d.lastOrdered)
+	d.workers.Add(workers + 1)
+	go d.run(d.ordered)
+	for i := 0; i < workers; i++ {
+		go d.run(d.concurrent)
+	}
+	return d
+}
+
+// defaultWorkers returns the number of workers used by each session.
+func defaultWorkers() int {
+	return max(2, runtime.NumCPU())
+}
+
+// run executes the jobs received on the given queue until it is closed.
+func (d *Dispatcher) run(queue chan func()) {
+	defer d.workers.Done()
+	for job := range queue {
+		job()
+	}
+}
+
+// RunOrdered queues a job to be run after every job queued before it.
+// It must only be called from the goroutine reading the client messages.
+//
+// Parameters:
+//
+//	job func() - The job to be run.
+func (d *Dispatcher) RunOrdered(job func()) {
+	done := make(chan struct{})
+	d.lastOrdered = done
+	d.ordered <- func() {
+		defer close(done)
+		job()
+	}
+}
+
+// RunRequest runs the handler of a request and writes its response.
+// Ordered requests are queued with the document updates, the others run on the worker pool
+// once the document updates received before them have been applied.
+// A request that does not fit in the queue of the worker pool is answered with ServerCancelled.
+// It must only be called from the goroutine reading the client messages.
+//
+// Parameters:
+//
+//	id lsp.ID - The ID of the request.
+//	method string - The method of the request.
+//	ordered bool - Whether the request must run in order with the document updates.
+//	handler func(ctx context.Context) (any, error) - The handler producing the response.
+func (d *Dispatcher) RunRequest(id lsp.ID, method string, ordered bool, handler func(ctx context.Context) (any, error)) {
+	started := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	d.mu.Lock()
+	d.inflight[id.String()] = cancel
+	d.mu.Unlock()
+
+	run := func() {
+		defer d.finish(id, cancel)
+		if ctx.Err() != nil {
+			d.reply(ctx, id, method, started, nil, nil)
+			return
+		}
+		result, err := handler(ctx)
+		d.reply(ctx, id, method, started, result, err)
+	}
+	if ordered {
+		d.RunOrdered(run)
+		return
+	}
+	pending := d.lastOrdered
+	job := func() {
+		select {
+		case <-pending:
+		case <-ctx.Done():
+		}
+		run()
+	}
+	select {
+	case d.concurrent <- job:
+	default:
+		// blocking would stop reading the client messages, including the cancellations freeing the queue
+		log.Warn().Str("method", method).Str("id", id.String()).Msg("Request queue full, cancelling request")
+		d.reply(ctx, id, method, started, nil, lsp.NewResponseError(lsp.SERVER_CANCELLED, "Server is busy, retry the request"))
+		d.finish(id, cancel)
+	}
+}
+
+// Cancel cancels the context of an in-flight request.
+//
+// Parameters:
+//
+//	id lsp.ID - The ID of the request to be cancelled.
+//
+// Returns:
+//
+//	bool - True if the request was still in flight, false otherwise.
+func (d *Dispatcher) Cancel(id lsp.ID) bool {
+	d.mu.Lock()
+	cancel, found := d.inflight[id.String()]
+	d.mu.Unlock()
+	if found {
+		cancel()
+	}
+	return found
+}
+
+// Stop waits for the queued jobs to finish and stops the workers.
+// It must only be called from the goroutine reading the client messages, once it stopped reading.
+func (d *Dispatcher) Stop() {
+	close(d.ordered)
+	close(d.concurrent)
+	d.workers.Wait()
+}
+
+// finish forgets an in-flight request and releases its context.
+func (d *Dispatcher) finish(id lsp.ID, cancel context.CancelFunc) {
+	d.mu.Lock()
+	delete(d.inflight, id.String())
+	d.mu.Unlock()
+	cancel()
+}
+
+// reply writes the response of a request and traces it.
+// Cancelled requests are answered with RequestCancelled whatever the handler returned,
+// errors are answered with their own code if they are response errors, InternalError otherwise.
+func (d *Dispatcher) reply(ctx context.Context, id lsp.ID, method string, started time.Time, result any, err error) {
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	defer d.tracer.Replied(id, method, started, err)
+	if ctx.Err() != nil {
+		log.Info().Str("method", method).Str("id", id.String()).Msg("Request cancelled")
+		d.writer.WriteResponse(lsp.NewErrorResponse(id, lsp.REQUEST_CANCELLED, "Request cancelled"))
+		return
+	}
+	if err != nil {
+		var responseError *lsp.ResponseError
+		if !errors.As(err, &responseError) {
+			responseError = lsp.NewResponseError(lsp.INTERNAL_ERROR, err.Error())
+		}
+		log.Error().Err(err).Str("method", method).Str("id", id.String()).Msg("Request failed")
+		d.writer.WriteResponse(lsp.NewErrorResponse(id, responseError.Code, responseError.Message))
+		return
+	}
+	d.writer.WriteResponse(result)
+}
@@ -0,0 +1,212 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/rpc"
+	"KamaiZen/server"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// reply is a message written by the server, decoded by the tests.
+type reply struct {
+	ID     lsp.ID             `json:"id"`
+	Method string             `json:"method"`
+	Params json.RawMessage    `json:"params"`
+	Result json.RawMessage    `json:"result"`
+	Error  *lsp.ResponseError `json:"error"`
+}
+
+// readReplies decodes the messages read from the given output until it is closed.
+func readReplies(t *testing.T, out io.Reader) <-chan reply {
+	replies := make(chan reply, 64)
+	go func() {
+		defer close(replies)
+		scanner := bufio.NewScanner(out)
+		scanner.Split(rpc.Split)
+		for scanner.Scan() {
+			_, content, err := rpc.DecodeMessage(scanner.Bytes())
+			if err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			var r reply
+			if err := json.Unmarshal(content, &r); err != nil {
+				t.Errorf("Error: %s", err)
+				return
+			}
+			replies <- r
+		}
+	}()
+	return replies
+}
+
+// startWriter starts a writer and returns the messages it writes, decoded.
+// The writer is stopped once the test ends.
+func startWriter(t *testing.T) (*lsp.Writer, <-chan reply) {
+	out, in := io.Pipe()
+	writer := lsp.NewWriter(in)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go writer.Start(&wg)
+	replies := readReplies(t, out)
+	t.Cleanup(func() {
+		writer.Stop()
+		wg.Wait()
+		in.Close()
+	})
+	return writer, replies
+}
+
+// nextReply returns the next message written by the server, failing the test after a second.
+func nextReply(t *testing.T, replies <-chan reply) reply {
+	t.Helper()
+	select {
+	case r, ok := <-replies:
+		if !ok {
+			t.Fatalf("Expected: a message,\ngot: the output closed")
+		}
+		return r
+	case <-time.After(time.Second):
+		t.Fatalf("Expected: a message,\ngot: none after a second")
+	}
+	return reply{}
+}
+
+func TestRequestWaitsForDocumentUpdate(t *testing.T) {
+	writer, replies := startWriter(t)
+	dispatcher := server.NewDispatcher(2, writer, server.NewTracer(writer))
+	defer dispatcher.Stop()
+	release := make(chan struct{})
+	var applied atomic.Bool
+	dispatcher.RunOrdered(func() {
+		<-release
+		applied.Store(true)
+	})
+	dispatcher.RunRequest(lsp.NewIntID(1), "textDocument/hover", false, func(ctx context.Context) (any, error) {
+		return lsp.Response{RPC: "2.0", ID: lsp.NewIntID(1)}, nil
+	})
+	dispatcher.RunRequest(lsp.NewIntID(2), "textDocument/hover", false, func(ctx context.Context) (any, error) {
+		if !applied.Load() {
+			return nil, errors.New("the request ran before the document update")
+		}
+		return lsp.Response{RPC: "2.0", ID: lsp.NewIntID(2)}, nil
+	})
+	select {
+	case r := <-replies:
+		t.Fatalf("Expected: no reply before the document update,\ngot: %+v", r)
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	for range 2 {
+		if r := nextReply(t, replies); r.Error != nil {
+			t.Fatalf("Expected: a result,\ngot: %s", r.Error)
+		}
+	}
+}
+
+func TestCancelRunningRequest(t *testing.T) {
+	writer, replies := startWriter(t)
+	dispatcher := server.NewDispatcher(2, writer, server.NewTracer(writer))
+	started := make(chan struct{})
+	id := lsp.NewStringID("slow")
+	dispatcher.RunRequest(id, "textDocument/references", false, func(ctx context.Context) (any, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	<-started
+	if !dispatcher.Cancel(id) {
+		t.Fatalf("Expected: the request in flight,\ngot: not found")
+	}
+	r := nextReply(t, replies)
+	if r.ID != id || r.Error == nil || r.Error.Code != lsp.REQUEST_CANCELLED {
+		t.Fatalf("Expected: %d for %s,\ngot: %+v", lsp.REQUEST_CANCELLED, id, r)
+	}
+	dispatcher.Stop()
+	if dispatcher.Cancel(id) {
+		t.Fatalf("Expected: the request forgotten once answered,\ngot: still in flight")
+	}
+}
+
+func TestRequestErrors(t *testing.T) {
+	writer, replies := startWriter(t)
+	dispatcher := server.NewDispatcher(1, writer, server.NewTracer(writer))
+	defer dispatcher.Stop()
+	for _, test := range []struct {
+		err      error
+		expected lsp.ErrorCode
+	}{
+		{lsp.NewResponseError(lsp.REQUEST_FAILED, "Document not found"), lsp.REQUEST_FAILED},
+		{errors.New("boom"), lsp.INTERNAL_ERROR},
+	} {
+		dispatcher.RunRequest(lsp.NewIntID(1), "textDocument/rename", false, func(ctx context.Context) (any, error) {
+			return nil, test.err
+		})
+		if r := nextReply(t, replies); r.Error == nil || r.Error.Code != test.expected {
+			t.Fatalf("Expected: %d,\ngot: %+v", test.expected, r)
+		}
+	}
+}
+
+func TestUnknownRequest(t *testing.T) {
+	writer, replies := startWriter(t)
+	events := server.NewEventManager(writer, server.NewTracer(writer), server.NewStatistics())
+	defer events.Stop()
+	events.Dispatch(lsp.Message{RPC: "2.0", ID: lsp.NewIntID(7), Method: "textDocument/unknown"}, nil)
+	// an unknown notification is dropped
+	events.Dispatch(lsp.Message{RPC: "2.0", Method: "$/unknown"}, nil)
+	r := nextReply(t, replies)
+	expected := "Method not found: textDocument/unknown"
+	if r.ID != lsp.NewIntID(7) || r.Error == nil || r.Error.Code != lsp.METHOD_NOT_FOUND || r.Error.Message != expected {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, r)
+	}
+}
+
+func TestRequestQueueFull(t *testing.T) {
+	writer, replies := startWriter(t)
+	dispatcher := server.NewDispatcher(1, writer, server.NewTracer(writer))
+	defer dispatcher.Stop()
+	release := make(chan struct{})
+	dispatcher.RunOrdered(func() {
+		<-release
+	})
+	// the requests wait for the document update, the queue fills up without blocking the caller
+	for i := range 100 {
+		dispatcher.RunRequest(lsp.NewIntID(i), "textDocument/hover", false, func(ctx context.Context) (any, error) {
+			return lsp.Response{RPC: "2.0", ID: lsp.NewIntID(i)}, nil
+		})
+	}
+	if r := nextReply(t, replies); r.Error == nil || r.Error.Code != lsp.SERVER_CANCELLED {
+		t.Fatalf("Expected: %d,\ngot: %+v", lsp.SERVER_CANCELLED, r)
+	}
+	close(release)
+	for range 99 {
+		nextReply(t, replies)
+	}
+}
+
+func TestNotificationSentAsRequest(t *testing.T) {
+	writer, replies := startWriter(t)
+	events := server.NewEventManager(writer, server.NewTracer(writer), server.NewStatistics())
+	var ran atomic.Bool
+	events.RegisterHandler("textDocument/didOpen", func(contents []byte) {
+		ran.Store(true)
+	})
+	events.Dispatch(lsp.Message{RPC: "2.0", ID: lsp.NewIntID(3), Method: "textDocument/didOpen"}, []byte(`{}`))
+	r := nextReply(t, replies)
+	events.Stop()
+	if r.ID != lsp.NewIntID(3) || r.Error == nil || r.Error.Code != lsp.INVALID_REQUEST {
+		t.Fatalf("Expected: %d,\ngot: %+v", lsp.INVALID_REQUEST, r)
+	}
+	if ran.Load() {
+		t.Fatalf("Expected: the handler not run,\ngot: run")
+	}
+}
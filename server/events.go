@@ -3,62 +3,228 @@ package server
 import (
 	"KamaiZen/lsp"
 	"KamaiZen/settings"
-	"KamaiZen/state_manager"
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/rs/zerolog/log"
+	"runtime/debug"
+	"time"
 )
 
 const (
-	MethodInitialize            = "initialize"
-	MethodInitialized           = "initialized"
-	MethodDidOpen               = "textDocument/didOpen"
-	MethodDidChange             = "textDocument/didChange"
-	MethodHover                 = "textDocument/hover"
-	MethodDefinition            = "textDocument/definition"
-	MethodFormatting            = "textDocument/formatting"
-	MethodCompletion            = "textDocument/completion"
-	MethodConfiguration         = "workspace/Configuration"
-	MethodConfigurationResponse = ""
+	MethodInitialize    = "initialize"
+	MethodInitialized   = "initialized"
+	MethodShutdown      = "shutdown"
+	MethodCancelRequest = "$/cancelRequest"
+	MethodExit          = "exit"
+	MethodSetTrace      = "$/setTrace"
+	MethodDidOpen       = "textDocument/didOpen"
+	MethodDidChange     = "textDocument/didChange"
+	MethodDidClose      = "textDocument/didClose"
+	MethodDidSave       = "textDocument/didSave"
+	MethodHover         = "textDocument/hover"
+	MethodDefinition    = "textDocument/definition"
+	MethodReferences    = "textDocument/references"
+	MethodPrepareRename = "textDocument/prepareRename"
+	MethodRename        = "textDocument/rename"
+	MethodFormatting    = "textDocument/formatting"
+	MethodCompletion    = "textDocument/completion"
+	MethodConfiguration = "workspace/configuration"
+	MethodStatus        = "kamaizen/status"
+
+	MethodDocumentSymbol            = "textDocument/documentSymbol"
+	MethodWorkspaceSymbol           = "workspace/symbol"
+	MethodPrepareCallHierarchy      = "textDocument/prepareCallHierarchy"
+	MethodIncomingCalls             = "callHierarchy/incomingCalls"
+	MethodOutgoingCalls             = "callHierarchy/outgoingCalls"
+	MethodFoldingRange              = "textDocument/foldingRange"
+	MethodSemanticTokens            = "textDocument/semanticTokens/full"
+	MethodSemanticTokensDelta       = "textDocument/semanticTokens/full/delta"
+	MethodSemanticTokensRange       = "textDocument/semanticTokens/range"
+	MethodDocumentHighlight         = "textDocument/documentHighlight"
+	MethodDidChangeWatchedFiles     = "workspace/didChangeWatchedFiles"
+	MethodDidChangeConfiguration    = "workspace/didChangeConfiguration"
+	MethodDidChangeWorkspaceFolders = "workspace/didChangeWorkspaceFolders"
+	MethodRegisterCapability        = "client/registerCapability"
+	MethodWorkDoneProgressCreate    = "window/workDoneProgress/create"
+)
+
+// NotificationHandler handles a notification, notifications never get a response.
+type NotificationHandler func(contents []byte)
+
+// RequestHandler handles a request and returns the response to be sent back to the client.
+// The context is cancelled when the client sends '$/cancelRequest' for the request.
+// Errors of type *lsp.ResponseError are sent back with their own code, other errors as InternalError.
+type RequestHandler func(ctx context.Context, contents []byte) (any, error)
+
+// handlerMode tells where a handler runs.
+type handlerMode int
+
+const (
+	modeOrdered    handlerMode = iota // in order on the document queue
+	modeConcurrent                    // on the worker pool, once the pending document updates are applied
+	modeInline                        // on the goroutine reading the client messages
 )
 
+type handler struct {
+	notification NotificationHandler
+	request      RequestHandler
+	mode         handlerMode
+}
+
 // EventManager manages event handlers for different methods.
+// Every handler run is recorded in the statistics, and a panicking handler is recovered:
+// the panic is logged with the version of the document the message targets, and a request
+// is answered with InternalError.
 type EventManager struct {
-	handlers map[string]func(contents []byte)
+	handlers   map[string]handler
+	dispatcher *Dispatcher
+	writer     *lsp.Writer
+	stats      *Statistics
+	// documentVersion returns the version of a known document, for the panic logs
+	documentVersion func(uri lsp.DocumentURI) (int, bool)
 }
 
-// NewEventManager creates and returns a new EventManager instance.
-func NewEventManager() *EventManager {
+// NewEventManager creates and returns a new EventManager instance replying to the given writer,
+// the replies are traced to the given tracer and the handler runs recorded in the given statistics.
+func NewEventManager(writer *lsp.Writer, tracer *Tracer, stats *Statistics) *EventManager {
 	return &EventManager{
-		handlers: make(map[string]func(contents []byte)),
+		handlers:   make(map[string]handler),
+		dispatcher: NewDispatcher(defaultWorkers(), writer, tracer),
+		writer:     writer,
+		stats:      stats,
 	}
 }
 
-// RegisterHandler registers a handler function for a specific method.
+// RegisterHandler registers a notification handler for a specific method.
+// Notification handlers run in order on the document queue.
 // method: The name of the method for which the handler is being registered.
-// handler: The function to handle the event. It takes an state_manager.State, the contents as a byte slice, and a channel for state_manager.State.
-func (em *EventManager) RegisterHandler(method string, handler func(contents []byte)) {
+// notificationHandler: The function to handle the notification.
+func (em *EventManager) RegisterHandler(method string, notificationHandler NotificationHandler) {
 	log.Info().Str("method", method).Msg("Registering handler")
-	em.handlers[method] = handler
+	em.handlers[method] = handler{notification: notificationHandler, mode: modeOrdered}
+}
+
+// RegisterInlineHandler registers a notification handler that runs on the goroutine reading the
+// client messages. It is meant for the few notifications that must not wait for queued work.
+// method: The name of the method for which the handler is being registered.
+// notificationHandler: The function to handle the notification.
+func (em *EventManager) RegisterInlineHandler(method string, notificationHandler NotificationHandler) {
+	log.Info().Str("method", method).Msg("Registering inline handler")
+	em.handlers[method] = handler{notification: notificationHandler, mode: modeInline}
 }
 
-// Dispatch calls the registered handler for the given method.
-// method: The name of the method for which the handler is being dispatched.
-// state: The current state of the state_manager.
+// RegisterRequestHandler registers a request handler for a specific method.
+// Request handlers run concurrently on the worker pool.
+// method: The name of the method for which the handler is being registered.
+// requestHandler: The function to handle the request.
+func (em *EventManager) RegisterRequestHandler(method string, requestHandler RequestHandler) {
+	log.Info().Str("method", method).Msg("Registering request handler")
+	em.handlers[method] = handler{request: requestHandler, mode: modeConcurrent}
+}
+
+// RegisterOrderedRequestHandler registers a request handler that runs in order with the document updates.
+// method: The name of the method for which the handler is being registered.
+// requestHandler: The function to handle the request.
+func (em *EventManager) RegisterOrderedRequestHandler(method string, requestHandler RequestHandler) {
+	log.Info().Str("method", method).Msg("Registering ordered request handler")
+	em.handlers[method] = handler{request: requestHandler, mode: modeOrdered}
+}
+
+// Dispatch runs the registered handler for the given message.
+// Requests without a registered handler are answered with a MethodNotFound error, and requests for
+// a notification method with an InvalidRequest error without running the handler,
+// so that the client never waits for a reply that will not come.
+// message: The envelope of the message to be dispatched.
 // contents: The contents to be passed to the handler as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func (em *EventManager) Dispatch(method string, contents []byte) {
-	if handler, found := em.handlers[method]; found {
-		handler(contents)
+func (em *EventManager) Dispatch(message lsp.Message, contents []byte) {
+	h, found := em.handlers[message.Method]
+	if !found {
+		if message.IsRequest() {
+			log.Warn().Str("method", message.Method).Msg("No handler found, replying with MethodNotFound")
+			em.writer.WriteResponse(lsp.NewErrorResponse(message.ID, lsp.METHOD_NOT_FOUND, "Method not found: "+message.Method))
+			return
+		}
+		log.Error().Str("method", message.Method).Msg("No handler found")
+		return
+	}
+	if h.request != nil {
+		if !message.IsRequest() {
+			log.Error().Str("method", message.Method).Msg("Received request method as a notification")
+			return
+		}
+		em.dispatcher.RunRequest(message.ID, message.Method, h.mode == modeOrdered,
+			func(ctx context.Context) (result any, err error) {
+				defer em.track(message.Method, contents, time.Now(), &err)
+				return h.request(ctx, contents)
+			})
 		return
 	}
-	log.Error().Str("method", method).Msg("No handler found")
+	if message.IsRequest() {
+		log.Warn().Str("method", message.Method).Msg("Received notification method as a request, replying with InvalidRequest")
+		em.writer.WriteResponse(lsp.NewErrorResponse(message.ID, lsp.INVALID_REQUEST, "Not a request: "+message.Method))
+		return
+	}
+	run := func() {
+		var err error
+		defer em.track(message.Method, contents, time.Now(), &err)
+		h.notification(contents)
+	}
+	if h.mode == modeInline {
+		run()
+		return
+	}
+	em.dispatcher.RunOrdered(run)
+}
+
+// track records a handler run in the statistics, it must be deferred by the handler run.
+// A panic of the handler is recovered, logged with its stack, and turned into an InternalError.
+// method: The method of the message handled.
+// contents: The contents of the message handled.
+// started: The time the handler started.
+// err: The error returned by the handler, replaced if the handler panicked.
+func (em *EventManager) track(method string, contents []byte, started time.Time, err *error) {
+	recovered := recover()
+	if recovered != nil {
+		event := log.Error().
+			Str("method", method).
+			Str("panic", fmt.Sprint(recovered)).
+			Str("stack", string(debug.Stack()))
+		var message struct {
+			Params struct {
+				TextDocument lsp.TextDocumentIdentifier `json:"textDocument"`
+			} `json:"params"`
+		}
+		if json.Unmarshal(contents, &message) == nil && message.Params.TextDocument.URI != "" {
+			uri := message.Params.TextDocument.URI
+			event = event.Str("uri", string(uri))
+			if em.documentVersion != nil {
+				if version, found := em.documentVersion(uri); found {
+					event = event.Int("version", version)
+				}
+			}
+		}
+		event.Msg("Handler panicked")
+		*err = lsp.NewResponseError(lsp.INTERNAL_ERROR, fmt.Sprintf("Internal error handling %s: %v", method, recovered))
+	}
+	em.stats.Record(method, time.Since(started), *err, recovered != nil)
+}
+
+// Stop waits for the dispatched handlers to finish.
+func (em *EventManager) Stop() {
+	em.dispatcher.Stop()
+}
+
+// invalidParams returns the error sent back for a request whose parameters can't be decoded.
+func invalidParams(e error) *lsp.ResponseError {
+	return lsp.NewResponseError(lsp.INVALID_PARAMS, e.Error())
 }
 
 // handleInitialized handles the 'initialized' notification.
-// state: The current state of the state_manager.
+// It fetches the configuration of the session and asks the client to watch the configuration files
+// and the configuration changes.
 // contents: The contents of the notification as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleInitialized(contents []byte) {
+func (s *Session) handleInitialized(contents []byte) {
 	var notification lsp.InitializedNotification
 	log.Info().Str("contents", string(contents)).Msg("Received initialized notification")
 	if e := json.Unmarshal(contents, &notification); e != nil {
@@ -66,56 +232,139 @@ func handleInitialized(contents []byte) {
 		return
 	}
 	log.Info().Msgf("Received initialized notification with %v", notification)
+	s.fetchConfiguration()
+	s.watched = make(map[string]bool)
+	s.watchFiles()
+	s.watchConfiguration()
 }
 
 // handleInitialize handles the 'initialize' request.
-// state: The current state of the state_manager.
+// It records the client capabilities, the workspace folders, the trace level and the position encoding
+// of the session, and returns the initialize response holding the server capabilities.
 // contents: The contents of the request as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleInitialize(contents []byte) {
+func (s *Session) handleInitialize(ctx context.Context, contents []byte) (any, error) {
 	var request lsp.InitializeRequest
 	log.Info().Str("contents", string(contents)).Msg("Received initialize request")
 	if e := json.Unmarshal(contents, &request); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling initialize request")
-		return
+		return nil, invalidParams(e)
+	}
+	s.clientCapabilities = request.Params.Capabilities
+	// the folders are scanned once the configuration is applied, as it holds the globs of the files to scan
+	folders := request.Params.WorkspaceFolders
+	if len(folders) == 0 && request.Params.RootURI != "" {
+		folders = []lsp.WorkspaceFolder{{URI: request.Params.RootURI}}
 	}
+	for _, folder := range folders {
+		s.state.AddWorkspaceFolder(folder.URI)
+	}
+	if request.Params.Trace != "" {
+		s.tracer.SetLevel(request.Params.Trace)
+	}
+	encoding := lsp.NegotiatePositionEncoding(request.Params.Capabilities.General.PositionEncodings)
+	s.state.SetPositionEncoding(encoding)
 	log.Info().
 		Str("client", request.Params.ClientInfo.Name).
 		Str("version", request.Params.ClientInfo.Version).
-		Msg("Connected... Sending workspace configuration request")
-	config_request := lsp.NewWorkspaceConfigurationRequest(request.ID, lsp.ConfigurationParams{
-		Items: []lsp.ConfigurationItem{
-			{
-				Section: "kamaizen",
-			},
-		},
-	})
-	lsp.WriteResponse(config_request)
+		Str("positionEncoding", string(encoding)).
+		Msg("Connected... Configuration is fetched once initialized")
+	return lsp.NewInitializeResponse(request.ID, encoding), nil
+}
+
+// handleShutdown handles the 'shutdown' request.
+// It replies with a null result once the work queued before it is done.
+// Every following request but 'exit' is rejected by Session.accept.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleShutdown(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.ShutdownRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling shutdown request")
+		return nil, invalidParams(e)
+	}
+	log.Info().Msg("Received shutdown request")
+	return lsp.NewShutdownResponse(request.ID), nil
 }
 
-func handleWorkspaceConfiguration(contents []byte) {
-	var response lsp.WorkspaceConfigurationResponse
-	if e := json.Unmarshal(contents, &response); e != nil {
+// handleCancelRequest handles the '$/cancelRequest' notification.
+// It cancels the context of the in-flight request, which is then answered with RequestCancelled.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleCancelRequest(contents []byte) {
+	var notification lsp.CancelRequestNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling cancelRequest notification")
+		return
+	}
+	if !s.eventManager.dispatcher.Cancel(notification.Params.ID) {
+		log.Debug().Str("id", notification.Params.ID.String()).Msg("Cancelled request is not in flight")
+	}
+}
+
+// handleSetTrace handles the '$/setTrace' notification.
+// It sets the level of the trace sent to the client with '$/logTrace'.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleSetTrace(contents []byte) {
+	var notification lsp.SetTraceNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling setTrace notification")
+		return
+	}
+	s.tracer.SetLevel(notification.Params.Value)
+}
+
+// handleExit handles the 'exit' notification.
+// It stops the server loop, the exit code depends on whether a shutdown was requested before.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleExit(contents []byte) {
+	log.Info().Msg("Received exit notification")
+	s.exit()
+}
+
+// handleWorkspaceConfiguration handles the client response to the 'workspace/configuration' request.
+// It applies the received settings on the ordered queue, see Session.applySettings.
+// response: The response of the client.
+func (s *Session) handleWorkspaceConfiguration(response ClientResponse) {
+	if response.Error != nil {
+		log.Error().Err(response.Error).Msg("Client failed to provide the workspace configuration")
+		return
+	}
+	var result []lsp.ConfigurationObject
+	if e := json.Unmarshal(response.Result, &result); e != nil || len(result) == 0 {
 		log.Error().Err(e).Msg("Error unmarshalling workspace configuration response")
 		return
 	}
-	var initialize_response lsp.InitializeResponse
-	initialize_response = lsp.NewInitializeResponse(response.ID)
-	lsp.WriteResponse(initialize_response)
-	GetServerInstance().addKamailioMethods(
-		settings.NewLSPSettings(
-			response.Result[0].KamailioSourcePath,
-			"",
-			response.Result[0].Loglevel,
-			response.Result[0].EnableDeprecatedCommentHint,
-			response.Result[0].EnableDiagnostics))
+	// settings are read by the document handlers, apply them in between document updates
+	lspSettings := settings.NewLSPSettings(
+		result[0].KamailioSourcePath,
+		"",
+		result[0].Loglevel,
+		result[0].EnableDeprecatedCommentHint,
+		result[0].EnableDiagnostics)
+	lspSettings.ConfigGlobs = result[0].ConfigGlobs
+	if len(lspSettings.ConfigGlobs) == 0 {
+		lspSettings.ConfigGlobs = settings.DEFAULT_CONFIG_GLOBS
+	}
+	s.eventManager.dispatcher.RunOrdered(func() {
+		s.applySettings(lspSettings)
+	})
+}
+
+// handleDidChangeConfiguration handles the 'workspace/didChangeConfiguration' notification.
+// The settings pushed by the client are ignored, the configuration is pulled again.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleDidChangeConfiguration(contents []byte) {
+	var notification lsp.DidChangeConfigurationNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling didChangeConfiguration notification")
+		return
+	}
+	log.Info().Msg("Configuration changed")
+	s.fetchConfiguration()
 }
 
 // handleDidOpen handles the 'didOpen' notification.
-// state: The current state of the state_manager.
+// The document is analysed by the state of the session and its diagnostics, if any, are published.
 // contents: The contents of the notification as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleDidOpen(contents []byte) {
+func (s *Session) handleDidOpen(contents []byte) {
 	var notification lsp.DidOpenTextDocumentNotification
 	if e := json.Unmarshal(contents, &notification); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling didOpen notification")
@@ -124,122 +373,379 @@ func handleDidOpen(contents []byte) {
 	log.Info().
 		Str("uri", string(notification.Params.TextDocument.URI)).
 		Msg("Opened document")
-	dignostics := state_manager.GetState().OpenDocument(
+	dignostics := s.state.OpenDocument(
+		s.ctx,
 		notification.Params.TextDocument.URI,
+		notification.Params.TextDocument.Version,
 		notification.Params.TextDocument.Text,
 	)
 	if len(dignostics) > 0 {
-		lsp.WriteResponse(
+		s.writer.WriteResponse(
 			lsp.NewPublishDiagnosticNotification(
 				notification.Params.TextDocument.URI,
 				dignostics,
 			),
 		)
 	}
+	s.watchFiles()
 }
 
 // handleMessage handles incoming messages and dispatches them to the appropriate handler.
-// state: The current state of the state_manager.
-// method: The name of the method for which the handler is being dispatched.
+// message: The envelope of the message.
 // contents: The contents of the message as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
 // eventManager: The EventManager instance to use for dispatching the message.
-func handleMessage(method string, contents []byte, eventManager *EventManager) {
-	log.Info().Str("method", method).Msg("Received message with method")
-	eventManager.Dispatch(method, contents)
+func handleMessage(message lsp.Message, contents []byte, eventManager *EventManager) {
+	log.Info().Str("method", message.Method).Msg("Received message with method")
+	eventManager.Dispatch(message, contents)
 }
 
 // handleDidChange handles the 'didChange' notification.
-// state: The current state of the state_manager.
+// The changes are applied to the document in the state of the session and its diagnostics are published.
 // contents: The contents of the notification as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleDidChange(contents []byte) {
+func (s *Session) handleDidChange(contents []byte) {
 	var notification lsp.DidChangeTextDocumentNotification
-	state := state_manager.GetState()
 	if e := json.Unmarshal(contents, &notification); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling didChange notification")
 		return
 	}
-	for _, change := range notification.Params.ContentChanges {
-		diagnostics := state.UpdateDocument(notification.Params.TextDocument.URI, change.Text)
-		if len(diagnostics) > 0 {
-			log.Debug().
-				Str("uri", string(notification.Params.TextDocument.URI)).
-				Msg("Sending diagnostics for document")
-			lsp.WriteResponse(
-				lsp.NewPublishDiagnosticNotification(
-					notification.Params.TextDocument.URI,
-					diagnostics,
-				),
-			)
-			return
+	diagnostics := s.state.UpdateDocument(
+		s.ctx,
+		notification.Params.TextDocument.URI,
+		notification.Params.TextDocument.Version,
+		notification.Params.ContentChanges,
+	)
+	s.publishDiagnostics(notification.Params.TextDocument.URI, diagnostics)
+	s.watchFiles()
+}
+
+// handleDidClose handles the 'didClose' notification.
+// The document is dropped, unless another document includes it, and its diagnostics are cleared.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleDidClose(contents []byte) {
+	var notification lsp.DidCloseTextDocumentNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling didClose notification")
+		return
+	}
+	log.Info().
+		Str("uri", string(notification.Params.TextDocument.URI)).
+		Msg("Closed document")
+	s.state.CloseDocument(s.ctx, notification.Params.TextDocument.URI)
+	s.publishDiagnostics(notification.Params.TextDocument.URI, []lsp.Diagnostic{})
+}
+
+// handleDidSave handles the 'didSave' notification.
+// The documents including the saved document are analysed again.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleDidSave(contents []byte) {
+	var notification lsp.DidSaveTextDocumentNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling didSave notification")
+		return
+	}
+	for uri, diagnostics := range s.state.SaveDocument(s.ctx, notification.Params.TextDocument.URI) {
+		s.publishDiagnostics(uri, diagnostics)
+	}
+}
+
+// handleDidChangeWatchedFiles handles the 'workspace/didChangeWatchedFiles' notification.
+// Changed files are read again from disk and the documents including them are analysed again.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleDidChangeWatchedFiles(contents []byte) {
+	var notification lsp.DidChangeWatchedFilesNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling didChangeWatchedFiles notification")
+		return
+	}
+	for _, change := range notification.Params.Changes {
+		log.Debug().Str("uri", string(change.URI)).Int("type", int(change.Type)).Msg("Watched file changed")
+		for uri, diagnostics := range s.state.FileChanged(s.ctx, change.URI, change.Type) {
+			s.publishDiagnostics(uri, diagnostics)
 		}
-		// clear diagnostics
-		log.Debug().
-			Str("uri", string(notification.Params.TextDocument.URI)).
-			Msg("Clearing diagnostics for document")
-		lsp.WriteResponse(
-			lsp.NewPublishDiagnosticNotification(
-				notification.Params.TextDocument.URI,
-				[]lsp.Diagnostic{},
-			),
-		)
+	}
+	s.watchFiles()
+}
 
+// handleDidChangeWorkspaceFolders handles the 'workspace/didChangeWorkspaceFolders' notification.
+// The scans of the removed folders are cancelled and their files dropped, the added folders are scanned
+// once the configuration is applied.
+// contents: The contents of the notification as a byte slice.
+func (s *Session) handleDidChangeWorkspaceFolders(contents []byte) {
+	var notification lsp.DidChangeWorkspaceFoldersNotification
+	if e := json.Unmarshal(contents, &notification); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling didChangeWorkspaceFolders notification")
+		return
+	}
+	for _, folder := range notification.Params.Event.Removed {
+		log.Info().Str("uri", string(folder.URI)).Msg("Workspace folder removed")
+		if cancel, found := s.scans[folder.URI]; found {
+			cancel()
+			delete(s.scans, folder.URI)
+		}
+		s.state.RemoveWorkspaceFolder(folder.URI)
+	}
+	var added []lsp.DocumentURI
+	for _, folder := range notification.Params.Event.Added {
+		log.Info().Str("uri", string(folder.URI)).Msg("Workspace folder added")
+		s.state.AddWorkspaceFolder(folder.URI)
+		added = append(added, folder.URI)
 	}
+	if s.configured {
+		s.scanWorkspace(added...)
+	}
+}
+
+// handleRegistration handles the client response to a 'client/registerCapability' request.
+// response: The response of the client.
+func handleRegistration(response ClientResponse) {
+	if response.Error != nil {
+		log.Error().Err(response.Error).Msg("Client failed to register the capability")
+	}
+}
+
+// publishDiagnostics sends the diagnostics of a document to the client,
+// an empty list clears the diagnostics previously published.
+// uri: The URI of the document.
+// diagnostics: The diagnostics of the document.
+func (s *Session) publishDiagnostics(uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) {
+	if len(diagnostics) > 0 {
+		log.Debug().Str("uri", string(uri)).Msg("Sending diagnostics for document")
+	} else {
+		log.Debug().Str("uri", string(uri)).Msg("Clearing diagnostics for document")
+		diagnostics = []lsp.Diagnostic{}
+	}
+	s.writer.WriteResponse(lsp.NewPublishDiagnosticNotification(uri, diagnostics))
 }
 
 // handleHover handles the 'hover' request.
-// state: The current state of the state_manager.
+// It returns the hover response of the state of the session, or the context error if the request was cancelled.
 // contents: The contents of the request as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleHover(contents []byte) {
+func (s *Session) handleHover(ctx context.Context, contents []byte) (any, error) {
 	var request lsp.HoverRequest
 	if e := json.Unmarshal(contents, &request); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling hover request")
-		return
+		return nil, invalidParams(e)
 	}
-	response := state_manager.GetState().Hover(request.ID, request.Params.TextDocument.URI, request.Params.Position)
-	lsp.WriteResponse(response)
+	return s.state.Hover(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Position)
 }
 
 // handleDefinition handles the 'definition' request.
-// state: The current state of the state_manager.
+// It returns the definition response of the state of the session, or the context error if the request was cancelled.
 // contents: The contents of the request as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleDefinition(contents []byte) {
+func (s *Session) handleDefinition(ctx context.Context, contents []byte) (any, error) {
 	var request lsp.DefinitionProviderRequest
 	if e := json.Unmarshal(contents, &request); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling definition request")
-		return
+		return nil, invalidParams(e)
 	}
-	response := state_manager.GetState().Definition(request.ID, request.Params.TextDocument.URI, request.Params.Position)
-	lsp.WriteResponse(response)
+	return s.state.Definition(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Position)
+}
+
+// handleReferences handles the 'references' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleReferences(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.ReferencesRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling references request")
+		return nil, invalidParams(e)
+	}
+	params := request.Params
+	return s.state.References(ctx, request.ID, params.TextDocument.URI, params.Position, params.Context.IncludeDeclaration)
+}
+
+// handleDocumentHighlight handles the 'documentHighlight' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleDocumentHighlight(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.DocumentHighlightRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling document highlight request")
+		return nil, invalidParams(e)
+	}
+	params := request.Params
+	return s.state.DocumentHighlights(ctx, request.ID, params.TextDocument.URI, params.Position)
+}
+
+// handlePrepareRename handles the 'prepareRename' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handlePrepareRename(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.PrepareRenameRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling prepare rename request")
+		return nil, invalidParams(e)
+	}
+	return s.state.PrepareRename(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Position)
+}
+
+// handleRename handles the 'rename' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleRename(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.RenameRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling rename request")
+		return nil, invalidParams(e)
+	}
+	params := request.Params
+	return s.state.Rename(ctx, request.ID, params.TextDocument.URI, params.Position, params.NewName)
+}
+
+// handleDocumentSymbol handles the 'documentSymbol' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleDocumentSymbol(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.DocumentSymbolRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling document symbol request")
+		return nil, invalidParams(e)
+	}
+	return s.state.DocumentSymbols(ctx, request.ID, request.Params.TextDocument.URI)
+}
+
+// handleFoldingRange handles the 'foldingRange' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleFoldingRange(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.FoldingRangeRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling folding range request")
+		return nil, invalidParams(e)
+	}
+	return s.state.FoldingRanges(ctx, request.ID, request.Params.TextDocument.URI)
+}
+
+// handleSemanticTokens handles the 'semanticTokens/full' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleSemanticTokens(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.SemanticTokensRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling semantic tokens request")
+		return nil, invalidParams(e)
+	}
+	return s.state.SemanticTokens(ctx, request.ID, request.Params.TextDocument.URI)
+}
+
+// handleSemanticTokensDelta handles the 'semanticTokens/full/delta' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleSemanticTokensDelta(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.SemanticTokensDeltaRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling semantic tokens delta request")
+		return nil, invalidParams(e)
+	}
+	params := request.Params
+	return s.state.SemanticTokensDelta(ctx, request.ID, params.TextDocument.URI, params.PreviousResultID)
+}
+
+// handleSemanticTokensRange handles the 'semanticTokens/range' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleSemanticTokensRange(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.SemanticTokensRangeRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling semantic tokens range request")
+		return nil, invalidParams(e)
+	}
+	params := request.Params
+	return s.state.SemanticTokensRange(ctx, request.ID, params.TextDocument.URI, params.Range)
+}
+
+// handleWorkspaceSymbol handles the 'workspace/symbol' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleWorkspaceSymbol(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.WorkspaceSymbolRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling workspace symbol request")
+		return nil, invalidParams(e)
+	}
+	return s.state.WorkspaceSymbols(ctx, request.ID, request.Params.Query)
+}
+
+// handlePrepareCallHierarchy handles the 'prepareCallHierarchy' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handlePrepareCallHierarchy(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.PrepareCallHierarchyRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling prepare call hierarchy request")
+		return nil, invalidParams(e)
+	}
+	return s.state.PrepareCallHierarchy(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Position)
+}
+
+// handleIncomingCalls handles the 'callHierarchy/incomingCalls' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleIncomingCalls(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.CallHierarchyIncomingCallsRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling incoming calls request")
+		return nil, invalidParams(e)
+	}
+	return s.state.IncomingCalls(ctx, request.ID, request.Params.Item)
+}
+
+// handleOutgoingCalls handles the 'callHierarchy/outgoingCalls' request.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleOutgoingCalls(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.CallHierarchyOutgoingCallsRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling outgoing calls request")
+		return nil, invalidParams(e)
+	}
+	return s.state.OutgoingCalls(ctx, request.ID, request.Params.Item)
 }
 
 // handleFormatting handles the 'formatting' request.
-// state: The current state of the state_manager.
+// It returns the edits formatting the document in the state of the session, or the context error if the request was cancelled.
 // contents: The contents of the request as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleFormatting(contents []byte) {
+func (s *Session) handleFormatting(ctx context.Context, contents []byte) (any, error) {
 	var request lsp.DocumentFormattingRequest
 	if e := json.Unmarshal(contents, &request); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling formatting request")
-		return
+		return nil, invalidParams(e)
 	}
-	response := state_manager.GetState().Formatting(request.ID, request.Params.TextDocument.URI, request.Params.Options)
-	lsp.WriteResponse(response)
+	return s.state.Formatting(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Options)
 }
 
 // handleCompletion handles the 'completion' request.
-// state: The current state of the state_manager.
+// It returns the completion items of the state of the session, or the context error if the request was cancelled.
 // contents: The contents of the request as a byte slice.
-// analyser_channel: A channel for state_manager.State to communicate with the handler.
-func handleCompletion(contents []byte) {
+func (s *Session) handleCompletion(ctx context.Context, contents []byte) (any, error) {
 	var request lsp.CompletionRequest
 	if e := json.Unmarshal(contents, &request); e != nil {
 		log.Error().Err(e).Msg("Error unmarshalling completion request")
-		return
+		return nil, invalidParams(e)
+	}
+	return s.state.TextDocumentCompletion(ctx, request.ID, request.Params.TextDocument.URI, request.Params.Position)
+}
+
+// handleStatus handles the custom 'kamaizen/status' request.
+// It reports the uptime of the server, the documents and statistics of the session, and the index size.
+// contents: The contents of the request as a byte slice.
+func (s *Session) handleStatus(ctx context.Context, contents []byte) (any, error) {
+	var request lsp.StatusRequest
+	if e := json.Unmarshal(contents, &request); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling status request")
+		return nil, invalidParams(e)
 	}
-	response := state_manager.GetState().TextDocumentCompletion(request.ID, request.Params.TextDocument.URI, request.Params.Position)
-	lsp.WriteResponse(response)
+	uptime := time.Since(started)
+	open, loaded := s.state.OpenDocuments()
+	if open == nil {
+		open = []lsp.DocumentURI{}
+	}
+	folders := s.state.WorkspaceFolders()
+	if folders == nil {
+		folders = []lsp.DocumentURI{}
+	}
+	modules, functions, cookbook := s.state.Modules().IndexSize()
+	recent, methods := s.stats.Snapshot()
+	return lsp.NewStatusResponse(request.ID, lsp.Status{
+		Version:          settings.KAMAIZEN_VERSION,
+		Uptime:           uptime.Round(time.Second).String(),
+		UptimeSeconds:    uptime.Seconds(),
+		OpenDocuments:    open,
+		DiskDocuments:    loaded,
+		WorkspaceFolders: folders,
+		Index: lsp.IndexStatus{
+			Modules:   modules,
+			Functions: functions,
+			Cookbook:  cookbook,
+		},
+		RecentErrors: recent,
+		Methods:      methods,
+	}), nil
 }
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// progress_create_timeout bounds the wait for the client to create a progress.
+const progress_create_timeout = 5 * time.Second
+
+// Progress reports the progress of a background work to the client with '$/progress'.
+// A nil Progress reports nothing, so the work does not need to check whether the client supports it.
+type Progress struct {
+	writer     *lsp.Writer
+	token      string
+	percentage int
+}
+
+// beginProgress asks the client to create a progress and begins it.
+// It blocks until the client created the progress, so it must not be called from the goroutine
+// reading the client messages.
+//
+// Parameters:
+//
+//	supported bool - Whether the client supports the 'window/workDoneProgress/create' request.
+//	name string - The name of the progress, which prefixes its unique token.
+//	title string - The title of the progress shown to the user.
+//
+// Returns:
+//
+//	*Progress - The progress, nil if the client does not support it or failed to create it.
+func (s *Session) beginProgress(supported bool, name string, title string) *Progress {
+	if !supported {
+		return nil
+	}
+	token := fmt.Sprintf("kamaizen-%s-%d", name, s.progressTokens.Add(1))
+	ctx, cancel := context.WithTimeout(context.Background(), progress_create_timeout)
+	defer cancel()
+	if _, err := s.requests.Call(ctx, MethodWorkDoneProgressCreate, lsp.WorkDoneProgressCreateParams{Token: token}); err != nil {
+		log.Warn().Err(err).Str("token", token).Msg("Client did not create the progress")
+		return nil
+	}
+	percentage := 0
+	s.writer.WriteResponse(lsp.NewProgressNotification(token, lsp.WorkDoneProgressBegin{
+		Kind:       "begin",
+		Title:      title,
+		Percentage: &percentage,
+	}))
+	return &Progress{writer: s.writer, token: token}
+}
+
+// Report reports the progress made, it is only sent when the percentage changes.
+// done: The number of items done.
+// total: The number of items to do.
+// message: The message describing the progress.
+func (p *Progress) Report(done int, total int, message string) {
+	if p == nil || total <= 0 {
+		return
+	}
+	percentage := done * 100 / total
+	if percentage <= p.percentage {
+		return
+	}
+	p.percentage = percentage
+	p.writer.WriteResponse(lsp.NewProgressNotification(p.token, lsp.WorkDoneProgressReport{
+		Kind:       "report",
+		Message:    message,
+		Percentage: &percentage,
+	}))
+}
+
+// End ends the progress.
+// message: The message describing the outcome of the work.
+func (p *Progress) End(message string) {
+	if p == nil {
+		return
+	}
+	p.writer.WriteResponse(lsp.NewProgressNotification(p.token, lsp.WorkDoneProgressEnd{
+		Kind:    "end",
+		Message: message,
+	}))
+}
+
+// showMessage shows a message to the user, and mirrors it to the log file and the client log.
+// messageType: The type of the message.
+// message: The message to show.
+func (s *Session) showMessage(messageType lsp.MessageType, message string) {
+	s.writer.WriteResponse(lsp.NewShowMessageNotification(messageType, message))
+	s.logMessage(messageType, message)
+}
+
+// logMessage writes a message to the log file and the client log, for clients that do not show the log file.
+// messageType: The type of the message.
+// message: The message to log.
+func (s *Session) logMessage(messageType lsp.MessageType, message string) {
+	switch messageType {
+	case lsp.MESSAGE_ERROR:
+		log.Error().Msg(message)
+	case lsp.MESSAGE_WARNING:
+		log.Warn().Msg(message)
+	case lsp.MESSAGE_INFO:
+		log.Info().Msg(message)
+	default:
+		log.Debug().Msg(message)
+	}
+	s.writer.WriteResponse(lsp.NewLogMessageNotification(messageType, message))
+}
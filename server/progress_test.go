@@ -0,0 +1,130 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// progress is the value of a '$/progress' notification, any of begin, report and end.
+type progress struct {
+	Token string `json:"token"`
+	Value struct {
+		Kind    string `json:"kind"`
+		Title   string `json:"title"`
+		Message string `json:"message"`
+	} `json:"value"`
+}
+
+// kamailioSources creates Kamailio sources holding the given module READMEs, a nil README is not created.
+func kamailioSources(t *testing.T, readmes map[string]*string) string {
+	src := t.TempDir()
+	for module, readme := range readmes {
+		dir := filepath.Join(src, "src", "modules", module)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if readme == nil {
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(dir, "README"), []byte(*readme), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return src
+}
+
+// progress returns the next '$/progress' notification of the session.
+func (c *client) progress() progress {
+	c.t.Helper()
+	var params progress
+	if err := json.Unmarshal(c.until(notification("$/progress")).Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func TestIndexingProgress(t *testing.T) {
+	readme := "4.1. t_relay([host, port])\n\n   Relays the message.\n"
+	src := kamailioSources(t, map[string]*string{"tm": &readme, "broken": nil})
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{
+		Capabilities: lsp.ClientCapabilities{Window: lsp.WindowClientCapabilities{WorkDoneProgress: true}},
+	})
+	c.notify(server.MethodInitialized, struct{}{})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3, KamailioSourcePath: src})
+	create := c.until(func(r reply) bool {
+		return r.Method == server.MethodWorkDoneProgressCreate
+	})
+	var params lsp.WorkDoneProgressCreateParams
+	if err := json.Unmarshal(create.Params, &params); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(params.Token, "kamaizen-indexing-") {
+		t.Fatalf("Expected: an indexing token,\ngot: %s", params.Token)
+	}
+	c.respond(create.ID, nil)
+	if begin := c.progress(); begin.Token != params.Token || begin.Value.Kind != "begin" {
+		t.Fatalf("Expected: the indexing begins,\ngot: %+v", begin)
+	}
+	end := c.until(func(r reply) bool {
+		return r.Method == "$/progress" && strings.Contains(string(r.Params), `"end"`)
+	})
+	expected := "2 modules, 1 functions"
+	if !strings.Contains(string(end.Params), expected) {
+		t.Fatalf("Expected: %s,\ngot: %s", expected, end.Params)
+	}
+	expected = "KamaiZen: cannot read the README of 1 of 2 Kamailio modules"
+	if m := c.message(); m.Type != lsp.MESSAGE_WARNING || m.Message != expected {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, m)
+	}
+}
+
+func TestIndexingPathUnset(t *testing.T) {
+	readme := "4.1. t_relay([host, port])\n\n   Relays the message.\n"
+	src := kamailioSources(t, map[string]*string{"tm": &readme})
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.notify(server.MethodInitialized, struct{}{})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3, KamailioSourcePath: src})
+	status := func(id int) lsp.IndexStatus {
+		c.request(id, server.MethodStatus, nil)
+		var status lsp.Status
+		if err := json.Unmarshal(c.response(lsp.NewIntID(id)).Result, &status); err != nil {
+			t.Fatal(err)
+		}
+		return status.Index
+	}
+	for id := 2; status(id).Modules != 1; id++ {
+		if id > 100 {
+			t.Fatalf("Expected: the tm module indexed,\ngot: %+v", status(id))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.notify(server.MethodDidChangeConfiguration, map[string]any{"settings": nil})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3})
+	if m := c.message(); m.Type != lsp.MESSAGE_WARNING || !strings.Contains(m.Message, "kamailioSourcePath is not set") {
+		t.Fatalf("Expected: the sources path warning,\ngot: %+v", m)
+	}
+	// the documentation of the previous sources is not served anymore
+	if index := status(200); index.Modules != 0 || index.Functions != 0 {
+		t.Fatalf("Expected: an empty index,\ngot: %+v", index)
+	}
+}
+
+func TestIndexingInvalidPath(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.notify(server.MethodInitialized, struct{}{})
+	missing := filepath.Join(t.TempDir(), "missing")
+	c.configure(lsp.ConfigurationObject{Loglevel: 3, KamailioSourcePath: missing})
+	m := c.message()
+	if m.Type != lsp.MESSAGE_ERROR || !strings.HasSuffix(m.Message, "check kamailioSourcePath ("+missing+")") {
+		t.Fatalf("Expected: an error naming %s,\ngot: %+v", missing, m)
+	}
+}
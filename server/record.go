@@ -0,0 +1,30 @@
+package server
+
+import (
+	"KamaiZen/rpc"
+	"io"
+)
+
+// recorder records the messages exchanged with the clients, nil unless recording
+var recorder *rpc.Recorder
+
+// SetRecorder records the messages exchanged by the sessions started afterwards with the given recorder.
+//
+// Parameters:
+//
+//	r *rpc.Recorder - The recorder, nil to stop recording.
+func SetRecorder(r *rpc.Recorder) {
+	recorder = r
+}
+
+// recordingWriter records the messages written to the output of a session.
+// The session writer writes a single framed message per call.
+type recordingWriter struct {
+	out     io.Writer
+	session int
+}
+
+func (w recordingWriter) Write(frame []byte) (int, error) {
+	recorder.Record(w.session, rpc.Outgoing, frame)
+	return w.out.Write(frame)
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/rpc"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// replay_request_timeout bounds the wait for the server request a recorded client response answers.
+const replay_request_timeout = 5 * time.Second
+
+// Replay feeds the client messages of a recording back through new sessions, one per recorded session,
+// and compares the messages the sessions send with the recorded ones. The differences are written to out.
+// Messages are matched by kind, method and ID, or by order for notifications, so that the replies of
+// concurrent requests may come in any order. The '$/logTrace' notifications are not compared.
+//
+// Parameters:
+//
+//	records []rpc.Record - The records of the recording.
+//	out io.Writer - The output the differences are written to.
+//
+// Returns:
+//
+//	int - The number of differences.
+func Replay(records []rpc.Record, out io.Writer) int {
+	var ids []int
+	bySession := make(map[int][]rpc.Record)
+	for _, record := range records {
+		if _, found := bySession[record.Session]; !found {
+			ids = append(ids, record.Session)
+		}
+		bySession[record.Session] = append(bySession[record.Session], record)
+	}
+	differences := 0
+	for _, id := range ids {
+		fmt.Fprintf(out, "session %d\n", id)
+		var expected []json.RawMessage
+		for _, record := range bySession[id] {
+			if record.Direction == rpc.Outgoing {
+				expected = append(expected, record.Message)
+			}
+		}
+		differences += diffMessages(expected, replaySession(bySession[id]), out)
+	}
+	fmt.Fprintf(out, "%d difference(s)\n", differences)
+	return differences
+}
+
+// replaySession runs a new session fed with the client messages of the given records,
+// and returns the messages it sent.
+func replaySession(records []rpc.Record) []json.RawMessage {
+	in, feed := io.Pipe()
+	output := newReplayOutput()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		Serve(in, output)
+		// unblock the feeder if the session exited before reading every message
+		in.Close()
+	}()
+	for _, record := range records {
+		if record.Direction != rpc.Incoming {
+			continue
+		}
+		var message lsp.Message
+		if json.Unmarshal(record.Message, &message) == nil && message.IsResponse() {
+			// the response must not reach the session before the request it answers was sent
+			if !output.waitRequest(message.ID, replay_request_timeout) {
+				log.Warn().Str("id", message.ID.String()).Msg("Replayed response answers no request")
+			}
+		}
+		if _, err := feed.Write([]byte(rpc.EncodeMessage(record.Message))); err != nil {
+			break
+		}
+	}
+	feed.Close()
+	<-done
+	return output.messages()
+}
+
+// replayOutput collects the messages written by a replayed session.
+type replayOutput struct {
+	mu       sync.Mutex
+	contents []json.RawMessage
+	requests map[string]bool // the IDs of the requests sent to the client
+	changed  chan struct{}   // closed and replaced whenever a message is written
+}
+
+func newReplayOutput() *replayOutput {
+	return &replayOutput{
+		requests: make(map[string]bool),
+		changed:  make(chan struct{}),
+	}
+}
+
+// Write collects a framed message, the session writer writes a single message per call.
+func (o *replayOutput) Write(frame []byte) (int, error) {
+	_, content, err := rpc.DecodeMessage(frame)
+	if err != nil {
+		return 0, err
+	}
+	var message lsp.Message
+	json.Unmarshal(content, &message)
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.contents = append(o.contents, slices.Clone(content))
+	if message.IsRequest() {
+		o.requests[message.ID.String()] = true
+	}
+	close(o.changed)
+	o.changed = make(chan struct{})
+	return len(frame), nil
+}
+
+// waitRequest waits until the session sent the request with the given ID, or the timeout expires.
+func (o *replayOutput) waitRequest(id lsp.ID, timeout time.Duration) bool {
+	expired := time.After(timeout)
+	for {
+		o.mu.Lock()
+		sent, changed := o.requests[id.String()], o.changed
+		o.mu.Unlock()
+		if sent {
+			return true
+		}
+		select {
+		case <-changed:
+		case <-expired:
+			return false
+		}
+	}
+}
+
+// messages returns the collected messages, in the order they were written.
+func (o *replayOutput) messages() []json.RawMessage {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.contents)
+}
+
+// diffMessages writes the differences between the expected and the actual messages to out,
+// and returns their number.
+func diffMessages(expected []json.RawMessage, actual []json.RawMessage, out io.Writer) int {
+	expectedKeys, expectedByKey := keyMessages(expected)
+	actualKeys, actualByKey := keyMessages(actual)
+	differences := 0
+	for _, key := range expectedKeys {
+		got, found := actualByKey[key]
+		switch {
+		case !found:
+			fmt.Fprintf(out, "- %s %s\n", key, expectedByKey[key])
+		case !sameJSON(expectedByKey[key], got):
+			fmt.Fprintf(out, "- %s %s\n+ %s %s\n", key, expectedByKey[key], key, got)
+		default:
+			continue
+		}
+		differences++
+	}
+	for _, key := range actualKeys {
+		if _, found := expectedByKey[key]; !found {
+			fmt.Fprintf(out, "+ %s %s\n", key, actualByKey[key])
+			differences++
+		}
+	}
+	return differences
+}
+
+// keyMessages keys the given messages for comparison: responses by ID, requests by method and ID,
+// notifications by method, document and order, responses without ID by order.
+// The '$/logTrace' notifications are skipped.
+func keyMessages(messages []json.RawMessage) ([]string, map[string]json.RawMessage) {
+	var keys []string
+	byKey := make(map[string]json.RawMessage)
+	seen := make(map[string]int)
+	for _, content := range messages {
+		var message struct {
+			lsp.Message
+			Params struct {
+				URI          string `json:"uri"`
+				TextDocument struct {
+					URI string `json:"uri"`
+				} `json:"textDocument"`
+			} `json:"params"`
+		}
+		json.Unmarshal(content, &message)
+		var key string
+		switch {
+		case message.Method == "$/logTrace":
+			continue
+		case message.IsResponse() && message.ID.IsValid():
+			key = "response (" + message.ID.String() + ")"
+		case message.IsRequest():
+			key = "request " + message.Method + " (" + message.ID.String() + ")"
+		default:
+			// notifications, and error responses to messages without an ID
+			key = "notification " + message.Method
+			if message.IsResponse() {
+				key = "response"
+			}
+			if uri := message.Params.URI + message.Params.TextDocument.URI; uri != "" {
+				key += " " + uri
+			}
+			seen[key]++
+			key = fmt.Sprintf("%s #%d", key, seen[key])
+		}
+		keys = append(keys, key)
+		byKey[key] = content
+	}
+	return keys, byKey
+}
+
+// sameJSON checks whether two JSON documents hold the same values.
+func sameJSON(a, b json.RawMessage) bool {
+	var x, y any
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return string(a) == string(b)
+	}
+	return reflect.DeepEqual(x, y)
+}
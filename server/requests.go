@@ -0,0 +1,127 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ClientResponse holds the outcome of a request sent by the server to the client.
+// Exactly one of Result and Error is set.
+type ClientResponse struct {
+	Result json.RawMessage
+	Error  *lsp.ResponseError
+}
+
+// ResponseCallback is called once the client answered a request sent by the server.
+type ResponseCallback func(response ClientResponse)
+
+// RequestManager keeps track of the requests sent by the server to the client.
+// Every request gets an ID from the manager's own counter, and the response of the client
+// is routed back to the callback registered for that ID.
+type RequestManager struct {
+	mu      sync.Mutex
+	nextID  int
+	pending map[string]ResponseCallback
+	writer  *lsp.Writer // the writer of the connection the requests are sent to
+}
+
+// NewRequestManager creates and returns a new RequestManager instance sending the requests to the given writer.
+func NewRequestManager(writer *lsp.Writer) *RequestManager {
+	return &RequestManager{
+		pending: make(map[string]ResponseCallback),
+		writer:  writer,
+	}
+}
+
+// Send sends a request to the client and registers the callback to be called with its response.
+// The callback is called on the goroutine reading the client messages, so it must not block.
+//
+// Parameters:
+//
+//	method string - The method of the request.
+//	params any - The parameters of the request.
+//	callback ResponseCallback - The function called with the response, may be nil.
+//
+// Returns:
+//
+//	lsp.ID - The ID of the sent request.
+func (rm *RequestManager) Send(method string, params any, callback ResponseCallback) lsp.ID {
+	rm.mu.Lock()
+	rm.nextID++
+	id := lsp.NewIntID(rm.nextID)
+	if callback != nil {
+		rm.pending[id.String()] = callback
+	}
+	rm.mu.Unlock()
+	log.Debug().Str("method", method).Str("id", id.String()).Msg("Sending request to client")
+	rm.writer.WriteResponse(lsp.NewRequestMessage(id, method, params))
+	return id
+}
+
+// Call sends a request to the client and waits for its response.
+// It must not be called from the goroutine reading the client messages, as that would deadlock.
+//
+// Parameters:
+//
+//	ctx context.Context - The context bounding the wait for the response.
+//	method string - The method of the request.
+//	params any - The parameters of the request.
+//
+// Returns:
+//
+//	json.RawMessage - The result of the request.
+//	error - The error returned by the client, or the context error.
+func (rm *RequestManager) Call(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	responses := make(chan ClientResponse, 1)
+	id := rm.Send(method, params, func(response ClientResponse) {
+		responses <- response
+	})
+	select {
+	case response := <-responses:
+		if response.Error != nil {
+			return nil, response.Error
+		}
+		return response.Result, nil
+	case <-ctx.Done():
+		rm.forget(id)
+		return nil, ctx.Err()
+	}
+}
+
+// HandleResponse routes a response received from the client to the callback of the matching request.
+//
+// Parameters:
+//
+//	contents []byte - The contents of the response as a byte slice.
+//
+// Returns:
+//
+//	bool - True if the response matched a pending request, false otherwise.
+func (rm *RequestManager) HandleResponse(contents []byte) bool {
+	var response lsp.ResponseMessage
+	if e := json.Unmarshal(contents, &response); e != nil {
+		log.Error().Err(e).Msg("Error unmarshalling client response")
+		return false
+	}
+	rm.mu.Lock()
+	callback, found := rm.pending[response.ID.String()]
+	delete(rm.pending, response.ID.String())
+	rm.mu.Unlock()
+	if !found {
+		log.Warn().Str("id", response.ID.String()).Msg("Received response for unknown request")
+		return false
+	}
+	callback(ClientResponse{Result: response.Result, Error: response.Error})
+	return true
+}
+
+// forget drops the callback registered for the given request ID.
+func (rm *RequestManager) forget(id lsp.ID) {
+	rm.mu.Lock()
+	delete(rm.pending, id.String())
+	rm.mu.Unlock()
+}
@@ -0,0 +1,71 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// clientResponse returns the contents of a response of the client to the request with the given ID.
+func clientResponse(id lsp.ID, result string) []byte {
+	return []byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"result":%s}`, id, result))
+}
+
+func TestResponsesRoutedByID(t *testing.T) {
+	writer, replies := startWriter(t)
+	requests := server.NewRequestManager(writer)
+	results := map[string]string{}
+	first := requests.Send("workspace/configuration", nil, func(response server.ClientResponse) {
+		results["first"] = string(response.Result)
+	})
+	second := requests.Send("window/workDoneProgress/create", nil, func(response server.ClientResponse) {
+		results["second"] = string(response.Result)
+	})
+	for _, id := range []lsp.ID{first, second} {
+		if r := nextReply(t, replies); r.ID != id || r.Method == "" {
+			t.Fatalf("Expected: a request with ID %s,\ngot: %+v", id, r)
+		}
+	}
+	// the client answers out of order
+	if !requests.HandleResponse(clientResponse(second, "2")) || !requests.HandleResponse(clientResponse(first, "1")) {
+		t.Fatalf("Expected: the responses matched,\ngot: unknown requests")
+	}
+	if results["first"] != "1" || results["second"] != "2" {
+		t.Fatalf("Expected: map[first:1 second:2],\ngot: %v", results)
+	}
+	if requests.HandleResponse(clientResponse(first, "1")) {
+		t.Fatalf("Expected: the request forgotten once answered,\ngot: matched again")
+	}
+}
+
+func TestClientResponseError(t *testing.T) {
+	writer, replies := startWriter(t)
+	requests := server.NewRequestManager(writer)
+	go func() {
+		r := <-replies
+		requests.HandleResponse([]byte(fmt.Sprintf(`{"jsonrpc":"2.0","id":%s,"error":{"code":-32601,"message":"Unhandled method"}}`, r.ID)))
+	}()
+	_, err := requests.Call(context.Background(), "workspace/configuration", nil)
+	var responseError *lsp.ResponseError
+	if !errors.As(err, &responseError) || responseError.Code != lsp.METHOD_NOT_FOUND {
+		t.Fatalf("Expected: %d,\ngot: %v", lsp.METHOD_NOT_FOUND, err)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	writer, replies := startWriter(t)
+	requests := server.NewRequestManager(writer)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := requests.Call(ctx, "workspace/configuration", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected: %s,\ngot: %v", context.DeadlineExceeded, err)
+	}
+	r := nextReply(t, replies)
+	if requests.HandleResponse(clientResponse(r.ID, "[]")) {
+		t.Fatalf("Expected: the late response dropped,\ngot: matched")
+	}
+}
@@ -1,42 +1,127 @@
 package server
 
 import (
-	"KamaiZen/document_manager"
+	"KamaiZen/lsp"
 	"KamaiZen/rpc"
 	"KamaiZen/settings"
+	"KamaiZen/state_manager"
 	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"os"
+	"io"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
-type Server struct {
-	eventManager *EventManager
+// Session serves a single client connection: it reads the messages sent by the client and
+// dispatches them to the registered handlers. Every session has its own writer, documents and
+// handlers, settings and Kamailio module documentation, only the log level is shared between sessions.
+// The lifecycle flags are only touched by the goroutine reading the client messages.
+type Session struct {
+	id                int
+	ctx               context.Context // cancelled once the session stops, it cancels the analyses still running
+	stop              context.CancelFunc
+	in                io.Reader
+	writer            *lsp.Writer
+	tracer            *Tracer
+	stats             *Statistics
+	state             *state_manager.State
+	eventManager      *EventManager
+	requests          *RequestManager
+	initialized       bool // set once the client sent an 'initialize' request
+	shutdownRequested bool // set once the client sent a 'shutdown' request
+	exited            bool // set once the client sent an 'exit' notification
+	exitCode          int
+
+	// the following fields are only touched by the handlers running on the ordered queue
+	clientCapabilities lsp.ClientCapabilities                 // the capabilities sent in the 'initialize' request
+	watched            map[string]bool                        // the glob patterns of the files watched by the client, nil before 'initialized'
+	registrations      int                                    // the number of capabilities registered on the client
+	configured         bool                                   // set once the client configuration was applied
+	scans              map[lsp.DocumentURI]context.CancelFunc // cancels the scans of the workspace folders
+	indexing           context.CancelFunc                     // cancels the indexing of the Kamailio modules, nil if none ran
+
+	progressTokens atomic.Int64 // the number of progresses created on the client
 }
 
-// create a single instance of the server
-var serverInstance *Server
+// sessions counts the sessions started, it numbers them in the logs and the recording
+var sessions atomic.Int64
 
-// GetServerInstance returns the single instance of the server.
-func GetServerInstance() *Server {
-	if serverInstance == nil {
-		serverInstance = &Server{
-			eventManager: NewEventManager(),
-		}
+// NewSession creates and returns a new session reading the client messages from in
+// and writing the server messages to out.
+//
+// Parameters:
+//
+//	in io.Reader - The input of the client connection.
+//	out io.Writer - The output of the client connection.
+//
+// Returns:
+//
+//	*Session - The initialized session.
+func NewSession(in io.Reader, out io.Writer) *Session {
+	id := int(sessions.Add(1))
+	if recorder != nil {
+		out = recordingWriter{out: out, session: id}
+	}
+	writer := lsp.NewWriter(out)
+	tracer := NewTracer(writer)
+	stats := NewStatistics()
+	state := state_manager.NewState()
+	eventManager := NewEventManager(writer, tracer, stats)
+	eventManager.documentVersion = state.DocumentVersion
+	ctx, stop := context.WithCancel(context.Background())
+	return &Session{
+		id:           id,
+		ctx:          ctx,
+		stop:         stop,
+		in:           in,
+		writer:       writer,
+		tracer:       tracer,
+		stats:        stats,
+		state:        state,
+		eventManager: eventManager,
+		requests:     NewRequestManager(writer),
+		scans:        make(map[lsp.DocumentURI]context.CancelFunc),
 	}
-	return serverInstance
 }
 
-// StartServer starts the language server and listens for incoming messages from the client.
+// Serve runs a session over the given connection until the client exits or closes the connection.
+//
+// Parameters:
+//
+//	in io.Reader - The input of the client connection.
+//	out io.Writer - The output of the client connection.
+//
+// Returns:
+//
+//	int - The exit code of the session.
+func Serve(in io.Reader, out io.Writer) int {
+	session := NewSession(in, out)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go session.StartServer(&wg)
+	go session.writer.Start(&wg)
+	wg.Wait()
+	return session.ExitCode()
+}
+
+// StartServer starts the session and listens for incoming messages from the client.
 // It initializes the event manager, registers handlers for various methods, and processes incoming messages.
+// The loop ends when the client sends an 'exit' notification or closes the connection, after which the
+// dispatched handlers are awaited, the writer is stopped and the wait group is signalled.
 //
 // Parameters:
 //
-//	wg *sync.WaitGroup - The wait group to signal when the server is done.
-//	analyser_channel chan state_manager.State - The channel for communicating with the state manager.
-func (s *Server) StartServer(wg *sync.WaitGroup) {
+//	wg *sync.WaitGroup - The wait group to signal when the session is done.
+func (s *Session) StartServer(wg *sync.WaitGroup) {
 	defer wg.Done()
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := bufio.NewScanner(s.in)
 	log.Info().Msg("Starting server")
 	scanner.Split(rpc.Split)
 
@@ -44,38 +129,379 @@ func (s *Server) StartServer(wg *sync.WaitGroup) {
 	s.RegisterDefaultHandlers()
 
 	for scanner.Scan() {
-		msg := scanner.Bytes()
-		method, contents, e := rpc.DecodeMessage(msg)
-		if e != nil {
-			log.Error().Err(e).Msg("Error decoding message")
-			continue
+		s.read(scanner.Bytes())
+		if s.exited {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		log.Error().Err(err).Msg("Error reading from the client")
+	}
+	if !s.exited {
+		log.Info().Msg("Client connection closed, exiting")
+		s.exit()
+	}
+	s.StopServer()
+}
+
+// read handles a framed message received from the client.
+// A panic is logged and the message dropped, so that a bad message does not end the session.
+func (s *Session) read(msg []byte) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Error().
+				Str("panic", fmt.Sprint(recovered)).
+				Str("stack", string(debug.Stack())).
+				Msg("Panic reading a client message")
 		}
-		handleMessage(method, contents, s.eventManager)
+	}()
+	if recorder != nil {
+		recorder.Record(s.id, rpc.Incoming, msg)
+	}
+	method, contents, e := rpc.DecodeMessage(msg)
+	if e != nil {
+		log.Error().Err(e).Msg("Error decoding message")
+		s.writer.WriteResponse(lsp.NewErrorResponse(lsp.ID{}, lsp.PARSE_ERROR, e.Error()))
+		return
+	}
+	var message lsp.Message
+	if e := json.Unmarshal(contents, &message); e != nil {
+		log.Error().Err(e).Str("method", method).Msg("Error decoding message envelope")
+		s.writer.WriteResponse(lsp.NewErrorResponse(lsp.ID{}, lsp.INVALID_REQUEST, e.Error()))
+		return
+	}
+	s.tracer.Received(message, contents)
+	if !s.accept(message) {
+		return
+	}
+	if message.IsResponse() {
+		s.requests.HandleResponse(contents)
+		return
 	}
+	handleMessage(message, contents, s.eventManager)
 }
 
-func (s *Server) RegisterDefaultHandlers() {
-	s.RegisterHandler(MethodInitialize, handleInitialize)
-	s.RegisterHandler(MethodInitialized, handleInitialized)
-	s.RegisterHandler(MethodDidOpen, handleDidOpen)
-	s.RegisterHandler(MethodDidChange, handleDidChange)
-	s.RegisterHandler(MethodDefinition, handleDefinition)
-	s.RegisterHandler(MethodFormatting, handleFormatting)
-	s.RegisterHandler(MethodConfigurationResponse, handleWorkspaceConfiguration)
+func (s *Session) RegisterDefaultHandlers() {
+	s.RegisterOrderedRequestHandler(MethodInitialize, s.handleInitialize)
+	s.RegisterHandler(MethodInitialized, s.handleInitialized)
+	s.RegisterOrderedRequestHandler(MethodShutdown, s.handleShutdown)
+	s.RegisterInlineHandler(MethodExit, s.handleExit)
+	s.RegisterInlineHandler(MethodCancelRequest, s.handleCancelRequest)
+	s.RegisterInlineHandler(MethodSetTrace, s.handleSetTrace)
+	s.RegisterHandler(MethodDidOpen, s.handleDidOpen)
+	s.RegisterHandler(MethodDidChange, s.handleDidChange)
+	s.RegisterHandler(MethodDidClose, s.handleDidClose)
+	s.RegisterHandler(MethodDidSave, s.handleDidSave)
+	s.RegisterHandler(MethodDidChangeWatchedFiles, s.handleDidChangeWatchedFiles)
+	s.RegisterHandler(MethodDidChangeConfiguration, s.handleDidChangeConfiguration)
+	s.RegisterHandler(MethodDidChangeWorkspaceFolders, s.handleDidChangeWorkspaceFolders)
+	s.RegisterRequestHandler(MethodDefinition, s.handleDefinition)
+	s.RegisterRequestHandler(MethodReferences, s.handleReferences)
+	s.RegisterRequestHandler(MethodDocumentHighlight, s.handleDocumentHighlight)
+	s.RegisterRequestHandler(MethodPrepareRename, s.handlePrepareRename)
+	s.RegisterRequestHandler(MethodRename, s.handleRename)
+	s.RegisterRequestHandler(MethodDocumentSymbol, s.handleDocumentSymbol)
+	s.RegisterRequestHandler(MethodWorkspaceSymbol, s.handleWorkspaceSymbol)
+	s.RegisterRequestHandler(MethodPrepareCallHierarchy, s.handlePrepareCallHierarchy)
+	s.RegisterRequestHandler(MethodIncomingCalls, s.handleIncomingCalls)
+	s.RegisterRequestHandler(MethodOutgoingCalls, s.handleOutgoingCalls)
+	s.RegisterRequestHandler(MethodFoldingRange, s.handleFoldingRange)
+	s.RegisterRequestHandler(MethodSemanticTokens, s.handleSemanticTokens)
+	s.RegisterRequestHandler(MethodSemanticTokensDelta, s.handleSemanticTokensDelta)
+	s.RegisterRequestHandler(MethodSemanticTokensRange, s.handleSemanticTokensRange)
+	s.RegisterRequestHandler(MethodFormatting, s.handleFormatting)
+	s.RegisterRequestHandler(MethodHover, s.handleHover)
+	s.RegisterRequestHandler(MethodCompletion, s.handleCompletion)
+	s.RegisterRequestHandler(MethodStatus, s.handleStatus)
 }
 
-func (s *Server) StopServer() {
+// accept checks whether a message may be dispatched in the current lifecycle state of the server,
+// and records the lifecycle transitions triggered by 'initialize' and 'shutdown'.
+// Requests that are refused get an error reply, refused notifications and responses are dropped.
+//
+// Parameters:
+//
+//	message lsp.Message - The envelope of the received message.
+//
+// Returns:
+//
+//	bool - True if the message should be dispatched, false otherwise.
+func (s *Session) accept(message lsp.Message) bool {
+	var code lsp.ErrorCode
+	var reason string
+	switch {
+	case message.Method == MethodExit:
+		return true
+	case s.shutdownRequested:
+		code, reason = lsp.INVALID_REQUEST, "Server is shutting down"
+	case !s.initialized && message.Method != MethodInitialize:
+		code, reason = lsp.SERVER_NOT_INITIALIZED, "Server is not initialized"
+	case s.initialized && message.Method == MethodInitialize:
+		code, reason = lsp.INVALID_REQUEST, "Server is already initialized"
+	default:
+		switch message.Method {
+		case MethodInitialize:
+			s.initialized = true
+		case MethodShutdown:
+			s.shutdown()
+		}
+		return true
+	}
+	if message.IsRequest() {
+		log.Warn().Str("method", message.Method).Str("reason", reason).Msg("Rejecting request")
+		s.writer.WriteResponse(lsp.NewErrorResponse(message.ID, code, reason))
+		return false
+	}
+	log.Warn().Str("method", message.Method).Str("reason", reason).Msg("Dropping message")
+	return false
+}
+
+// StopServer cancels the analyses still running and waits for the dispatched handlers to finish, cancels the scans
+// of the workspace folders and the indexing of the Kamailio modules, then stops the writer, flushing the messages
+// that are still pending.
+func (s *Session) StopServer() {
 	log.Info().Msg("Stopping server")
+	s.stop()
+	s.eventManager.Stop()
+	for _, cancel := range s.scans {
+		cancel()
+	}
+	if s.indexing != nil {
+		s.indexing()
+	}
+	s.writer.Stop()
+}
+
+// shutdown marks the server as shut down, every request but 'exit' is rejected afterwards.
+func (s *Session) shutdown() {
+	s.shutdownRequested = true
+}
+
+// exit marks the server as exited and sets the exit code.
+// The exit code is 0 if a shutdown was requested before, 1 otherwise.
+func (s *Session) exit() {
+	s.exited = true
+	if s.shutdownRequested {
+		s.exitCode = 0
+	} else {
+		s.exitCode = 1
+	}
+}
+
+// ExitCode returns the code the session exited with.
+func (s *Session) ExitCode() int {
+	return s.exitCode
 }
 
-func (s *Server) RegisterHandler(method string, handler func(contents []byte)) {
+func (s *Session) RegisterHandler(method string, handler NotificationHandler) {
 	s.eventManager.RegisterHandler(method, handler)
 }
 
-func (s *Server) addKamailioMethods(settings settings.LSPSettings) {
+func (s *Session) RegisterInlineHandler(method string, handler NotificationHandler) {
+	s.eventManager.RegisterInlineHandler(method, handler)
+}
+
+func (s *Session) RegisterRequestHandler(method string, handler RequestHandler) {
+	s.eventManager.RegisterRequestHandler(method, handler)
+}
+
+func (s *Session) RegisterOrderedRequestHandler(method string, handler RequestHandler) {
+	s.eventManager.RegisterOrderedRequestHandler(method, handler)
+}
+
+// fetchConfiguration asks the client for the 'kamaizen' configuration section.
+// The response is handled by handleWorkspaceConfiguration.
+func (s *Session) fetchConfiguration() {
+	s.requests.Send(MethodConfiguration, lsp.ConfigurationParams{
+		Items: []lsp.ConfigurationItem{
+			{
+				Section: "kamaizen",
+			},
+		},
+	}, s.handleWorkspaceConfiguration)
+}
+
+// applySettings puts the given settings in effect for the session and applies what changed since the previous ones.
+// The log level is shared by the sessions of the process, the last configured one wins.
+// The Kamailio sources are indexed again when their path changes, and the documents are analysed
+// again, publishing the diagnostics of the open ones, when the diagnostics settings change.
+// The workspace folders are scanned again when the globs of the configuration files change.
+// The user is warned if the first configuration of the session does not set the sources path.
+func (s *Session) applySettings(lspSettings settings.LSPSettings) {
+	previous := s.state.ApplySettings(lspSettings)
+	zerolog.SetGlobalLevel(zerolog.Level(lspSettings.LogLevel))
+	first := !s.configured
+	s.configured = true
+	if s.state.SetConfigGlobs(lspSettings.ConfigGlobs) {
+		s.scanWorkspace(s.state.WorkspaceFolders()...)
+		s.watchFiles()
+	}
+	if lspSettings.KamailioSourcePath != previous.KamailioSourcePath ||
+		(first && lspSettings.KamailioSourcePath == "") {
+		s.addKamailioMethods(lspSettings)
+	}
+	if lspSettings.EnableDiagnostics != previous.EnableDiagnostics ||
+		lspSettings.DeprecatedCommentHints != previous.DeprecatedCommentHints {
+		log.Info().Msg("Diagnostics settings changed, analysing the documents again")
+		for uri, diagnostics := range s.state.AnalyseAll(s.ctx) {
+			s.publishDiagnostics(uri, diagnostics)
+		}
+	}
+}
+
+// addKamailioMethods indexes the module documentation found in the Kamailio sources into the index
+// of the session, which backs the hover and completion methods. Indexing runs in the background, replacing the running
+// indexing, and requests are served with whatever has been indexed so far.
+// The indexing progress is reported to the client if it supports it, and the configuration
+// problems found while indexing are shown to the user.
+// Unsetting the sources path drops the documentation indexed so far.
+func (s *Session) addKamailioMethods(settings settings.LSPSettings) {
+	if s.indexing != nil {
+		s.indexing()
+		s.indexing = nil
+	}
+	if settings.KamailioSourcePath == "" {
+		s.state.Modules().Clear()
+		s.showMessage(lsp.MESSAGE_WARNING,
+			"KamaiZen: kamailioSourcePath is not set, the documentation of the Kamailio modules is not available")
+		return
+	}
 	log.Info().Str("path", settings.KamailioSourcePath).Msg("Kamailio src added")
-	log.Info().Msg("Adding Hover and Completion methods")
-	document_manager.Initialise(settings)
-	s.RegisterHandler(MethodHover, handleHover)
-	s.RegisterHandler(MethodCompletion, handleCompletion)
+	workDoneProgress := s.clientCapabilities.Window.WorkDoneProgress
+	ctx, cancel := context.WithCancel(context.Background())
+	s.indexing = cancel
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				log.Error().
+					Str("panic", fmt.Sprint(recovered)).
+					Str("stack", string(debug.Stack())).
+					Msg("Panic indexing Kamailio modules")
+			}
+		}()
+		progress := s.beginProgress(workDoneProgress, "indexing", "Indexing Kamailio modules")
+		result, err := s.state.Modules().Initialise(ctx, settings, func(scanned int, total int) {
+			progress.Report(scanned, total, fmt.Sprintf("%d/%d modules", scanned, total))
+		})
+		switch {
+		case ctx.Err() != nil:
+			progress.End("Cancelled")
+			return
+		case err != nil:
+			progress.End("Failed")
+			s.showMessage(lsp.MESSAGE_ERROR, fmt.Sprintf("KamaiZen: %s, check kamailioSourcePath (%s)",
+				err.Error(), settings.KamailioSourcePath))
+			return
+		}
+		progress.End(fmt.Sprintf("%d modules, %d functions", result.Modules, result.Functions))
+		if len(result.Unreadable) > 0 {
+			s.showMessage(lsp.MESSAGE_WARNING, fmt.Sprintf("KamaiZen: cannot read the README of %d of %d Kamailio modules",
+				len(result.Unreadable), result.Modules))
+			for _, module := range result.Unreadable {
+				s.logMessage(lsp.MESSAGE_WARNING, "KamaiZen: cannot read the README of the module "+module)
+			}
+		}
+	}()
+}
+
+// scanWorkspace scans the given workspace folders in the background, replacing their running scans.
+// The scan progress is reported to the client if it supports it.
+// folders: The URIs of the folders.
+func (s *Session) scanWorkspace(folders ...lsp.DocumentURI) {
+	workDoneProgress := s.clientCapabilities.Window.WorkDoneProgress
+	for _, folder := range folders {
+		if cancel, found := s.scans[folder]; found {
+			cancel()
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		s.scans[folder] = cancel
+		go func() {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					log.Error().
+						Str("panic", fmt.Sprint(recovered)).
+						Str("stack", string(debug.Stack())).
+						Msg("Panic scanning workspace folder")
+				}
+			}()
+			progress := s.beginProgress(workDoneProgress, "workspace", "Scanning "+string(folder))
+			files, err := s.state.ScanWorkspaceFolder(ctx, folder, func(scanned int, total int) {
+				progress.Report(scanned, total, fmt.Sprintf("%d/%d files", scanned, total))
+			})
+			switch {
+			case ctx.Err() != nil:
+				progress.End("Cancelled")
+			case err != nil:
+				progress.End("Failed")
+				s.logMessage(lsp.MESSAGE_WARNING, fmt.Sprintf("KamaiZen: cannot scan the workspace folder %s: %s", folder, err.Error()))
+			default:
+				progress.End(fmt.Sprintf("%d configuration files", files))
+				log.Info().Str("folder", string(folder)).Int("files", files).Msg("Workspace folder scanned")
+			}
+		}()
+	}
+}
+
+// watchFiles asks the client to watch the Kamailio configuration files and the files they include,
+// if the client supports registering the watchers dynamically.
+// Only the glob patterns that are not watched yet are registered.
+func (s *Session) watchFiles() {
+	if s.watched == nil || !s.clientCapabilities.Workspace.DidChangeWatchedFiles.DynamicRegistration {
+		return
+	}
+	patterns := []string{"**/*.cfg"}
+	for _, glob := range s.state.Settings().ConfigGlobs {
+		if !strings.Contains(glob, "/") {
+			glob = "**/" + glob
+		}
+		patterns = append(patterns, glob)
+	}
+	for _, uri := range s.state.IncludedFiles() {
+		if path, err := uri.Path(); err == nil && filepath.Ext(path) != ".cfg" {
+			patterns = append(patterns, "**/"+filepath.Base(path))
+		}
+	}
+	var watchers []lsp.FileSystemWatcher
+	for _, pattern := range patterns {
+		if s.watched[pattern] {
+			continue
+		}
+		s.watched[pattern] = true
+		watchers = append(watchers, lsp.FileSystemWatcher{GlobPattern: pattern})
+	}
+	if len(watchers) == 0 {
+		return
+	}
+	log.Info().Int("watchers", len(watchers)).Msg("Registering file watchers")
+	s.registerCapability("watched-files", MethodDidChangeWatchedFiles, lsp.DidChangeWatchedFilesRegistrationOptions{
+		Watchers: watchers,
+	})
+}
+
+// watchConfiguration asks the client to send the 'workspace/didChangeConfiguration' notification
+// when the 'kamaizen' configuration section changes, if the client supports registering it dynamically.
+func (s *Session) watchConfiguration() {
+	if !s.clientCapabilities.Workspace.DidChangeConfiguration.DynamicRegistration {
+		return
+	}
+	s.registerCapability("configuration", MethodDidChangeConfiguration, lsp.DidChangeConfigurationRegistrationOptions{
+		Section: "kamaizen",
+	})
+}
+
+// registerCapability sends a 'client/registerCapability' request registering the given method.
+// name: The name of the registration, which prefixes its unique ID.
+// method: The method to register.
+// options: The registration options of the method.
+func (s *Session) registerCapability(name string, method string, options any) {
+	s.registrations++
+	s.requests.Send(MethodRegisterCapability, lsp.RegistrationParams{
+		Registrations: []lsp.Registration{
+			{
+				ID:              fmt.Sprintf("kamaizen-%s-%d", name, s.registrations),
+				Method:          method,
+				RegisterOptions: options,
+			},
+		},
+	}, handleRegistration)
 }
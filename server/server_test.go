@@ -0,0 +1,159 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/rpc"
+	"KamaiZen/server"
+	"io"
+	"testing"
+	"time"
+)
+
+// client drives a session the way an editor does.
+type client struct {
+	t       *testing.T
+	out     io.WriteCloser // the input of the session
+	replies <-chan reply   // the messages written by the session
+	code    chan int       // the exit code of the session, once it ended
+}
+
+// newClient returns a client reading the messages of a session from in and writing its messages to out.
+// The output is closed once the test ends.
+func newClient(t *testing.T, in io.Reader, out io.WriteCloser) *client {
+	t.Cleanup(func() {
+		out.Close()
+	})
+	return &client{t: t, out: out, replies: readReplies(t, in), code: make(chan int, 1)}
+}
+
+// startSession serves a session over pipes and returns its client.
+func startSession(t *testing.T) *client {
+	serverIn, out := io.Pipe()
+	in, serverOut := io.Pipe()
+	c := newClient(t, in, out)
+	go func() {
+		c.code <- server.Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+	return c
+}
+
+// send writes a message to the session.
+func (c *client) send(message map[string]any) {
+	c.t.Helper()
+	message["jsonrpc"] = "2.0"
+	if _, err := io.WriteString(c.out, rpc.EncodeMessage(message)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// request sends a request with the given ID, a number or a string.
+func (c *client) request(id any, method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"id": id, "method": method, "params": params})
+}
+
+// notify sends a notification.
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+// respond answers a request sent by the session.
+func (c *client) respond(id lsp.ID, result any) {
+	c.t.Helper()
+	c.send(map[string]any{"id": id, "result": result})
+}
+
+// until returns the first message written by the session that matches, skipping the other ones.
+func (c *client) until(match func(r reply) bool) reply {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r, ok := <-c.replies:
+			if !ok {
+				c.t.Fatalf("Expected: a matching message,\ngot: the output closed")
+			}
+			if match(r) {
+				return r
+			}
+		case <-timeout:
+			c.t.Fatalf("Expected: a matching message,\ngot: none after 5 seconds")
+		}
+	}
+}
+
+// response returns the response of the request with the given ID.
+func (c *client) response(id lsp.ID) reply {
+	c.t.Helper()
+	return c.until(func(r reply) bool {
+		return r.Method == "" && r.ID == id
+	})
+}
+
+// initialize sends the 'initialize' request with the given parameters and returns its response.
+func (c *client) initialize(params lsp.InitializeRequestParams) reply {
+	c.t.Helper()
+	c.request(1, server.MethodInitialize, params)
+	return c.response(lsp.NewIntID(1))
+}
+
+// exitCode returns the exit code of the session, once it ended.
+func (c *client) exitCode() int {
+	c.t.Helper()
+	select {
+	case code := <-c.code:
+		return code
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("Expected: the session ended,\ngot: still running after 5 seconds")
+	}
+	return 0
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	c := startSession(t)
+	c.request(1, server.MethodHover, nil)
+	if r := c.response(lsp.NewIntID(1)); r.Error == nil || r.Error.Code != lsp.SERVER_NOT_INITIALIZED {
+		t.Fatalf("Expected: %d before initialize,\ngot: %+v", lsp.SERVER_NOT_INITIALIZED, r)
+	}
+	// string IDs are answered as strings
+	c.request("init", server.MethodInitialize, lsp.InitializeRequestParams{})
+	if r := c.response(lsp.NewStringID("init")); r.Error != nil || len(r.Result) == 0 || string(r.Result) == "null" {
+		t.Fatalf("Expected: the server capabilities,\ngot: %+v", r)
+	}
+	c.request("again", server.MethodInitialize, lsp.InitializeRequestParams{})
+	if r := c.response(lsp.NewStringID("again")); r.Error == nil || r.Error.Code != lsp.INVALID_REQUEST {
+		t.Fatalf("Expected: %d for a second initialize,\ngot: %+v", lsp.INVALID_REQUEST, r)
+	}
+	c.request(2, server.MethodShutdown, nil)
+	if r := c.response(lsp.NewIntID(2)); r.Error != nil || string(r.Result) != "null" {
+		t.Fatalf("Expected: a null result,\ngot: %+v", r)
+	}
+	c.request(3, server.MethodHover, nil)
+	if r := c.response(lsp.NewIntID(3)); r.Error == nil || r.Error.Code != lsp.INVALID_REQUEST {
+		t.Fatalf("Expected: %d after shutdown,\ngot: %+v", lsp.INVALID_REQUEST, r)
+	}
+	c.notify(server.MethodExit, nil)
+	if code := c.exitCode(); code != 0 {
+		t.Fatalf("Expected: 0,\ngot: %d", code)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.notify(server.MethodExit, nil)
+	if code := c.exitCode(); code != 1 {
+		t.Fatalf("Expected: 1,\ngot: %d", code)
+	}
+}
+
+func TestConnectionClosed(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.out.Close()
+	if code := c.exitCode(); code != 1 {
+		t.Fatalf("Expected: 1,\ngot: %d", code)
+	}
+}
@@ -0,0 +1,74 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// notification returns a matcher of the notifications with the given method.
+func notification(method string) func(r reply) bool {
+	return func(r reply) bool {
+		return r.Method == method && r.ID == lsp.ID{}
+	}
+}
+
+// configure answers the next 'workspace/configuration' request of the session with the given configuration.
+func (c *client) configure(config lsp.ConfigurationObject) {
+	c.t.Helper()
+	r := c.until(func(r reply) bool {
+		return r.Method == server.MethodConfiguration
+	})
+	c.respond(r.ID, []lsp.ConfigurationObject{config})
+}
+
+// message returns the next message shown to the user.
+func (c *client) message() lsp.MessageParams {
+	c.t.Helper()
+	var params lsp.MessageParams
+	if err := json.Unmarshal(c.until(notification("window/showMessage")).Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+// diagnostics returns the next diagnostics published by the session.
+func (c *client) diagnostics() lsp.PublishDiagnosticParams {
+	c.t.Helper()
+	var params lsp.PublishDiagnosticParams
+	if err := json.Unmarshal(c.until(notification("textDocument/publishDiagnostics")).Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+// open opens a document in the session.
+func (c *client) open(uri lsp.DocumentURI, text string) {
+	c.t.Helper()
+	c.notify(server.MethodDidOpen, lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageId: "kamailio", Version: 1, Text: text},
+	})
+}
+
+func TestDidChangeConfiguration(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.notify(server.MethodInitialized, struct{}{})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3})
+	if m := c.message(); m.Type != lsp.MESSAGE_WARNING || !strings.Contains(m.Message, "kamailioSourcePath is not set") {
+		t.Fatalf("Expected: the sources path warning,\ngot: %+v", m)
+	}
+	uri := lsp.DocumentURI("file://" + filepath.Join(t.TempDir(), "kamailio.cfg"))
+	// the diagnostics are disabled, nothing is published
+	c.open(uri, "include_file \"missing.cfg\"\n")
+	c.notify(server.MethodDidChangeConfiguration, map[string]any{"settings": nil})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3, EnableDiagnostics: true})
+	d := c.diagnostics()
+	expected := "Included file not found: missing.cfg"
+	if d.URI != uri || len(d.Diagnostics) != 1 || d.Diagnostics[0].Message != expected {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, d)
+	}
+}
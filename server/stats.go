@@ -0,0 +1,96 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"slices"
+	"sync"
+	"time"
+)
+
+// recent_errors_size is the number of errors kept for the 'kamaizen/status' request.
+const recent_errors_size = 20
+
+// started is the time the server started, for its uptime.
+var started = time.Now()
+
+// Statistics collects the latency of the handlers of a session, per method, and its recent errors.
+// It is safe for concurrent use.
+type Statistics struct {
+	mu      sync.Mutex
+	methods map[string]*methodStatistics
+	errors  []lsp.RecentError // the most recent errors, oldest first
+}
+
+type methodStatistics struct {
+	count  int
+	errors int
+	total  time.Duration
+	max    time.Duration
+	last   time.Duration
+}
+
+// NewStatistics creates and returns an empty Statistics instance.
+func NewStatistics() *Statistics {
+	return &Statistics{
+		methods: make(map[string]*methodStatistics),
+	}
+}
+
+// Record records a handler run.
+// method: The method of the message handled.
+// elapsed: The time the handler took.
+// err: The error the handler failed with, if any.
+// panicked: Whether the handler panicked.
+func (s *Statistics) Record(method string, elapsed time.Duration, err error, panicked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, found := s.methods[method]
+	if !found {
+		stats = &methodStatistics{}
+		s.methods[method] = stats
+	}
+	stats.count++
+	stats.total += elapsed
+	stats.max = max(stats.max, elapsed)
+	stats.last = elapsed
+	if err == nil {
+		return
+	}
+	stats.errors++
+	s.errors = append(s.errors, lsp.RecentError{
+		Time:    time.Now().UTC(),
+		Method:  method,
+		Message: err.Error(),
+		Panic:   panicked,
+	})
+	if len(s.errors) > recent_errors_size {
+		s.errors = slices.Clone(s.errors[len(s.errors)-recent_errors_size:])
+	}
+}
+
+// Snapshot returns the recent errors, most recent first, and the statistics per method.
+func (s *Statistics) Snapshot() ([]lsp.RecentError, map[string]lsp.MethodStatistics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recent := slices.Clone(s.errors)
+	slices.Reverse(recent)
+	if recent == nil {
+		recent = []lsp.RecentError{}
+	}
+	methods := make(map[string]lsp.MethodStatistics, len(s.methods))
+	for method, stats := range s.methods {
+		methods[method] = lsp.MethodStatistics{
+			Count:     stats.count,
+			Errors:    stats.errors,
+			AverageMs: milliseconds(stats.total / time.Duration(stats.count)),
+			MaxMs:     milliseconds(stats.max),
+			LastMs:    milliseconds(stats.last),
+		}
+	}
+	return recent, methods
+}
+
+// milliseconds converts a duration to milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
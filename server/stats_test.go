@@ -0,0 +1,72 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRecentErrors(t *testing.T) {
+	stats := server.NewStatistics()
+	for i := range 25 {
+		stats.Record("textDocument/hover", time.Millisecond, fmt.Errorf("error %d", i), false)
+	}
+	stats.Record("textDocument/hover", 3*time.Millisecond, nil, false)
+	recent, methods := stats.Snapshot()
+	if len(recent) != 20 || recent[0].Message != "error 24" || recent[19].Message != "error 5" {
+		t.Fatalf("Expected: the 20 most recent errors, most recent first,\ngot: %+v", recent)
+	}
+	hover := methods["textDocument/hover"]
+	if hover.Count != 26 || hover.Errors != 25 || hover.MaxMs != 3 || hover.LastMs != 3 {
+		t.Fatalf("Expected: 26 runs, 25 errors, 3ms max and last,\ngot: %+v", hover)
+	}
+}
+
+func TestHandlerPanic(t *testing.T) {
+	writer, replies := startWriter(t)
+	stats := server.NewStatistics()
+	events := server.NewEventManager(writer, server.NewTracer(writer), stats)
+	defer events.Stop()
+	events.RegisterRequestHandler("kamaizen/panic", func(ctx context.Context, contents []byte) (any, error) {
+		panic("boom")
+	})
+	events.RegisterRequestHandler("kamaizen/ok", func(ctx context.Context, contents []byte) (any, error) {
+		return lsp.Response{RPC: "2.0", ID: lsp.NewIntID(2)}, nil
+	})
+	events.Dispatch(lsp.Message{RPC: "2.0", ID: lsp.NewIntID(1), Method: "kamaizen/panic"}, []byte(`{}`))
+	if r := nextReply(t, replies); r.ID != lsp.NewIntID(1) || r.Error == nil || r.Error.Code != lsp.INTERNAL_ERROR {
+		t.Fatalf("Expected: %d,\ngot: %+v", lsp.INTERNAL_ERROR, r)
+	}
+	// the session keeps serving requests
+	events.Dispatch(lsp.Message{RPC: "2.0", ID: lsp.NewIntID(2), Method: "kamaizen/ok"}, []byte(`{}`))
+	if r := nextReply(t, replies); r.ID != lsp.NewIntID(2) || r.Error != nil {
+		t.Fatalf("Expected: a result,\ngot: %+v", r)
+	}
+	recent, _ := stats.Snapshot()
+	if len(recent) != 1 || !recent[0].Panic || recent[0].Method != "kamaizen/panic" {
+		t.Fatalf("Expected: the panic recorded,\ngot: %+v", recent)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.request(2, server.MethodHover, nil)
+	c.response(lsp.NewIntID(2))
+	c.request(3, server.MethodStatus, nil)
+	r := c.response(lsp.NewIntID(3))
+	var status lsp.Status
+	if err := json.Unmarshal(r.Result, &status); err != nil {
+		t.Fatal(err)
+	}
+	if status.Methods[server.MethodInitialize].Count != 1 || status.Methods[server.MethodHover].Count != 1 {
+		t.Fatalf("Expected: one initialize and one hover request,\ngot: %+v", status.Methods)
+	}
+	if len(status.OpenDocuments) != 0 || status.Version == "" {
+		t.Fatalf("Expected: the version and no open document,\ngot: %+v", status)
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"KamaiZen/lsp"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Tracer sends the execution trace of a session to the client with '$/logTrace',
+// at the level the client sets in the 'initialize' request and with '$/setTrace'.
+// It is safe for concurrent use.
+type Tracer struct {
+	level  atomic.Value // lsp.TraceValue
+	writer *lsp.Writer
+}
+
+// NewTracer creates and returns a new Tracer, off until the client sets its level.
+func NewTracer(writer *lsp.Writer) *Tracer {
+	t := &Tracer{writer: writer}
+	t.level.Store(lsp.TRACE_OFF)
+	return t
+}
+
+// SetLevel sets the trace level, unknown levels turn the trace off.
+// level: The trace level sent by the client.
+func (t *Tracer) SetLevel(level lsp.TraceValue) {
+	switch level {
+	case lsp.TRACE_OFF, lsp.TRACE_MESSAGES, lsp.TRACE_VERBOSE:
+	default:
+		log.Warn().Str("level", string(level)).Msg("Unknown trace level, turning the trace off")
+		level = lsp.TRACE_OFF
+	}
+	log.Debug().Str("level", string(level)).Msg("Trace level set")
+	t.level.Store(level)
+}
+
+// Received traces a message received from the client, with its parameters at the verbose level.
+// message: The envelope of the received message.
+// contents: The contents of the message as a byte slice.
+func (t *Tracer) Received(message lsp.Message, contents []byte) {
+	var text string
+	switch {
+	case message.IsRequest():
+		text = fmt.Sprintf("Received request '%s - (%s)'.", message.Method, message.ID.String())
+	case message.IsResponse():
+		text = fmt.Sprintf("Received response '(%s)'.", message.ID.String())
+	default:
+		text = fmt.Sprintf("Received notification '%s'.", message.Method)
+	}
+	t.log(text, func() string {
+		return string(contents)
+	})
+}
+
+// Replied traces the response sent for a request, with the time the request took.
+// The error is sent along at the verbose level.
+// id: The ID of the request.
+// method: The method of the request.
+// started: The time the request was received.
+// err: The error the request failed with, if any.
+func (t *Tracer) Replied(id lsp.ID, method string, started time.Time, err error) {
+	text := fmt.Sprintf("Sending response '%s - (%s)'. Processing request took %dms.",
+		method, id.String(), time.Since(started).Milliseconds())
+	t.log(text, func() string {
+		if err == nil {
+			return ""
+		}
+		return "Error: " + err.Error()
+	})
+}
+
+// log sends a trace message if the trace is on, the verbose information is only computed at the verbose level.
+func (t *Tracer) log(message string, verbose func() string) {
+	level := t.level.Load().(lsp.TraceValue)
+	if level == lsp.TRACE_OFF {
+		return
+	}
+	details := ""
+	if level == lsp.TRACE_VERBOSE {
+		details = verbose()
+	}
+	t.writer.WriteResponse(lsp.NewLogTraceNotification(message, details))
+}
@@ -0,0 +1,47 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// trace returns the next '$/logTrace' notification of the session.
+func (c *client) trace() lsp.LogTraceParams {
+	c.t.Helper()
+	var params lsp.LogTraceParams
+	if err := json.Unmarshal(c.until(notification("$/logTrace")).Params, &params); err != nil {
+		c.t.Fatal(err)
+	}
+	return params
+}
+
+func TestSetTrace(t *testing.T) {
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.notify(server.MethodSetTrace, lsp.SetTraceParams{Value: lsp.TRACE_VERBOSE})
+	c.request(5, server.MethodHover, nil)
+	received := c.trace()
+	expected := "Received request 'textDocument/hover - (5)'."
+	if received.Message != expected || !strings.Contains(received.Verbose, `"method":"textDocument/hover"`) {
+		t.Fatalf("Expected: %s with the request,\ngot: %+v", expected, received)
+	}
+	expected = "Sending response 'textDocument/hover - (5)'."
+	if sent := c.trace(); !strings.HasPrefix(sent.Message, expected) {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, sent)
+	}
+	// the notification turning the trace off is still traced
+	c.notify(server.MethodSetTrace, lsp.SetTraceParams{Value: lsp.TRACE_OFF})
+	expected = "Received notification '$/setTrace'."
+	if received := c.trace(); received.Message != expected {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, received)
+	}
+	c.request(6, server.MethodHover, nil)
+	if r := c.until(func(r reply) bool {
+		return r.Method == "$/logTrace" || r.ID == lsp.NewIntID(6)
+	}); r.Method != "" {
+		t.Fatalf("Expected: no trace once it is off,\ngot: %s", r.Params)
+	}
+}
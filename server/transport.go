@@ -0,0 +1,75 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Listen accepts client connections on the given address, e.g. tcp://127.0.0.1:9999,
+// and serves every connection with its own session. It only returns if the listener fails.
+//
+// Parameters:
+//
+//	address string - The address to listen on, as a tcp:// URL.
+//
+// Returns:
+//
+//	error - An error if the address is invalid or the listener fails.
+func Listen(address string) error {
+	endpoint, err := url.Parse(address)
+	if err != nil {
+		return err
+	}
+	if endpoint.Scheme != "tcp" || endpoint.Host == "" {
+		return fmt.Errorf("invalid listen address %q, expected tcp://host:port", address)
+	}
+	listener, err := net.Listen("tcp", endpoint.Host)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+	log.Info().Str("address", listener.Addr().String()).Msg("Listening for client connections")
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go serveConnection(conn)
+	}
+}
+
+// DialPipe connects to the named pipe, or unix socket, created by the client at the given path
+// and serves the connection until the client exits.
+//
+// Parameters:
+//
+//	path string - The path of the pipe.
+//
+// Returns:
+//
+//	int - The exit code of the session.
+//	error - An error if the pipe cannot be connected to.
+func DialPipe(path string) (int, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return 1, err
+	}
+	return serveConnection(conn), nil
+}
+
+// serveConnection serves the given connection with a new session, then closes it.
+func serveConnection(conn net.Conn) int {
+	defer conn.Close()
+	remote := conn.RemoteAddr().String()
+	log.Info().Str("remote", remote).Msg("Client connected")
+	code := Serve(conn, conn)
+	log.Info().Str("remote", remote).Int("code", code).Msg("Client disconnected")
+	return code
+}
@@ -0,0 +1,123 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// freeAddress returns a local TCP address nothing listens on.
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	return listener.Addr().String()
+}
+
+// dial connects to the given address, retrying until the server listens.
+func dial(t *testing.T, address string) net.Conn {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", address)
+		if err == nil {
+			return conn
+		}
+		if time.Now().After(deadline) {
+			t.Fatal(err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// shutdown sends the 'shutdown' request then the 'exit' notification, checking the shutdown response.
+func (c *client) shutdown() {
+	c.t.Helper()
+	c.request(2, server.MethodShutdown, nil)
+	if r := c.response(lsp.NewIntID(2)); r.Error != nil {
+		c.t.Fatalf("Expected: a null result,\ngot: %s", r.Error)
+	}
+	c.notify(server.MethodExit, nil)
+}
+
+// closed waits until the session closed the connection.
+func (c *client) closed() {
+	c.t.Helper()
+	for {
+		select {
+		case _, ok := <-c.replies:
+			if !ok {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("Expected: the connection closed,\ngot: still open after 5 seconds")
+		}
+	}
+}
+
+func TestListen(t *testing.T) {
+	address := freeAddress(t)
+	go func() {
+		if err := server.Listen("tcp://" + address); err != nil {
+			t.Errorf("Error: %s", err)
+		}
+	}()
+	// every connection is served by its own session, both are open at once
+	clients := []*client{}
+	for range 2 {
+		conn := dial(t, address)
+		c := newClient(t, conn, conn)
+		c.initialize(lsp.InitializeRequestParams{})
+		clients = append(clients, c)
+	}
+	for _, c := range clients {
+		c.shutdown()
+		c.closed()
+	}
+}
+
+func TestListenInvalidAddress(t *testing.T) {
+	for _, address := range []string{"udp://127.0.0.1:9999", "tcp://", "127.0.0.1:9999"} {
+		if err := server.Listen(address); err == nil {
+			t.Fatalf("Expected: an error for %s,\ngot: nil", address)
+		}
+	}
+}
+
+func TestDialPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kamaizen.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	codes := make(chan int, 1)
+	go func() {
+		code, err := server.DialPipe(path)
+		if err != nil {
+			t.Errorf("Error: %s", err)
+		}
+		codes <- code
+	}()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t, conn, conn)
+	c.initialize(lsp.InitializeRequestParams{})
+	c.shutdown()
+	c.closed()
+	if code := <-codes; code != 0 {
+		t.Fatalf("Expected: 0,\ngot: %d", code)
+	}
+}
+
+func TestDialMissingPipe(t *testing.T) {
+	if _, err := server.DialPipe(filepath.Join(t.TempDir(), "missing.sock")); err == nil {
+		t.Fatalf("Expected: an error,\ngot: nil")
+	}
+}
@@ -0,0 +1,58 @@
+package server_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/server"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// symbols returns the workspace symbols matching the query.
+func (c *client) symbols(id int, query string) []lsp.SymbolInformation {
+	c.t.Helper()
+	c.request(id, server.MethodWorkspaceSymbol, lsp.WorkspaceSymbolParams{Query: query})
+	var symbols []lsp.SymbolInformation
+	if err := json.Unmarshal(c.response(lsp.NewIntID(id)).Result, &symbols); err != nil {
+		c.t.Fatal(err)
+	}
+	return symbols
+}
+
+func TestWorkspaceFolders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "routing.cfg"), []byte("route[AUTH] {\n\texit;\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	folder := lsp.WorkspaceFolder{URI: lsp.DocumentURI("file://" + dir), Name: "kamailio"}
+	c := startSession(t)
+	c.initialize(lsp.InitializeRequestParams{
+		Capabilities:     lsp.ClientCapabilities{Window: lsp.WindowClientCapabilities{WorkDoneProgress: true}},
+		WorkspaceFolders: []lsp.WorkspaceFolder{folder},
+	})
+	c.notify(server.MethodInitialized, struct{}{})
+	c.configure(lsp.ConfigurationObject{Loglevel: 3})
+	create := c.until(func(r reply) bool {
+		return r.Method == server.MethodWorkDoneProgressCreate && strings.Contains(string(r.Params), "kamaizen-workspace-")
+	})
+	c.respond(create.ID, nil)
+	end := c.until(func(r reply) bool {
+		return r.Method == "$/progress" && strings.Contains(string(r.Params), `"end"`)
+	})
+	expected := "1 configuration files"
+	if !strings.Contains(string(end.Params), expected) {
+		t.Fatalf("Expected: %s,\ngot: %s", expected, end.Params)
+	}
+	symbols := c.symbols(2, "AUTH")
+	if len(symbols) != 1 || !strings.HasSuffix(string(symbols[0].Location.URI), "/routing.cfg") {
+		t.Fatalf("Expected: route[AUTH] in routing.cfg,\ngot: %+v", symbols)
+	}
+	c.notify(server.MethodDidChangeWorkspaceFolders, lsp.DidChangeWorkspaceFoldersParams{
+		Event: lsp.WorkspaceFoldersChangeEvent{Removed: []lsp.WorkspaceFolder{folder}},
+	})
+	if symbols := c.symbols(3, "AUTH"); len(symbols) != 0 {
+		t.Fatalf("Expected: no symbol once the folder is removed,\ngot: %+v", symbols)
+	}
+}
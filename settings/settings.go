@@ -1,18 +1,16 @@
 package settings
 
-import "github.com/rs/zerolog"
-
 type LSPSettings struct {
-	KamailioSourcePath     string `json:"kamailioSourcePath"`
-	LogLevel               int    `json:"logLevel"`
-	DeprecatedCommentHints bool   `json:"deprecatedCommentHints"`
-	EnableDiagnostics      bool   `json:"enableDiagnostics"`
+	KamailioSourcePath     string   `json:"kamailioSourcePath"`
+	LogLevel               int      `json:"logLevel"`
+	DeprecatedCommentHints bool     `json:"deprecatedCommentHints"`
+	EnableDiagnostics      bool     `json:"enableDiagnostics"`
+	ConfigGlobs            []string `json:"configGlobs"` // the globs matching the configuration files of the workspace folders
 }
 
-var GlobalSettings LSPSettings
-
 // NewLSPSettings creates and returns a new instance of LSPSettings.
 // It initializes the settings with the given Kamailio source path, root directory, and log level.
+// Every session holds its own settings, see state_manager.State.ApplySettings.
 //
 // Parameters:
 //
@@ -26,16 +24,18 @@ var GlobalSettings LSPSettings
 //
 //	LSPSettings - The initialized settings.
 func NewLSPSettings(ksrc string, rootDir string, ll int, dch bool, diag bool) LSPSettings {
-	GlobalSettings = LSPSettings{
+	return LSPSettings{
 		KamailioSourcePath:     ksrc,
 		LogLevel:               ll,
 		DeprecatedCommentHints: dch,
 		EnableDiagnostics:      diag,
 	}
-	zerolog.SetGlobalLevel(zerolog.Level(ll))
-	return GlobalSettings
 }
 
+// DEFAULT_CONFIG_GLOBS are the globs matching the configuration files of the workspace folders,
+// unless the client configures them.
+var DEFAULT_CONFIG_GLOBS = []string{"*.cfg", "*.inc"}
+
 const RPC_VERSION = "2.0"
 const KAMAIZEN_VERSION = "0.1.2"
 const MY_NAME = "KamaiZen"
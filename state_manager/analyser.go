@@ -4,7 +4,9 @@ import (
 	"KamaiZen/document_manager"
 	"KamaiZen/kamailio_cfg"
 	"KamaiZen/lsp"
+	"context"
 	"regexp"
+	"slices"
 
 	"github.com/rs/zerolog/log"
 	sitter "github.com/smacker/go-tree-sitter"
@@ -61,50 +63,43 @@ func (s *StateTree) TraverseNode(uri lsp.DocumentURI, node *sitter.Node, padding
 }
 
 // GetNodeDocsAtPosition retrieves the documentation for the node at the given position in the source code.
+// Variables and defines are looked up in the whole configuration the document belongs to.
+//
 // Parameters:
-// - uri: The URI of the document.
-// - position: The position within the document.
-// - source_code: The source code as a byte slice.
+//
+//	modules *document_manager.ModuleIndex - The module documentation of the session.
+//	document *Document - The locked document.
+//	point sitter.Point - The point within the document, as a row and byte column.
+//	views []documentView - The other documents of the configuration, see State.combinedView.
+//
 // Returns:
-// - The documentation string for the node at the specified position.
-func GetNodeDocsAtPosition(uri lsp.DocumentURI, position lsp.Position, source_code []byte) string {
-	node := GetState().Analyzer.GetAST().Node
-	nodeAtPosition := getNodeAtPosition(node, position)
+//
+//	string - The documentation string for the node at the specified position.
+func GetNodeDocsAtPosition(modules *document_manager.ModuleIndex, document *Document, point sitter.Point, views []documentView) string {
+	ast := document.Analyzer.GetAST()
+	if ast == nil {
+		return "Documentation not found"
+	}
+	source_code := []byte(document.Text)
+	if symbol := document.Symbols.SymbolAt(point); symbol != nil {
+		return document.Symbols.GetDocs(*symbol, symbolTables(views)...)
+	}
+	nodeAtPosition := getNodeAtPosition(ast.Node, point)
 	if nodeAtPosition == nil {
 		log.Error().Msg("Node at position is nil")
 		return ""
 	}
-	switch nodeAtPosition.Type() {
-	case kamailio_cfg.IdentifierNodeType:
-		switch nodeAtPosition.Parent().Parent().Type() {
-		case kamailio_cfg.CallExpressionNodeType:
-			functionName := getFunctionName(nodeAtPosition, source_code)
-			return document_manager.FindFunctionInAllModules(functionName)
-		case kamailio_cfg.AVPNodeType:
-			variableName := nodeAtPosition.Content(source_code)
-			v := kamailio_cfg.GetAVPVariable(variableName)
-			return v.GetDocs()
-		case kamailio_cfg.VARNodeType:
-			variableName := nodeAtPosition.Content(source_code)
-			v := kamailio_cfg.GetLocalVariable(variableName)
-			return v.GetDocs()
-		case kamailio_cfg.DlgVarNodeType:
-			variableName := nodeAtPosition.Content(source_code)
-			v := kamailio_cfg.GetDlgVariable(variableName)
-			return v.GetDocs()
+	if nodeAtPosition.Type() == kamailio_cfg.IdentifierNodeType {
+		if define, view := findDefine(document, views, nodeAtPosition.Content(source_code)); define != nil {
+			if view == nil {
+				return define.GetDocs()
+			}
+			return define.GetDocs() + "\n### Defined in\n\n\t" + fileName(view.uri) + "\n"
 		}
-	case kamailio_cfg.AVPNodeType:
-		variableName := nodeAtPosition.ChildByFieldName("name").NamedChild(0).Content(source_code)
-		v := kamailio_cfg.GetAVPVariable(variableName)
-		return v.GetDocs()
-	case kamailio_cfg.VARNodeType:
-		variableName := nodeAtPosition.ChildByFieldName("name").NamedChild(0).Content(source_code)
-		v := kamailio_cfg.GetLocalVariable(variableName)
-		return v.GetDocs()
-	case kamailio_cfg.DlgVarNodeType:
-		variableName := nodeAtPosition.ChildByFieldName("name").NamedChild(0).Content(source_code)
-		v := kamailio_cfg.GetDlgVariable(variableName)
-		return v.GetDocs()
+	}
+	if getFunctionName(nodeAtPosition, source_code) != "" {
+		functionName := getFunctionName(nodeAtPosition, source_code)
+		return modules.FindFunctionInAllModules(functionName)
 	}
 	word := nodeAtPosition.Content(source_code)
 	// drop special characters
@@ -121,22 +116,14 @@ func GetNodeDocsAtPosition(uri lsp.DocumentURI, position lsp.Position, source_co
 // getNodeAtPosition finds the node at the specified position within the given AST node.
 // Parameters:
 // - node: The root AST node.
-// - position: The position within the document.
+// - point: The point within the document, as a row and byte column.
 // Returns:
 // - The node at the specified position or nil if no such node exists.
-func getNodeAtPosition(node *sitter.Node, position lsp.Position) *sitter.Node {
+func getNodeAtPosition(node *sitter.Node, point sitter.Point) *sitter.Node {
 	if node == nil {
 		return nil
 	}
-	nodeAtPosition := node.NamedDescendantForPointRange(
-		sitter.Point{
-			Row:    uint32(position.Line),
-			Column: uint32(position.Character),
-		},
-		sitter.Point{
-			Row:    uint32(position.Line),
-			Column: uint32(position.Character),
-		})
+	nodeAtPosition := node.NamedDescendantForPointRange(point, point)
 	return nodeAtPosition
 }
 
@@ -156,6 +143,7 @@ func getFunctionName(node *sitter.Node, source_code []byte) string {
 		return ""
 	}
 	if node.Type() == kamailio_cfg.IdentifierNodeType &&
+		node.Parent() != nil && node.Parent().Parent() != nil &&
 		node.Parent().Parent().Type() == kamailio_cfg.CallExpressionNodeType &&
 		node.Parent().Parent().FieldNameForChild(0) == "function" {
 		return node.Content(source_code)
@@ -196,17 +184,22 @@ func getAllAvailableKeywords() map[string]string {
 }
 
 // GetCompletionItems returns a list of completion items for the given document URI.
+// The variables are the ones visible at the given point of the document, along with the transaction
+// and dialog variables and the defines of the other documents of its configuration.
 //
 // Parameters:
 //
-//	uri lsp.DocumentURI - The URI of the document.
+//	ctx context.Context - The context of the completion request, the configuration is not looked up once it is done.
+//	document *Document - The locked document, or nil if the document is not known.
+//	point sitter.Point - The point of the completion within the document.
+//	views []documentView - The other documents of the configuration, see State.combinedView.
 //
 // Returns:
 //
 //	[]lsp.CompletionItem - A list of completion items.
-func GetCompletionItems(uri lsp.DocumentURI) []lsp.CompletionItem {
+func GetCompletionItems(ctx context.Context, modules *document_manager.ModuleIndex, document *Document, point sitter.Point, views []documentView) []lsp.CompletionItem {
 	var completionItems []lsp.CompletionItem
-	functions := document_manager.GetAllAvailableFunctionDocs()
+	functions := modules.GetAllAvailableFunctionDocs()
 	for _, function := range functions {
 		completionItems = append(completionItems, lsp.CompletionItem{
 			Detail:        function.Name + "(" + function.Parameters + ")",
@@ -226,37 +219,62 @@ func GetCompletionItems(uri lsp.DocumentURI) []lsp.CompletionItem {
 		})
 	}
 
-	variables := kamailio_cfg.GetAVPVariables()
-	for variable, value := range variables {
-		completionItems = append(completionItems, lsp.CompletionItem{
-			Detail:        "AVP",
-			Label:         variable,
-			Documentation: value.GetDocs(),
-			Kind:          lsp.VARIABLE_COMPLETION,
-		})
-	}
-
-	localVariables := kamailio_cfg.GetLocalVariables()
-	for variable, value := range localVariables {
-		completionItems = append(completionItems, lsp.CompletionItem{
-			Detail:        "Local Variable",
-			Label:         variable,
-			Documentation: value.GetDocs(),
-			Kind:          lsp.VARIABLE_COMPLETION,
-		})
-	}
-
-	dlgVariables := kamailio_cfg.GetDlgVariables()
-	for variable, value := range dlgVariables {
-		completionItems = append(completionItems, lsp.CompletionItem{
-			Detail:        "Dialog Variable",
-			Label:         variable,
-			Documentation: value.GetDocs(),
-			Kind:          lsp.VARIABLE_COMPLETION,
-		})
+	if document != nil {
+		symbols := document.Symbols
+		seen := make(map[string]bool)
+		variables := symbols.VisibleDefinitions(symbols.RouteAt(point))
+		for _, view := range views {
+			if ctx.Err() != nil {
+				return nil
+			}
+			for _, variable := range view.symbols.VisibleDefinitions("") {
+				if variable.Kind != kamailio_cfg.LocalVariable {
+					variables = append(variables, variable)
+				}
+			}
+		}
+		for _, variable := range variables {
+			if seen[variable.Name] {
+				continue
+			}
+			seen[variable.Name] = true
+			detail := "AVP"
+			switch variable.Kind {
+			case kamailio_cfg.LocalVariable:
+				detail = "Local Variable"
+			case kamailio_cfg.DlgVariable:
+				detail = "Dialog Variable"
+			case kamailio_cfg.XAVPVariable:
+				detail = "XAVP"
+			case kamailio_cfg.SHTVariable:
+				detail = "Hash Table"
+			}
+			completionItems = append(completionItems, lsp.CompletionItem{
+				Detail:        detail,
+				Label:         variable.Name,
+				Documentation: symbols.GetDocs(variable, symbolTables(views)...),
+				Kind:          lsp.VARIABLE_COMPLETION,
+			})
+		}
+		defines := slices.Clone(document.Defines)
+		for _, view := range views {
+			defines = append(defines, view.defines...)
+		}
+		for _, define := range defines {
+			if seen[define.Name] {
+				continue
+			}
+			seen[define.Name] = true
+			completionItems = append(completionItems, lsp.CompletionItem{
+				Detail:        "Define",
+				Label:         define.Name,
+				Documentation: define.GetDocs(),
+				Kind:          lsp.VALUE_COMPLETION,
+			})
+		}
 	}
 
-	for module := range document_manager.GetAllAvailableModules() {
+	for module := range modules.GetAllAvailableModules() {
 		completionItems = append(completionItems, lsp.CompletionItem{
 			Detail:        "Module",
 			Label:         module,
@@ -278,17 +296,261 @@ func GetCompletionItems(uri lsp.DocumentURI) []lsp.CompletionItem {
 
 }
 
+// GetRouteDefinitionAtPosition returns the locations of the routes called by the route(...) statement
+// at the given point, looked up in the whole configuration the document belongs to.
+// Several routes are returned when the route is defined more than once.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the definition request, the configuration is not looked up once it is done.
+//	document *Document - The locked document.
+//	views []documentView - The other documents of the configuration, see State.combinedView.
+//	point sitter.Point - The point within the document.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	[]lsp.Location - The locations of the names of the routes.
+//	bool - False if there is no route call at the point.
 func GetRouteDefinitionAtPosition(
-	uri lsp.DocumentURI,
-	position lsp.Position,
-	source_code []byte,
-) *kamailio_cfg.NamedRoute {
-	node := GetState().Analyzer.GetAST().Node
-	nodeAtPosition := getNodeAtPosition(node, position)
-	if nodeAtPosition == nil {
-		log.Error().Msg("Node at position is nil")
+	ctx context.Context,
+	document *Document,
+	views []documentView,
+	point sitter.Point,
+	encoding lsp.PositionEncodingKind,
+) ([]lsp.Location, bool) {
+	name, found := kamailio_cfg.RouteCallAt(document.Analyzer, []byte(document.Text), point)
+	if !found {
+		return nil, false
+	}
+	var locations []lsp.Location
+	for _, route := range document.Routes {
+		if route.Type == kamailio_cfg.RouteBlockType && route.Name == name {
+			locations = append(locations, lsp.Location{
+				URI:   document.URI,
+				Range: document.LineIndex(encoding).Range(route.StartPoint, route.EndPoint),
+			})
+		}
+	}
+	for _, view := range views {
+		if ctx.Err() != nil {
+			break
+		}
+		for _, route := range view.routes {
+			if route.Type == kamailio_cfg.RouteBlockType && route.Name == name {
+				locations = append(locations, view.location(route.StartPoint, route.EndPoint))
+			}
+		}
+	}
+	return locations, true
+}
+
+// definitionInConfiguration returns the location of the definition of the variable or the define
+// at the given point, looked up in the document, then in the other documents of its configuration.
+// Local variables are only looked up in the document.
+// The document must be locked.
+func definitionInConfiguration(document *Document, views []documentView, point sitter.Point, encoding lsp.PositionEncodingKind) (lsp.Location, bool) {
+	if symbol := document.Symbols.SymbolAt(point); symbol != nil {
+		if definitions := document.Symbols.Definitions(*symbol); len(definitions) > 0 {
+			return lsp.Location{
+				URI:   document.URI,
+				Range: document.LineIndex(encoding).Range(definitions[0].StartPoint, definitions[0].EndPoint),
+			}, true
+		}
+		if symbol.Kind == kamailio_cfg.LocalVariable {
+			return lsp.Location{}, false
+		}
+		for _, view := range views {
+			if definitions := view.symbols.Definitions(*symbol); len(definitions) > 0 {
+				return view.location(definitions[0].StartPoint, definitions[0].EndPoint), true
+			}
+		}
+		return lsp.Location{}, false
+	}
+	ast := document.Analyzer.GetAST()
+	if ast == nil {
+		return lsp.Location{}, false
+	}
+	node := getNodeAtPosition(ast.Node, point)
+	if node == nil || node.Type() != kamailio_cfg.IdentifierNodeType {
+		return lsp.Location{}, false
+	}
+	define, view := findDefine(document, views, node.Content([]byte(document.Text)))
+	switch {
+	case define == nil:
+		return lsp.Location{}, false
+	case view == nil:
+		return lsp.Location{
+			URI:   document.URI,
+			Range: document.LineIndex(encoding).Range(define.StartPoint, define.EndPoint),
+		}, true
+	}
+	return view.location(define.StartPoint, define.EndPoint), true
+}
+
+// GetReferencesAtPosition returns the locations of the references to the route, the variable,
+// the define or the module at the given point, looked up in the whole configuration the document belongs to.
+// Local variables are only looked up in the document.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the references request, the configuration is not looked up once it is done.
+//	document *Document - The locked document.
+//	views []documentView - The other documents of the configuration, see State.combinedView.
+//	point sitter.Point - The point within the document.
+//	includeDeclaration bool - Whether the declarations are returned along with the uses.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	[]lsp.Location - The locations of the references, none if there is no reference at the point.
+func GetReferencesAtPosition(
+	ctx context.Context,
+	document *Document,
+	views []documentView,
+	point sitter.Point,
+	includeDeclaration bool,
+	encoding lsp.PositionEncodingKind,
+) []lsp.Location {
+	target := document.References.At(point)
+	if target == nil {
 		return nil
 	}
-	namedRoute := kamailio_cfg.QueryRoute(GetState().Analyzer, source_code)
-	return namedRoute
+	var locations []lsp.Location
+	index := document.LineIndex(encoding)
+	for _, reference := range document.References.Find(*target) {
+		if includeDeclaration || !reference.Declaration {
+			locations = append(locations, lsp.Location{
+				URI:   document.URI,
+				Range: index.Range(reference.StartPoint, reference.EndPoint),
+			})
+		}
+	}
+	if target.Local() {
+		return locations
+	}
+	for _, view := range views {
+		if ctx.Err() != nil {
+			break
+		}
+		references := view.references.Find(*target)
+		if len(references) == 0 {
+			continue
+		}
+		for _, reference := range references {
+			if includeDeclaration || !reference.Declaration {
+				locations = append(locations, lsp.Location{
+					URI:   view.uri,
+					Range: view.index.Range(reference.StartPoint, reference.EndPoint),
+				})
+			}
+		}
+	}
+	return locations
+}
+
+// GetDocumentHighlights returns the occurrences, within the document, of the route, the variable,
+// the define or the module at the given point, taken from the reference index of the document.
+// The assignments of a variable are writes, the declarations of the other names are text,
+// and the uses are reads.
+//
+// Parameters:
+//
+//	document *Document - The locked document.
+//	point sitter.Point - The point within the document.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	[]lsp.DocumentHighlight - The occurrences, none if there is no reference at the point.
+func GetDocumentHighlights(document *Document, point sitter.Point, encoding lsp.PositionEncodingKind) []lsp.DocumentHighlight {
+	target := document.References.At(point)
+	if target == nil {
+		return nil
+	}
+	var highlights []lsp.DocumentHighlight
+	index := document.LineIndex(encoding)
+	for _, reference := range document.References.Find(*target) {
+		kind := lsp.READ_HIGHLIGHT
+		switch {
+		case reference.Declaration && reference.Kind == kamailio_cfg.VariableReference:
+			kind = lsp.WRITE_HIGHLIGHT
+		case reference.Declaration:
+			kind = lsp.TEXT_HIGHLIGHT
+		}
+		highlights = append(highlights, lsp.DocumentHighlight{
+			Range: index.Range(reference.StartPoint, reference.EndPoint),
+			Kind:  kind,
+		})
+	}
+	return highlights
+}
+
+// outlineSymbolKinds maps the kinds of the outline items to the kinds of the document symbols.
+var outlineSymbolKinds = map[kamailio_cfg.OutlineKind]lsp.SymbolKind{
+	kamailio_cfg.RouteOutline:     lsp.FUNCTION_SYMBOL,
+	kamailio_cfg.ModuleOutline:    lsp.MODULE_SYMBOL,
+	kamailio_cfg.ModparamOutline:  lsp.PROPERTY_SYMBOL,
+	kamailio_cfg.DefineOutline:    lsp.CONSTANT_SYMBOL,
+	kamailio_cfg.ParameterOutline: lsp.VARIABLE_SYMBOL,
+}
+
+// GetDocumentSymbols returns the outline of the document: its routing blocks, its modules
+// with their modparam statements nested, its defines and its core parameters.
+//
+// Parameters:
+//
+//	document *Document - The locked document.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	[]lsp.DocumentSymbol - The symbols of the document, in document order.
+func GetDocumentSymbols(document *Document, encoding lsp.PositionEncodingKind) []lsp.DocumentSymbol {
+	index := document.LineIndex(encoding)
+	var symbols func(items []kamailio_cfg.OutlineItem) []lsp.DocumentSymbol
+	symbols = func(items []kamailio_cfg.OutlineItem) []lsp.DocumentSymbol {
+		var result []lsp.DocumentSymbol
+		for _, item := range items {
+			result = append(result, lsp.DocumentSymbol{
+				Name:           item.Name,
+				Detail:         item.Detail,
+				Kind:           outlineSymbolKinds[item.Kind],
+				Range:          index.Range(item.StartPoint, item.EndPoint),
+				SelectionRange: index.Range(item.NameStartPoint, item.NameEndPoint),
+				Children:       symbols(item.Children),
+			})
+		}
+		return result
+	}
+	return symbols(document.Outline)
+}
+
+// foldingRangeKinds maps the kinds of the folds to the kinds of the folding ranges, code has no kind.
+var foldingRangeKinds = map[kamailio_cfg.FoldKind]lsp.FoldingRangeKind{
+	kamailio_cfg.CommentFold: lsp.COMMENT_FOLDING_RANGE,
+	kamailio_cfg.ImportsFold: lsp.IMPORTS_FOLDING_RANGE,
+	kamailio_cfg.RegionFold:  lsp.REGION_FOLDING_RANGE,
+}
+
+// GetFoldingRanges returns the folding ranges of the document, whole lines.
+// Lines do not depend on the position encoding.
+//
+// Parameters:
+//
+//	document *Document - The locked document.
+//
+// Returns:
+//
+//	[]lsp.FoldingRange - The folding ranges of the document, sorted by start line.
+func GetFoldingRanges(document *Document) []lsp.FoldingRange {
+	var ranges []lsp.FoldingRange
+	for _, fold := range kamailio_cfg.Folds(document.Analyzer) {
+		ranges = append(ranges, lsp.FoldingRange{
+			StartLine: fold.StartRow,
+			EndLine:   fold.EndRow,
+			Kind:      foldingRangeKinds[fold.Kind],
+		})
+	}
+	return ranges
 }
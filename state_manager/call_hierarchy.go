@@ -0,0 +1,203 @@
+package state_manager
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"context"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// routeBlock is a routing block of a document of a configuration, a function of the call hierarchy.
+type routeBlock struct {
+	view *documentView
+	item kamailio_cfg.OutlineItem
+}
+
+// callHierarchy resolves the calls between the routing blocks of the documents of a configuration.
+type callHierarchy struct {
+	views  []documentView
+	blocks []routeBlock
+}
+
+// newCallHierarchy creates the call hierarchy of the documents of a configuration, see State.configurationView.
+func newCallHierarchy(views []documentView) *callHierarchy {
+	h := &callHierarchy{views: views}
+	for i := range h.views {
+		for _, item := range h.views[i].outline {
+			if item.Kind == kamailio_cfg.RouteOutline {
+				h.blocks = append(h.blocks, routeBlock{view: &h.views[i], item: item})
+			}
+		}
+	}
+	return h
+}
+
+// item returns the call hierarchy item of a routing block.
+func (h *callHierarchy) item(block routeBlock) lsp.CallHierarchyItem {
+	index := block.view.index
+	kind := lsp.FUNCTION_SYMBOL
+	if routeType, _ := block.item.Route(); routeType == kamailio_cfg.EventRouteType {
+		kind = lsp.EVENT_SYMBOL
+	}
+	return lsp.CallHierarchyItem{
+		Name:           block.item.Name,
+		Kind:           kind,
+		Detail:         block.item.Detail + " - " + fileName(block.view.uri),
+		URI:            block.view.uri,
+		Range:          index.Range(block.item.StartPoint, block.item.EndPoint),
+		SelectionRange: index.Range(block.item.NameStartPoint, block.item.NameEndPoint),
+	}
+}
+
+// blockAt returns the routing block of the document with the given URI containing the point.
+func (h *callHierarchy) blockAt(uri lsp.DocumentURI, point sitter.Point) (routeBlock, bool) {
+	for _, block := range h.blocks {
+		if block.view.uri == uri && block.item.Contains(point) {
+			return block, true
+		}
+	}
+	return routeBlock{}, false
+}
+
+// blockOf returns the routing block of the given call hierarchy item.
+func (h *callHierarchy) blockOf(item lsp.CallHierarchyItem) (routeBlock, bool) {
+	for i := range h.views {
+		if h.views[i].uri == item.URI {
+			return h.blockAt(item.URI, h.views[i].index.PointAt(item.SelectionRange.Start))
+		}
+	}
+	return routeBlock{}, false
+}
+
+// targets returns the routing blocks called by a call, several if the route is defined more than once.
+func (h *callHierarchy) targets(call kamailio_cfg.Reference) []routeBlock {
+	var targets []routeBlock
+	for _, block := range h.blocks {
+		if routeType, name := block.item.Route(); routeType == call.RouteType && name == call.Name {
+			targets = append(targets, block)
+		}
+	}
+	return targets
+}
+
+// calls records the ranges of the calls between routing blocks, grouped by routing block in call order.
+type calls struct {
+	blocks []routeBlock
+	ranges [][]lsp.Range
+}
+
+// add records the range of a call from or to the given routing block.
+func (c *calls) add(block routeBlock, rng lsp.Range) {
+	for i, b := range c.blocks {
+		if b.view.uri == block.view.uri && b.item.StartPoint == block.item.StartPoint {
+			c.ranges[i] = append(c.ranges[i], rng)
+			return
+		}
+	}
+	c.blocks = append(c.blocks, block)
+	c.ranges = append(c.ranges, []lsp.Range{rng})
+}
+
+// Prepare returns the routing blocks at the given point of the document with the given URI:
+// the routing blocks called at the point, or else the routing block containing the point.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//	point sitter.Point - The point within the document.
+//
+// Returns:
+//
+//	[]lsp.CallHierarchyItem - The items of the routing blocks, none if there is no routing block at the point.
+func (h *callHierarchy) Prepare(uri lsp.DocumentURI, point sitter.Point) []lsp.CallHierarchyItem {
+	var items []lsp.CallHierarchyItem
+	for i := range h.views {
+		if h.views[i].uri != uri {
+			continue
+		}
+		for _, call := range h.views[i].references.RouteCalls() {
+			if call.Contains(point) {
+				for _, target := range h.targets(call) {
+					items = append(items, h.item(target))
+				}
+				return items
+			}
+		}
+	}
+	if block, found := h.blockAt(uri, point); found {
+		items = append(items, h.item(block))
+	}
+	return items
+}
+
+// IncomingCalls returns the routing blocks calling the routing block of the given item,
+// with the ranges of their calls.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the incoming calls request, the callers are not looked up once it is done.
+//	item lsp.CallHierarchyItem - The item of the called routing block.
+//
+// Returns:
+//
+//	[]lsp.CallHierarchyIncomingCall - The callers, in the order of the documents of the configuration.
+func (h *callHierarchy) IncomingCalls(ctx context.Context, item lsp.CallHierarchyItem) []lsp.CallHierarchyIncomingCall {
+	target, found := h.blockOf(item)
+	if !found {
+		return nil
+	}
+	routeType, name := target.item.Route()
+	var callers calls
+	for i := range h.views {
+		if ctx.Err() != nil {
+			return nil
+		}
+		view := &h.views[i]
+		for _, call := range view.references.RouteCalls() {
+			if call.RouteType != routeType || call.Name != name {
+				continue
+			}
+			if caller, found := h.blockAt(view.uri, call.StartPoint); found {
+				callers.add(caller, view.index.Range(call.StartPoint, call.EndPoint))
+			}
+		}
+	}
+	var incoming []lsp.CallHierarchyIncomingCall
+	for i, caller := range callers.blocks {
+		incoming = append(incoming, lsp.CallHierarchyIncomingCall{From: h.item(caller), FromRanges: callers.ranges[i]})
+	}
+	return incoming
+}
+
+// OutgoingCalls returns the routing blocks called by the routing block of the given item,
+// with the ranges of the calls: the route(...) calls, the routes armed by functions such as
+// t_on_failure("...") and the event_route blocks triggered by module functions.
+//
+// Parameters:
+//
+//	item lsp.CallHierarchyItem - The item of the calling routing block.
+//
+// Returns:
+//
+//	[]lsp.CallHierarchyOutgoingCall - The called routing blocks, in call order.
+func (h *callHierarchy) OutgoingCalls(item lsp.CallHierarchyItem) []lsp.CallHierarchyOutgoingCall {
+	caller, found := h.blockOf(item)
+	if !found {
+		return nil
+	}
+	var callees calls
+	for _, call := range caller.view.references.RouteCalls() {
+		if !caller.item.Contains(call.StartPoint) {
+			continue
+		}
+		for _, target := range h.targets(call) {
+			callees.add(target, caller.view.index.Range(call.StartPoint, call.EndPoint))
+		}
+	}
+	var outgoing []lsp.CallHierarchyOutgoingCall
+	for i, callee := range callees.blocks {
+		outgoing = append(outgoing, lsp.CallHierarchyOutgoingCall{To: h.item(callee), FromRanges: callees.ranges[i]})
+	}
+	return outgoing
+}
@@ -0,0 +1,68 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"KamaiZen/state_manager"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCallHierarchy(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"routes.cfg\"\nrequest_route {\n  route(AUTH);\n  t_on_failure(\"FAIL\");\n  dlg_manage();\n  route(AUTH);\n}\n",
+		"routes.cfg":   "route[AUTH] {\n}\nfailure_route[FAIL] {\n  route(AUTH);\n}\nevent_route[dialog:start] {\n}\n",
+	})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	routes := lsp.PathToURI(filepath.Join(dir, "routes.cfg"))
+	text, _ := os.ReadFile(filepath.Join(dir, "kamailio.cfg"))
+	state.OpenDocument(context.Background(), main, 1, string(text))
+
+	prepared, err := state.PrepareCallHierarchy(context.Background(), lsp.NewIntID(1), main, lsp.Position{Line: 2, Character: 9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := prepared.Result
+	if len(items) != 1 || items[0].Name != "route[AUTH]" || items[0].URI != routes {
+		t.Fatalf("Expected: route[AUTH] of routes.cfg,\ngot: %+v", items)
+	}
+	incomingCalls, err := state.IncomingCalls(context.Background(), lsp.NewIntID(2), items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	incoming := incomingCalls.Result
+	callers := make(map[string]int)
+	for _, call := range incoming {
+		callers[call.From.Name] = len(call.FromRanges)
+	}
+	if len(callers) != 2 || callers["request_route"] != 2 || callers["failure_route[FAIL]"] != 1 {
+		t.Fatalf("Expected: request_route twice and failure_route[FAIL] once,\ngot: %+v", incoming)
+	}
+
+	prepared, err = state.PrepareCallHierarchy(context.Background(), lsp.NewIntID(3), main, lsp.Position{Line: 1, Character: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	items = prepared.Result
+	if len(items) != 1 || items[0].Name != "request_route" {
+		t.Fatalf("Expected: request_route,\ngot: %+v", items)
+	}
+	outgoingCalls, err := state.OutgoingCalls(context.Background(), lsp.NewIntID(4), items[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	outgoing := outgoingCalls.Result
+	expected := []string{"route[AUTH]", "failure_route[FAIL]", "event_route[dialog:start]"}
+	if len(outgoing) != len(expected) {
+		t.Fatalf("Expected: %v,\ngot: %+v", expected, outgoing)
+	}
+	for i, e := range expected {
+		if outgoing[i].To.Name != e {
+			t.Fatalf("Expected: %s,\ngot: %s", e, outgoing[i].To.Name)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package state_manager
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"context"
+	"sync"
+)
+
+// Document holds a text document known to the server and its analysis.
+// A document is either open in the editor, or read from disk because an open document includes it.
+// Every document has its own parser, so that its parse tree is edited and reparsed incrementally.
+// The document lock must be held while reading or updating any of its fields.
+type Document struct {
+	mu          sync.Mutex
+	URI         lsp.DocumentURI
+	Text        string                       // The text content of the document.
+	Version     int                          // The version of the text, as sent by the client.
+	Open        bool                         // Whether the document is open in the editor, rather than read from disk.
+	Analyzer    *kamailio_cfg.Analyzer       // The analyzer holding the parser and the parse tree of the document.
+	Symbols     *kamailio_cfg.SymbolTable    // The variables defined and used in the document.
+	Includes    []kamailio_cfg.Include       // The include_file and import_file directives of the document.
+	Defines     []kamailio_cfg.Define        // The #!define directives of the document.
+	Routes      []kamailio_cfg.NamedRoute    // The named routing blocks of the document.
+	References  *kamailio_cfg.ReferenceIndex // The routes, variables, defines and modules referred to in the document.
+	Outline     []kamailio_cfg.OutlineItem   // The routing blocks, modules, defines and core parameters of the document.
+	Indexed     []kamailio_cfg.IndexedSymbol // The symbols of the document searched across the workspace.
+	Diagnostics []lsp.Diagnostic             // The diagnostics of the last analysis.
+
+	index            *lsp.LineIndex     // The line index of the text, built once per text and encoding, see LineIndex.
+	includeRanges    []lsp.Range        // The ranges of the paths of the include directives, as sent to the client.
+	semanticTokens   lsp.SemanticTokens // The last semantic tokens sent to the client, see State.SemanticTokensDelta.
+	semanticTokensID int                // The number of semantic tokens results sent to the client.
+}
+
+// NewDocument creates and returns a new document, which is analysed once it is updated.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//	version int - The version of the text.
+//	text string - The text content of the document.
+//
+// Returns:
+//
+//	*Document - The new document.
+func NewDocument(uri lsp.DocumentURI, version int, text string) *Document {
+	return &Document{
+		URI:        uri,
+		Text:       text,
+		Version:    version,
+		Analyzer:   kamailio_cfg.NewAnalyzer(),
+		Symbols:    kamailio_cfg.NewSymbolTable(),
+		References: kamailio_cfg.NewReferenceIndex(),
+	}
+}
+
+// Lock locks the document.
+func (d *Document) Lock() {
+	d.mu.Lock()
+}
+
+// Unlock unlocks the document.
+func (d *Document) Unlock() {
+	d.mu.Unlock()
+}
+
+// locked runs the given function with the document locked.
+// The document is unlocked even if the function panics, so that a buffer crashing the analysis
+// does not keep the document locked for the following requests.
+func (d *Document) locked(f func()) {
+	d.Lock()
+	defer d.Unlock()
+	f()
+}
+
+// close frees the parser and the parse tree of the document, once it is dropped from the state.
+// The document is not analysed anymore afterwards.
+func (d *Document) close() {
+	d.Analyzer.Close()
+}
+
+// LineIndex returns the line index of the document text, kept until the text or the encoding changes.
+//
+// Parameters:
+//
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	*lsp.LineIndex - The line index of the text.
+func (d *Document) LineIndex(encoding lsp.PositionEncodingKind) *lsp.LineIndex {
+	if d.index == nil || d.index.Encoding() != encoding {
+		d.index = lsp.NewLineIndex(d.Text, encoding)
+	}
+	return d.index
+}
+
+// applyChanges applies the given content changes, in order, to the document text.
+// The line index of the text is updated along, rather than built again for every change.
+// Range changes are applied to the parse tree as well, so that the next analysis parses incrementally.
+// The tree is dropped if a change replaces the whole text.
+func (d *Document) applyChanges(changes []lsp.TextDocumentContentChangeEvent, encoding lsp.PositionEncodingKind) {
+	parser := d.Analyzer.GetParser()
+	index := d.LineIndex(encoding)
+	incremental := true
+	for _, change := range changes {
+		updated, edit := change.Update(index)
+		index = updated
+		if edit == nil {
+			incremental = false
+			continue
+		}
+		if incremental {
+			parser.Edit(*edit)
+		}
+	}
+	d.Text = index.Text()
+	d.index = index
+	if !incremental {
+		parser.Reset()
+	}
+}
+
+// analyse parses the document text, builds its symbol table and collects its diagnostics.
+// Diagnostics are only kept if they are enabled in the given settings of the session.
+// The analysis results are replaced rather than modified, so that they can be read
+// once the document is unlocked, see State.combinedView.
+// It returns the context error if the parse was cancelled, the previous analysis is then kept.
+func (d *Document) analyse(ctx context.Context, encoding lsp.PositionEncodingKind, config settings.LSPSettings) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	source := []byte(d.Text)
+	if err := d.Analyzer.BuildCtx(ctx, source); err != nil && ctx.Err() != nil {
+		return err
+	}
+	if d.Analyzer.GetAST() == nil {
+		d.Symbols = kamailio_cfg.NewSymbolTable()
+		d.Includes = nil
+		d.Defines = nil
+		d.Routes = nil
+		d.References = kamailio_cfg.NewReferenceIndex()
+		d.Outline = nil
+		d.Indexed = nil
+		d.includeRanges = nil
+		d.Diagnostics = []lsp.Diagnostic{}
+		return nil
+	}
+	index := d.LineIndex(encoding)
+	visitor := kamailio_cfg.NewDiagnosticVisitor(index, config.DeprecatedCommentHints)
+	d.Analyzer.GetAST().Accept(visitor, d.Analyzer)
+	d.Symbols = kamailio_cfg.BuildSymbolTable(d.Analyzer, source)
+	d.Includes = kamailio_cfg.ExtractIncludes(d.Analyzer, source)
+	d.Defines = kamailio_cfg.ExtractDefines(d.Analyzer, source)
+	d.Routes = kamailio_cfg.QueryRoutes(d.Analyzer, source)
+	d.References = kamailio_cfg.BuildReferenceIndex(d.Analyzer, source, d.Symbols)
+	d.Outline = kamailio_cfg.Outline(d.Analyzer, source)
+	d.Indexed = kamailio_cfg.IndexSymbols(d.Analyzer, source, d.Outline)
+	d.includeRanges = make([]lsp.Range, len(d.Includes))
+	for i, include := range d.Includes {
+		d.includeRanges[i] = index.Range(include.StartPoint, include.EndPoint)
+	}
+	visitor.GetQueryDiagnostics(d.Analyzer.GetAST(), d.Analyzer)
+	d.Diagnostics = []lsp.Diagnostic{}
+	if config.EnableDiagnostics {
+		d.Diagnostics = visitor.GetDiagnostics()
+	}
+	return nil
+}
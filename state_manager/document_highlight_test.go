@@ -0,0 +1,50 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/state_manager"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestDocumentHighlights(t *testing.T) {
+	state := state_manager.NewState()
+	uri := lsp.DocumentURI("file:///tmp/kamailio.cfg")
+	state.OpenDocument(context.Background(), uri, 1, "#!define WITH_AUTH\nloadmodule \"tm.so\"\nmodparam(\"tm\", \"fr_timer\", 30)\n"+
+		"request_route {\n  $var(x) = 1;\n  $var(y) = $var(x);\n#!ifdef WITH_AUTH\n  route(AUTH);\n#!endif\n}\n"+
+		"route[AUTH] {\n  $var(x) = $var(x) + 1;\n}\n")
+	position := func(line int, character int) lsp.Position {
+		return lsp.Position{Line: line, Character: character}
+	}
+	for _, test := range []struct {
+		name     string
+		position lsp.Position
+		expected map[int]lsp.DocumentHighlightKind // the kind of the highlight of every line
+	}{
+		{"route", position(7, 9), map[int]lsp.DocumentHighlightKind{7: lsp.READ_HIGHLIGHT, 10: lsp.TEXT_HIGHLIGHT}},
+		{"define", position(0, 10), map[int]lsp.DocumentHighlightKind{0: lsp.TEXT_HIGHLIGHT, 6: lsp.READ_HIGHLIGHT}},
+		{"module", position(2, 11), map[int]lsp.DocumentHighlightKind{1: lsp.TEXT_HIGHLIGHT, 2: lsp.READ_HIGHLIGHT}},
+		{"variable", position(4, 8), map[int]lsp.DocumentHighlightKind{4: lsp.WRITE_HIGHLIGHT, 5: lsp.READ_HIGHLIGHT}},
+	} {
+		response, err := state.DocumentHighlights(context.Background(), lsp.NewIntID(1), uri, test.position)
+		if err != nil {
+			t.Fatal(err)
+		}
+		highlights := response.Result
+		got := make(map[int]lsp.DocumentHighlightKind)
+		for _, highlight := range highlights {
+			got[highlight.Range.Start.Line] = highlight.Kind
+		}
+		if len(highlights) != len(test.expected) || !reflect.DeepEqual(got, test.expected) {
+			t.Fatalf("Expected: %s highlights %v,\ngot: %v", test.name, test.expected, highlights)
+		}
+	}
+	response, err := state.DocumentHighlights(context.Background(), lsp.NewIntID(2), uri, position(3, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Result != nil {
+		t.Fatalf("Expected: no highlights,\ngot: %v", response.Result)
+	}
+}
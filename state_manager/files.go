@@ -0,0 +1,258 @@
+package state_manager
+
+import (
+	"KamaiZen/lsp"
+	"context"
+	"os"
+	"slices"
+
+	"github.com/rs/zerolog/log"
+)
+
+// snapshot returns the documents known to the server.
+func (s *State) snapshot() []*Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	documents := make([]*Document, 0, len(s.documents))
+	for _, document := range s.documents {
+		documents = append(documents, document)
+	}
+	return documents
+}
+
+// LoadDocument reads the document with the given URI from disk and analyses it.
+// The document replaces any document with the same URI, whose parse tree is closed, the files it includes
+// are read from disk as well if they are not known yet.
+// The caller must not hold any document lock.
+//
+// Parameters:
+//
+//	ctx context.Context - Cancels the analysis, the state is then left untouched.
+//	uri lsp.DocumentURI - The URI of the file.
+//
+// Returns:
+//
+//	error - An error if the file cannot be read, or the context error.
+func (s *State) LoadDocument(ctx context.Context, uri lsp.DocumentURI) error {
+	replaced := s.GetDocument(uri)
+	_, err := s.loadDocument(ctx, uri, func(document *Document) bool {
+		s.addDocument(document)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if replaced != nil {
+		replaced.locked(replaced.close)
+	}
+	s.loadIncludes(ctx)
+	return nil
+}
+
+// loadDocument reads the document with the given URI from disk and analyses it, leaving out the files
+// it includes, see LoadDocument. The document is only added once analysed, by the given add function,
+// so that a cancelled parse leaves the state untouched, and a document read in the background does not
+// replace a document the editor opened in the meantime. The parse tree of a document add refuses is closed.
+// It returns whether the document was added.
+func (s *State) loadDocument(ctx context.Context, uri lsp.DocumentURI, add func(document *Document) bool) (bool, error) {
+	path, err := uri.Path()
+	if err != nil {
+		return false, err
+	}
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	document := NewDocument(uri, 0, string(text))
+	added := false
+	document.locked(func() {
+		if err = document.analyse(ctx, s.PositionEncoding(), s.Settings()); err == nil {
+			added = add(document)
+		}
+		if !added {
+			document.close()
+		}
+	})
+	if err != nil {
+		return false, err
+	}
+	if added {
+		log.Debug().Str("uri", string(uri)).Msg("Loaded document from disk")
+	}
+	return added, nil
+}
+
+// includers returns the documents, other than itself, that include the document with the given URI.
+// The caller must not hold any document lock.
+func (s *State) includers(uri lsp.DocumentURI) []*Document {
+	var includers []*Document
+	for _, includer := range s.includeGraph().Includers(uri) {
+		if document := s.GetDocument(includer); document != nil {
+			includers = append(includers, document)
+		}
+	}
+	return includers
+}
+
+// reanalyse analyses the given documents again.
+// It returns the diagnostics of the documents open in the editor, the other ones are not published.
+// Once the context is cancelled, the documents left keep their previous analysis.
+func (s *State) reanalyse(ctx context.Context, documents []*Document) map[lsp.DocumentURI][]lsp.Diagnostic {
+	encoding, config := s.PositionEncoding(), s.Settings()
+	diagnostics := make(map[lsp.DocumentURI][]lsp.Diagnostic)
+	for _, document := range documents {
+		document.locked(func() {
+			document.analyse(ctx, encoding, config)
+			if document.Open {
+				diagnostics[document.URI] = document.Diagnostics
+			}
+		})
+	}
+	s.invalidateIncludeGraph()
+	graph := s.loadIncludes(ctx)
+	for uri := range diagnostics {
+		diagnostics[uri] = s.withIncludeDiagnostics(graph, uri, diagnostics[uri])
+	}
+	return diagnostics
+}
+
+// CloseDocument closes the document with the given URI.
+// A document still included by another document, or found in the workspace, is read again from disk,
+// otherwise it is dropped. Either way the parse tree of the closed document is closed.
+//
+// Parameters:
+//
+//	ctx context.Context - Cancels the analysis of the document read again.
+//	uri lsp.DocumentURI - The URI of the document.
+func (s *State) CloseDocument(ctx context.Context, uri lsp.DocumentURI) {
+	if s.inWorkspace(uri) || len(s.includers(uri)) > 0 {
+		err := s.LoadDocument(ctx, uri)
+		if err == nil {
+			return
+		}
+		log.Debug().Err(err).Str("uri", string(uri)).Msg("Cannot read closed document from disk")
+	}
+	s.removeDocument(uri)
+}
+
+// SaveDocument handles the save of the document with the given URI.
+// The documents including it are analysed again.
+//
+// Parameters:
+//
+//	ctx context.Context - Cancels the analysis of the documents.
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	map[lsp.DocumentURI][]lsp.Diagnostic - The diagnostics of the open documents analysed again.
+func (s *State) SaveDocument(ctx context.Context, uri lsp.DocumentURI) map[lsp.DocumentURI][]lsp.Diagnostic {
+	return s.reanalyse(ctx, s.includers(uri))
+}
+
+// FileChanged handles a change on disk of the file with the given URI.
+// Documents open in the editor are left untouched, as the editor holds their content.
+// Known documents, files included by known documents and configuration files of the workspace
+// are read again, deleted files are dropped.
+// The documents including the file are analysed again.
+//
+// Parameters:
+//
+//	ctx context.Context - Cancels the analysis of the file and of the documents including it.
+//	uri lsp.DocumentURI - The URI of the file.
+//	change lsp.FileChangeType - The kind of change.
+//
+// Returns:
+//
+//	map[lsp.DocumentURI][]lsp.Diagnostic - The diagnostics of the open documents analysed again.
+func (s *State) FileChanged(ctx context.Context, uri lsp.DocumentURI, change lsp.FileChangeType) map[lsp.DocumentURI][]lsp.Diagnostic {
+	// the include directives may resolve to a created or deleted file
+	s.invalidateIncludeGraph()
+	document := s.GetDocument(uri)
+	if document != nil {
+		document.Lock()
+		open := document.Open
+		document.Unlock()
+		if open {
+			return nil
+		}
+	}
+	includers := s.includers(uri)
+	switch {
+	case change == lsp.FILE_DELETED:
+		s.removeDocument(uri)
+	case document != nil || len(includers) > 0 || s.inWorkspace(uri):
+		if err := s.LoadDocument(ctx, uri); err != nil {
+			log.Error().Err(err).Str("uri", string(uri)).Msg("Cannot read changed file")
+			s.removeDocument(uri)
+		}
+	default:
+		return nil
+	}
+	return s.reanalyse(ctx, includers)
+}
+
+// AnalyseAll analyses every known document again, after a change of the settings.
+//
+// Parameters:
+//
+//	ctx context.Context - Cancels the analysis of the documents.
+//
+// Returns:
+//
+//	map[lsp.DocumentURI][]lsp.Diagnostic - The diagnostics of the open documents.
+func (s *State) AnalyseAll(ctx context.Context) map[lsp.DocumentURI][]lsp.Diagnostic {
+	return s.reanalyse(ctx, s.snapshot())
+}
+
+// IncludedFiles returns the URIs of the files included by the known documents, found or not.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the included files.
+func (s *State) IncludedFiles() []lsp.DocumentURI {
+	return s.includeGraph().Files()
+}
+
+// OpenDocuments returns the URIs of the documents open in the editor, and the number of
+// documents read from disk because they are included or found in the workspace.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the open documents, sorted.
+//	int - The number of documents read from disk.
+func (s *State) OpenDocuments() ([]lsp.DocumentURI, int) {
+	var open []lsp.DocumentURI
+	loaded := 0
+	for _, document := range s.snapshot() {
+		document.Lock()
+		if document.Open {
+			open = append(open, document.URI)
+		} else {
+			loaded++
+		}
+		document.Unlock()
+	}
+	slices.Sort(open)
+	return open, loaded
+}
+
+// DocumentVersion returns the version of the document with the given URI.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	int - The version of the document.
+//	bool - False if the document is not known.
+func (s *State) DocumentVersion(uri lsp.DocumentURI) (int, bool) {
+	document := s.GetDocument(uri)
+	if document == nil {
+		return 0, false
+	}
+	document.Lock()
+	defer document.Unlock()
+	return document.Version, true
+}
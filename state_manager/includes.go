@@ -0,0 +1,608 @@
+package state_manager
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"context"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ResolveInclude returns the URI of a file included by the document with the given URI.
+// Relative paths are resolved against the directory of the including document.
+//
+// Parameters:
+//
+//	from lsp.DocumentURI - The URI of the including document.
+//	path string - The path of the included file, as written in the directive.
+//
+// Returns:
+//
+//	lsp.DocumentURI - The URI of the included file.
+//	bool - False if the including document is not a file.
+func ResolveInclude(from lsp.DocumentURI, path string) (lsp.DocumentURI, bool) {
+	if filepath.IsAbs(path) {
+		return lsp.PathToURI(path), true
+	}
+	fromPath, err := from.Path()
+	if err != nil {
+		log.Debug().Err(err).Str("uri", string(from)).Msg("Cannot resolve includes of the document")
+		return "", false
+	}
+	return lsp.PathToURI(filepath.Join(filepath.Dir(fromPath), path)), true
+}
+
+// max_include_loads bounds the rounds of reading included files from disk,
+// as a file may include itself through an ever longer relative path.
+const max_include_loads = 32
+
+// includeEdge is an include_file or import_file directive, resolved to the file it includes.
+type includeEdge struct {
+	from    lsp.DocumentURI
+	to      lsp.DocumentURI
+	include kamailio_cfg.Include
+	rng     lsp.Range // The range of the path in the including document.
+	found   bool      // Whether the included file exists, known or on disk.
+}
+
+// IncludeGraph is the graph of the include_file and import_file directives of the known documents.
+// Included paths are resolved the way Kamailio does: relative to the including file, then relative
+// to the directory of the main configuration, which is a document included by no other document.
+// The graph is a snapshot, it does not change with the documents: the state keeps it until a document
+// is added, removed or analysed again, or a file changes on disk, see State.includeGraph.
+type IncludeGraph struct {
+	documents []lsp.DocumentURI                 // the known documents, sorted
+	known     map[lsp.DocumentURI]bool          // the known documents
+	edges     map[lsp.DocumentURI][]includeEdge // the directives by including document, in document order
+	includers map[lsp.DocumentURI][]includeEdge // the directives including a found file, by included file
+}
+
+// includeGraph returns the include graph of the known documents, built once and kept until it is
+// dropped, so that the requests do not resolve the include directives again.
+// The caller must not hold any document lock.
+func (s *State) includeGraph() *IncludeGraph {
+	s.mu.Lock()
+	graph, version := s.graph, s.graphVersion
+	s.mu.Unlock()
+	if graph != nil {
+		return graph
+	}
+	graph = s.buildIncludeGraph()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// a graph built while the documents changed is returned but not kept
+	if s.graphVersion == version {
+		s.graph = graph
+	}
+	return graph
+}
+
+// dropIncludeGraph drops the include graph, so that it is built again by the next includeGraph.
+// The caller must hold the state lock.
+func (s *State) dropIncludeGraph() {
+	s.graph = nil
+	s.graphVersion++
+}
+
+// invalidateIncludeGraph drops the include graph, see dropIncludeGraph.
+// The caller must not hold the state lock.
+func (s *State) invalidateIncludeGraph() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropIncludeGraph()
+}
+
+// moveIncludes updates the include graph kept by the state once the given document was analysed again
+// without changing the files it includes, rather than dropping it, so that an edit does not resolve
+// every include directive again. The graph is dropped if none is kept.
+// The caller must hold the document lock, and not the state lock.
+func (s *State) moveIncludes(document *Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	graph := s.graph
+	s.dropIncludeGraph()
+	if graph != nil {
+		s.graph = graph.moved(document.URI, document.Includes, document.includeRanges)
+	}
+}
+
+// buildIncludeGraph builds the include graph of the known documents.
+// The caller must not hold any document lock.
+func (s *State) buildIncludeGraph() *IncludeGraph {
+	g := &IncludeGraph{
+		known: make(map[lsp.DocumentURI]bool),
+		edges: make(map[lsp.DocumentURI][]includeEdge),
+	}
+	documents := s.snapshot()
+	for _, document := range documents {
+		g.known[document.URI] = true
+		g.documents = append(g.documents, document.URI)
+	}
+	slices.Sort(g.documents)
+	for _, document := range documents {
+		document.locked(func() {
+			for i, include := range document.Includes {
+				to, ok := ResolveInclude(document.URI, include.Path)
+				if !ok || i >= len(document.includeRanges) {
+					continue
+				}
+				g.edges[document.URI] = append(g.edges[document.URI], includeEdge{
+					from:    document.URI,
+					to:      to,
+					include: include,
+					rng:     document.includeRanges[i],
+					found:   g.exists(to),
+				})
+			}
+		})
+	}
+	g.indexIncluders()
+	// resolving a path against the main configuration may make another main configuration included
+	for changed := true; changed; {
+		changed = false
+		for _, from := range g.documents {
+			for i := range g.edges[from] {
+				edge := &g.edges[from][i]
+				if edge.found || filepath.IsAbs(edge.include.Path) {
+					continue
+				}
+				for _, main := range g.Mains(from) {
+					if to, ok := ResolveInclude(main, edge.include.Path); ok && to != edge.to && g.exists(to) {
+						edge.to, edge.found, changed = to, true, true
+						break
+					}
+				}
+			}
+		}
+		if changed {
+			g.indexIncluders()
+		}
+	}
+	return g
+}
+
+// exists checks whether the file with the given URI is known or exists on disk.
+func (g *IncludeGraph) exists(uri lsp.DocumentURI) bool {
+	if g.known[uri] {
+		return true
+	}
+	path, err := uri.Path()
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// indexIncluders indexes the directives including a found file by included file.
+func (g *IncludeGraph) indexIncluders() {
+	g.includers = make(map[lsp.DocumentURI][]includeEdge)
+	for _, from := range g.documents {
+		for _, edge := range g.edges[from] {
+			if edge.found {
+				g.includers[edge.to] = append(g.includers[edge.to], edge)
+			}
+		}
+	}
+}
+
+// Included returns the files found for the directives of a document, in document order.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the including document.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the included files.
+func (g *IncludeGraph) Included(uri lsp.DocumentURI) []lsp.DocumentURI {
+	var included []lsp.DocumentURI
+	for _, edge := range g.edges[uri] {
+		if edge.found && !slices.Contains(included, edge.to) {
+			included = append(included, edge.to)
+		}
+	}
+	return included
+}
+
+// Includers returns the documents including a file.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the included file.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the including documents, sorted.
+func (g *IncludeGraph) Includers(uri lsp.DocumentURI) []lsp.DocumentURI {
+	var includers []lsp.DocumentURI
+	for _, edge := range g.includers[uri] {
+		if edge.from != uri && !slices.Contains(includers, edge.from) {
+			includers = append(includers, edge.from)
+		}
+	}
+	return includers
+}
+
+// Mains returns the main configurations a document belongs to: the documents included by no other
+// document that include it, directly or not. A document included by no other document is its own
+// main configuration, as is a document whose includers only include each other.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the main configurations, sorted.
+func (g *IncludeGraph) Mains(uri lsp.DocumentURI) []lsp.DocumentURI {
+	var mains []lsp.DocumentURI
+	visited := map[lsp.DocumentURI]bool{uri: true}
+	queue := []lsp.DocumentURI{uri}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if len(g.includers[current]) == 0 {
+			mains = append(mains, current)
+		}
+		for _, edge := range g.includers[current] {
+			if !visited[edge.from] {
+				visited[edge.from] = true
+				queue = append(queue, edge.from)
+			}
+		}
+	}
+	if len(mains) == 0 {
+		return []lsp.DocumentURI{uri}
+	}
+	slices.Sort(mains)
+	return mains
+}
+
+// Combined returns the documents making up the configurations a document belongs to:
+// its main configurations and every file they include, directly or not.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the documents, the document included, in include order.
+func (g *IncludeGraph) Combined(uri lsp.DocumentURI) []lsp.DocumentURI {
+	var combined []lsp.DocumentURI
+	visited := make(map[lsp.DocumentURI]bool)
+	var visit func(current lsp.DocumentURI)
+	visit = func(current lsp.DocumentURI) {
+		if visited[current] {
+			return
+		}
+		visited[current] = true
+		combined = append(combined, current)
+		for _, edge := range g.edges[current] {
+			if edge.found {
+				visit(edge.to)
+			}
+		}
+	}
+	for _, main := range g.Mains(uri) {
+		visit(main)
+	}
+	visit(uri)
+	return combined
+}
+
+// Files returns every file included by the known documents, whether it is found or not.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the included files.
+func (g *IncludeGraph) Files() []lsp.DocumentURI {
+	var files []lsp.DocumentURI
+	for _, from := range g.documents {
+		for _, edge := range g.edges[from] {
+			if !slices.Contains(files, edge.to) {
+				files = append(files, edge.to)
+			}
+		}
+	}
+	return files
+}
+
+// unknown returns the included files found on disk that are not known yet.
+// The files are the ones found when the graph was built, the disk is not checked again.
+func (g *IncludeGraph) unknown() []lsp.DocumentURI {
+	var unknown []lsp.DocumentURI
+	for _, from := range g.documents {
+		for _, edge := range g.edges[from] {
+			if edge.found && !g.known[edge.to] && !slices.Contains(unknown, edge.to) {
+				unknown = append(unknown, edge.to)
+			}
+		}
+	}
+	return unknown
+}
+
+// moved returns a copy of the graph with the include directives of the given document moved to
+// the given ranges, once an edit moved them without changing the files they include.
+// The graph itself is left untouched, as it may be in use.
+func (g *IncludeGraph) moved(uri lsp.DocumentURI, includes []kamailio_cfg.Include, ranges []lsp.Range) *IncludeGraph {
+	moved := *g
+	moved.edges = maps.Clone(g.edges)
+	edges := slices.Clone(g.edges[uri])
+	// the edges are the resolved directives, in document order, see buildIncludeGraph
+	next := 0
+	for i, include := range includes {
+		if _, ok := ResolveInclude(uri, include.Path); !ok || i >= len(ranges) || next >= len(edges) {
+			continue
+		}
+		edges[next].include = include
+		edges[next].rng = ranges[i]
+		next++
+	}
+	moved.edges[uri] = edges
+	moved.indexIncluders()
+	return &moved
+}
+
+// sameIncludes reports whether two lists of include directives include the same files in the same order,
+// wherever the directives are in the document.
+func sameIncludes(a []kamailio_cfg.Include, b []kamailio_cfg.Include) bool {
+	return slices.EqualFunc(a, b, func(x kamailio_cfg.Include, y kamailio_cfg.Include) bool {
+		return x.Path == y.Path && x.Import == y.Import
+	})
+}
+
+// Diagnostics returns the diagnostics of the include directives of a document: the included files
+// that are not found, the directives including a file that includes the document back, and the files
+// included more than once in a configuration. A missing file is not reported for import_file,
+// which Kamailio skips silently.
+//
+// Parameters:
+//
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	[]lsp.Diagnostic - The diagnostics, in document order.
+func (g *IncludeGraph) Diagnostics(uri lsp.DocumentURI) []lsp.Diagnostic {
+	reported := make(map[lsp.Range]lsp.Diagnostic)
+	report := func(edge includeEdge, severity lsp.DiagnosticSeverity, message string) {
+		if _, found := reported[edge.rng]; !found && edge.from == uri {
+			reported[edge.rng] = lsp.Diagnostic{
+				Range:    edge.rng,
+				Severity: severity,
+				Source:   settings.MY_NAME,
+				Message:  message,
+			}
+		}
+	}
+	for _, edge := range g.edges[uri] {
+		switch cycle := g.path(edge.to, uri); {
+		case !edge.found && !edge.include.Import:
+			report(edge, lsp.ERROR, "Included file not found: "+edge.include.Path)
+		case edge.found && cycle != nil:
+			report(edge, lsp.ERROR, "Include cycle: "+includePath(append([]lsp.DocumentURI{uri}, cycle...)))
+		}
+	}
+	// walk every configuration the document belongs to, in the order Kamailio reads the files
+	for _, main := range g.Mains(uri) {
+		first := map[lsp.DocumentURI]lsp.DocumentURI{main: ""} // the first includer of every file
+		var walk func(current lsp.DocumentURI)
+		walk = func(current lsp.DocumentURI) {
+			for _, edge := range g.edges[current] {
+				if !edge.found {
+					continue
+				}
+				if includer, found := first[edge.to]; found {
+					// including a file back is a cycle, reported above
+					if includer != "" && g.path(edge.to, current) == nil {
+						report(edge, lsp.WARNING, "File already included by "+fileName(includer))
+					}
+					continue
+				}
+				first[edge.to] = current
+				walk(edge.to)
+			}
+		}
+		walk(main)
+	}
+	diagnostics := make([]lsp.Diagnostic, 0, len(reported))
+	for _, diagnostic := range reported {
+		diagnostics = append(diagnostics, diagnostic)
+	}
+	slices.SortFunc(diagnostics, func(a, b lsp.Diagnostic) int {
+		if a.Range.Start.Line != b.Range.Start.Line {
+			return a.Range.Start.Line - b.Range.Start.Line
+		}
+		return a.Range.Start.Character - b.Range.Start.Character
+	})
+	return diagnostics
+}
+
+// path returns the files from a file to another through the found directives, both included,
+// or nil if the file does not include the other one, directly or not.
+func (g *IncludeGraph) path(from lsp.DocumentURI, to lsp.DocumentURI) []lsp.DocumentURI {
+	previous := map[lsp.DocumentURI]lsp.DocumentURI{from: ""}
+	queue := []lsp.DocumentURI{from}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == to {
+			var path []lsp.DocumentURI
+			for ; current != ""; current = previous[current] {
+				path = append(path, current)
+			}
+			slices.Reverse(path)
+			return path
+		}
+		for _, edge := range g.edges[current] {
+			if _, visited := previous[edge.to]; edge.found && !visited {
+				previous[edge.to] = current
+				queue = append(queue, edge.to)
+			}
+		}
+	}
+	return nil
+}
+
+// includePath returns the names of the given files, separated by arrows.
+func includePath(uris []lsp.DocumentURI) string {
+	names := make([]string, len(uris))
+	for i, uri := range uris {
+		names[i] = fileName(uri)
+	}
+	return strings.Join(names, " -> ")
+}
+
+// fileName returns the name of the file with the given URI.
+func fileName(uri lsp.DocumentURI) string {
+	if path, err := uri.Path(); err == nil {
+		return filepath.Base(path)
+	}
+	return string(uri)
+}
+
+// loadIncludes reads the included files that are not known yet from disk, along with the files
+// they include, and returns the include graph of the known documents. Missing files are skipped,
+// and so are the files left once the context is cancelled.
+// The caller must not hold any document lock.
+func (s *State) loadIncludes(ctx context.Context) *IncludeGraph {
+	for range max_include_loads {
+		graph := s.includeGraph()
+		loaded := false
+		for _, uri := range graph.unknown() {
+			added, err := s.loadDocument(ctx, uri, s.addDocumentIfAbsent)
+			if err != nil {
+				log.Debug().Err(err).Str("uri", string(uri)).Msg("Cannot read included file")
+			}
+			loaded = loaded || added
+		}
+		if !loaded {
+			return graph
+		}
+	}
+	log.Warn().Int("rounds", max_include_loads).Msg("Included files are nested too deep, stopped reading them")
+	return s.includeGraph()
+}
+
+// withIncludeDiagnostics returns the given diagnostics of a document along with the diagnostics
+// of its include directives, if the diagnostics are enabled in the settings of the session.
+func (s *State) withIncludeDiagnostics(graph *IncludeGraph, uri lsp.DocumentURI, diagnostics []lsp.Diagnostic) []lsp.Diagnostic {
+	if !s.Settings().EnableDiagnostics {
+		return diagnostics
+	}
+	return append(slices.Clone(diagnostics), graph.Diagnostics(uri)...)
+}
+
+// documentView is the analysis of a document of a combined view, copied under the document lock.
+// The analysis results of a document are replaced rather than modified, so they can be read
+// once the document is unlocked.
+type documentView struct {
+	uri        lsp.DocumentURI
+	index      *lsp.LineIndex // The line index of the text, built once per text and encoding, see Document.LineIndex.
+	symbols    *kamailio_cfg.SymbolTable
+	defines    []kamailio_cfg.Define
+	routes     []kamailio_cfg.NamedRoute
+	references *kamailio_cfg.ReferenceIndex
+	outline    []kamailio_cfg.OutlineItem
+}
+
+// combinedView returns the analysis of the documents making up the configurations of the document
+// with the given URI, the document left out, see IncludeGraph.Combined.
+// It returns the context error if the request was cancelled meanwhile.
+// The caller must not hold any document lock, the documents are locked one at a time.
+func (s *State) combinedView(ctx context.Context, uri lsp.DocumentURI) ([]documentView, error) {
+	encoding := s.PositionEncoding()
+	var views []documentView
+	for _, combined := range s.includeGraph().Combined(uri) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		document := s.GetDocument(combined)
+		if combined == uri || document == nil {
+			continue
+		}
+		document.locked(func() {
+			views = append(views, document.view(encoding))
+		})
+	}
+	return views, nil
+}
+
+// configurationView returns the analysis of the documents making up the configurations of the document
+// with the given URI, the document first, and false if the document is not known.
+// It returns the context error if the request was cancelled meanwhile.
+// The caller must not hold any document lock, the documents are locked one at a time.
+func (s *State) configurationView(ctx context.Context, uri lsp.DocumentURI) ([]documentView, bool, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return nil, false, err
+	}
+	document := s.GetDocument(uri)
+	if document == nil {
+		return nil, false, nil
+	}
+	var view documentView
+	document.locked(func() {
+		view = document.view(s.PositionEncoding())
+	})
+	return append([]documentView{view}, views...), true, nil
+}
+
+// view returns the analysis of the document, with the line index of its text in the given encoding.
+// The document must be locked.
+func (d *Document) view(encoding lsp.PositionEncodingKind) documentView {
+	return documentView{
+		uri:        d.URI,
+		index:      d.LineIndex(encoding),
+		symbols:    d.Symbols,
+		defines:    d.Defines,
+		routes:     d.Routes,
+		references: d.References,
+		outline:    d.Outline,
+	}
+}
+
+// symbolTables returns the symbol tables of the given views.
+func symbolTables(views []documentView) []*kamailio_cfg.SymbolTable {
+	tables := make([]*kamailio_cfg.SymbolTable, len(views))
+	for i, view := range views {
+		tables[i] = view.symbols
+	}
+	return tables
+}
+
+// location returns the location of the given range of the view.
+func (v documentView) location(start sitter.Point, end sitter.Point) lsp.Location {
+	return lsp.Location{
+		URI:   v.uri,
+		Range: v.index.Range(start, end),
+	}
+}
+
+// findDefine looks up a define by name in the document, then in the views of its configuration.
+// It returns the define and the view defining it, nil when the document defines it.
+// The document must be locked.
+func findDefine(document *Document, views []documentView, name string) (*kamailio_cfg.Define, *documentView) {
+	for i := range document.Defines {
+		if document.Defines[i].Name == name {
+			return &document.Defines[i], nil
+		}
+	}
+	for i := range views {
+		for j := range views[i].defines {
+			if views[i].defines[j].Name == name {
+				return &views[i].defines[j], &views[i]
+			}
+		}
+	}
+	return nil, nil
+}
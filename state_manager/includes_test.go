@@ -0,0 +1,190 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"KamaiZen/state_manager"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeFiles writes the given files, by path relative to the returned directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, text := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestIncludeDiagnostics(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"sub/a.cfg\"\ninclude_file \"missing.cfg\"\nimport_file \"optional.cfg\"\ninclude_file \"sub/a.cfg\"\n",
+		// not next to a.cfg, found in the directory of the main configuration
+		"sub/a.cfg": "include_file \"b.cfg\"\n",
+		"b.cfg":     "#!define WITH_B\n",
+	})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	text, _ := os.ReadFile(filepath.Join(dir, "kamailio.cfg"))
+	diagnostics := state.OpenDocument(context.Background(), main, 1, string(text))
+	expected := []struct {
+		line     int
+		severity lsp.DiagnosticSeverity
+		message  string
+	}{
+		{1, lsp.ERROR, "Included file not found: missing.cfg"},
+		{3, lsp.WARNING, "File already included by kamailio.cfg"},
+	}
+	if len(diagnostics) != len(expected) {
+		t.Fatalf("Expected: %d diagnostics,\ngot: %+v", len(expected), diagnostics)
+	}
+	for i, e := range expected {
+		d := diagnostics[i]
+		if d.Range.Start.Line != e.line || d.Severity != e.severity || d.Message != e.message {
+			t.Fatalf("Expected: %+v,\ngot: %+v", e, d)
+		}
+	}
+	if state.GetDocument(lsp.PathToURI(filepath.Join(dir, "b.cfg"))) == nil {
+		t.Fatalf("Expected: b.cfg read from the main configuration directory,\ngot: %v", state.IncludedFiles())
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"a.cfg": "include_file \"b.cfg\"\n",
+		"b.cfg": "include_file \"a.cfg\"\n",
+	})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	uri := lsp.PathToURI(filepath.Join(dir, "a.cfg"))
+	diagnostics := state.OpenDocument(context.Background(), uri, 1, "include_file \"b.cfg\"\n")
+	expected := "Include cycle: a.cfg -> b.cfg -> a.cfg"
+	if len(diagnostics) != 1 || diagnostics[0].Message != expected {
+		t.Fatalf("Expected: %s,\ngot: %+v", expected, diagnostics)
+	}
+}
+
+func TestIncludedFileCreated(t *testing.T) {
+	dir := writeFiles(t, map[string]string{})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	routes := lsp.PathToURI(filepath.Join(dir, "routes.cfg"))
+	if diagnostics := state.OpenDocument(context.Background(), main, 1, "include_file \"routes.cfg\"\n"); len(diagnostics) != 1 {
+		t.Fatalf("Expected: routes.cfg not found,\ngot: %+v", diagnostics)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "routes.cfg"), []byte("route[AUTH] {\n}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the include graph kept by the state is built again once the file is created
+	diagnostics, found := state.FileChanged(context.Background(), routes, lsp.FILE_CREATED)[main]
+	if !found || len(diagnostics) != 0 {
+		t.Fatalf("Expected: no diagnostics for %s,\ngot: %+v", main, diagnostics)
+	}
+	if state.GetDocument(routes) == nil {
+		t.Fatalf("Expected: %s read from disk,\ngot: unknown", routes)
+	}
+}
+
+func TestIncludeMoved(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"routes.cfg": "route[AUTH] {\n}\n"})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	state.OpenDocument(context.Background(), main, 1, "include_file \"routes.cfg\"\ninclude_file \"missing.cfg\"\n")
+	// the edit moves the include directives without changing the files they include
+	insert := []lsp.TextDocumentContentChangeEvent{{
+		Range: &lsp.Range{Start: lsp.Position{Line: 0, Character: 0}, End: lsp.Position{Line: 0, Character: 0}},
+		Text:  "#!define WITH_AUTH\n",
+	}}
+	diagnostics := state.UpdateDocument(context.Background(), main, 2, insert)
+	if len(diagnostics) != 1 || diagnostics[0].Range.Start.Line != 2 {
+		t.Fatalf("Expected: missing.cfg not found on line 2,\ngot: %+v", diagnostics)
+	}
+	routes := lsp.PathToURI(filepath.Join(dir, "routes.cfg"))
+	if files := state.IncludedFiles(); len(files) != 2 || files[0] != routes {
+		t.Fatalf("Expected: routes.cfg and missing.cfg,\ngot: %v", files)
+	}
+}
+
+func TestSessionSettings(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"missing.cfg\"\n",
+	})
+	uri := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	enabled, disabled := state_manager.NewState(), state_manager.NewState()
+	enabled.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	disabled.ApplySettings(settings.LSPSettings{EnableDiagnostics: false})
+	// the settings of a session do not leak into another session
+	if diagnostics := enabled.OpenDocument(context.Background(), uri, 1, "include_file \"missing.cfg\"\n"); len(diagnostics) != 1 {
+		t.Fatalf("Expected: 1 diagnostic,\ngot: %+v", diagnostics)
+	}
+	if diagnostics := disabled.OpenDocument(context.Background(), uri, 1, "include_file \"missing.cfg\"\n"); len(diagnostics) != 0 {
+		t.Fatalf("Expected: no diagnostics,\ngot: %+v", diagnostics)
+	}
+	if enabled.Modules() == disabled.Modules() {
+		t.Fatalf("Expected: a module index per session,\ngot: a shared index")
+	}
+}
+
+func TestCancelledRequest(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"routes.cfg\"\nrequest_route {\n  route(AUTH);\n}\n",
+		"routes.cfg":   "route[AUTH] {\n}\n",
+	})
+	state := state_manager.NewState()
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	text, _ := os.ReadFile(filepath.Join(dir, "kamailio.cfg"))
+	state.OpenDocument(context.Background(), main, 1, string(text))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	position := lsp.Position{Line: 2, Character: 9}
+	if _, err := state.References(ctx, lsp.NewIntID(1), main, position, true); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected: %v,\ngot: %v", context.Canceled, err)
+	}
+	if _, err := state.Definition(ctx, lsp.NewIntID(2), main, position); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected: %v,\ngot: %v", context.Canceled, err)
+	}
+	if _, err := state.SemanticTokens(ctx, lsp.NewIntID(3), main); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected: %v,\ngot: %v", context.Canceled, err)
+	}
+}
+
+func TestCancelledUpdate(t *testing.T) {
+	state := state_manager.NewState()
+	uri := lsp.DocumentURI("file:///kamailio.cfg")
+	state.OpenDocument(context.Background(), uri, 1, "route[A] {\n}\n")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	change := []lsp.TextDocumentContentChangeEvent{{Text: "route[B] {\n}\n"}}
+	state.UpdateDocument(ctx, uri, 2, change)
+	routes := func() []string {
+		document := state.GetDocument(uri)
+		document.Lock()
+		defer document.Unlock()
+		var names []string
+		for _, route := range document.Routes {
+			names = append(names, route.Name)
+		}
+		return names
+	}
+	// the cancelled analysis keeps the previous one, the next update analyses the text again
+	if names := routes(); len(names) != 1 || names[0] != "A" {
+		t.Fatalf("Expected: [A],\ngot: %v", names)
+	}
+	state.UpdateDocument(context.Background(), uri, 3, nil)
+	if names := routes(); len(names) != 1 || names[0] != "B" {
+		t.Fatalf("Expected: [B],\ngot: %v", names)
+	}
+}
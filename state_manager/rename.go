@@ -0,0 +1,203 @@
+package state_manager
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+var (
+	routeNamePattern  = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+	identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// renamableRoutes are the types of the routing blocks that can be renamed, the other ones are
+// named by Kamailio or its modules, e.g. event_route[xhttp:request].
+var renamableRoutes = map[string]bool{
+	kamailio_cfg.RouteBlockType: true,
+	"failure_route":             true,
+	"branch_route":              true,
+	"onreply_route":             true,
+}
+
+// renameError returns the error sent back to the client when a name cannot be renamed.
+func renameError(format string, args ...any) error {
+	return lsp.NewResponseError(lsp.REQUEST_FAILED, fmt.Sprintf(format, args...))
+}
+
+// renameTarget returns the reference at the given point, if it can be renamed.
+// The routes, the defines and the local variables can be renamed.
+// The document must be locked.
+func renameTarget(document *Document, point sitter.Point) (*kamailio_cfg.Reference, error) {
+	target := document.References.At(point)
+	if target == nil {
+		return nil, renameError("Only routes, #!defines and $var(...) variables can be renamed")
+	}
+	switch target.Kind {
+	case kamailio_cfg.RouteReference:
+		if !renamableRoutes[target.RouteType] {
+			return nil, renameError("%s[%s] is named by Kamailio and cannot be renamed", target.RouteType, target.Name)
+		}
+	case kamailio_cfg.DefineReference:
+	case kamailio_cfg.VariableReference:
+		if target.Variable.Kind != kamailio_cfg.LocalVariable {
+			return nil, renameError("%s is not scoped to the configuration, only $var(...) variables can be renamed", target.Name)
+		}
+	default:
+		return nil, renameError("Modules cannot be renamed")
+	}
+	return target, nil
+}
+
+// targetName returns the name of a reference, as edited by a rename.
+func targetName(target *kamailio_cfg.Reference) string {
+	if target.Kind == kamailio_cfg.VariableReference {
+		return target.Variable.Identifier
+	}
+	return target.Name
+}
+
+// PrepareRename checks whether the name at the given point can be renamed.
+//
+// Parameters:
+//
+//	document *Document - The locked document.
+//	point sitter.Point - The point within the document.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	lsp.Range - The range of the name.
+//	string - The name.
+//	error - The reason the name cannot be renamed.
+func PrepareRename(document *Document, point sitter.Point, encoding lsp.PositionEncodingKind) (lsp.Range, string, error) {
+	target, err := renameTarget(document, point)
+	if err != nil {
+		return lsp.Range{}, "", err
+	}
+	start, end := target.NameRange()
+	return document.LineIndex(encoding).Range(start, end), targetName(target), nil
+}
+
+// Rename returns the edits renaming the route, the define or the local variable at the given point.
+// A route is renamed along with its route(...) calls and the t_on_failure("...") like uses,
+// a define along with its #!ifdef and uses, in the whole configuration the document belongs to.
+// A local variable is only renamed within its route.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the rename request.
+//	document *Document - The locked document.
+//	views []documentView - The other documents of the configuration, see State.combinedView.
+//	point sitter.Point - The point within the document.
+//	newName string - The new name, $var(name) or name for a variable.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+//
+// Returns:
+//
+//	map[lsp.DocumentURI][]lsp.TextEdit - The edits of every document using the name.
+//	error - The reason the name cannot be renamed, the new name refused, or the context error.
+func Rename(
+	ctx context.Context,
+	document *Document,
+	views []documentView,
+	point sitter.Point,
+	newName string,
+	encoding lsp.PositionEncodingKind,
+) (map[lsp.DocumentURI][]lsp.TextEdit, error) {
+	target, err := renameTarget(document, point)
+	if err != nil {
+		return nil, err
+	}
+	newName = strings.TrimSpace(newName)
+	probe := *target
+	switch target.Kind {
+	case kamailio_cfg.RouteReference:
+		newName = strings.Trim(newName, "\"'")
+		if !routeNamePattern.MatchString(newName) {
+			return nil, renameError("%q is not a valid route name, use letters, digits and underscores", newName)
+		}
+		probe.Name = newName
+	case kamailio_cfg.DefineReference:
+		if !identifierPattern.MatchString(newName) {
+			return nil, renameError("%q is not a valid define name, use letters, digits and underscores, not starting with a digit", newName)
+		}
+		probe.Name = newName
+	case kamailio_cfg.VariableReference:
+		if name, found := strings.CutPrefix(newName, "$var("); found {
+			newName = strings.TrimSuffix(name, ")")
+		}
+		if !identifierPattern.MatchString(newName) {
+			return nil, renameError("%q is not a valid variable name, use letters, digits and underscores, not starting with a digit", newName)
+		}
+		probe.Name = "$var(" + newName + ")"
+		probe.Variable.Name = probe.Name
+	}
+	changes := make(map[lsp.DocumentURI][]lsp.TextEdit)
+	if newName == targetName(target) {
+		return changes, nil
+	}
+	if err := renameCollision(document, views, target, probe); err != nil {
+		return nil, err
+	}
+	index := document.LineIndex(encoding)
+	for _, reference := range document.References.Find(*target) {
+		start, end := reference.NameRange()
+		changes[document.URI] = append(changes[document.URI], lsp.TextEdit{Range: index.Range(start, end), NewText: newName})
+	}
+	if target.Local() {
+		return changes, nil
+	}
+	for _, view := range views {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		references := view.references.Find(*target)
+		if len(references) == 0 {
+			continue
+		}
+		for _, reference := range references {
+			start, end := reference.NameRange()
+			changes[view.uri] = append(changes[view.uri], lsp.TextEdit{Range: view.index.Range(start, end), NewText: newName})
+		}
+	}
+	return changes, nil
+}
+
+// renameCollision checks that the new name, given by the probe reference, is not already taken:
+// by a route of the same type or a define in the configuration, or by a variable of the same route.
+func renameCollision(document *Document, views []documentView, target *kamailio_cfg.Reference, probe kamailio_cfg.Reference) error {
+	taken := func(index *kamailio_cfg.ReferenceIndex) bool {
+		for _, reference := range index.Find(probe) {
+			// a variable is taken as soon as it is used, a route or a define once it is declared
+			if reference.Declaration || target.Kind == kamailio_cfg.VariableReference {
+				return true
+			}
+		}
+		return false
+	}
+	where := fileName(document.URI)
+	collides := taken(document.References)
+	for i := 0; !collides && !target.Local() && i < len(views); i++ {
+		collides = taken(views[i].references)
+		where = fileName(views[i].uri)
+	}
+	if !collides {
+		return nil
+	}
+	switch target.Kind {
+	case kamailio_cfg.RouteReference:
+		return renameError("%s[%s] already exists in %s", probe.RouteType, probe.Name, where)
+	case kamailio_cfg.DefineReference:
+		return renameError("#!define %s already exists in %s", probe.Name, where)
+	}
+	if probe.Variable.Route == "" {
+		return renameError("%s is already used outside the routes", probe.Name)
+	}
+	return renameError("%s is already used in %s", probe.Name, probe.Variable.Route)
+}
@@ -0,0 +1,61 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"KamaiZen/state_manager"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRename(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"routes.cfg\"\nrequest_route {\n  t_on_failure(\"FAIL\");\n  route(AUTH);\n}\n",
+		"routes.cfg":   "route[AUTH] {\n  $var(x) = 1;\n  $var(y) = $var(x);\n}\nroute[OTHER] {\n}\nfailure_route[FAIL] {\n}\n",
+	})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	routes := lsp.PathToURI(filepath.Join(dir, "routes.cfg"))
+	text, _ := os.ReadFile(filepath.Join(dir, "kamailio.cfg"))
+	state.OpenDocument(context.Background(), main, 1, string(text))
+
+	response, err := state.Rename(context.Background(), lsp.NewIntID(1), main, lsp.Position{Line: 3, Character: 9}, "AUTHENTICATE")
+	if err != nil {
+		t.Fatalf("Expected: the route renamed,\ngot: %v", err)
+	}
+	changes := response.Result.Changes
+	if len(changes[main]) != 1 || len(changes[routes]) != 1 || changes[routes][0].Range.Start != (lsp.Position{Line: 0, Character: 6}) {
+		t.Fatalf("Expected: the call and the route block renamed,\ngot: %+v", changes)
+	}
+
+	response, err = state.Rename(context.Background(), lsp.NewIntID(2), main, lsp.Position{Line: 2, Character: 17}, "FAILED")
+	if err != nil || len(response.Result.Changes[main]) != 1 || response.Result.Changes[main][0].Range.Start != (lsp.Position{Line: 2, Character: 16}) {
+		t.Fatalf("Expected: the failure route renamed inside the quotes,\ngot: %+v %v", response.Result, err)
+	}
+
+	for _, test := range []struct {
+		uri      lsp.DocumentURI
+		position lsp.Position
+		newName  string
+		expected string
+	}{
+		{main, lsp.Position{Line: 3, Character: 9}, "OTHER", "route[OTHER] already exists in routes.cfg"},
+		{main, lsp.Position{Line: 3, Character: 9}, "NO-WAY", "\"NO-WAY\" is not a valid route name, use letters, digits and underscores"},
+		{routes, lsp.Position{Line: 1, Character: 8}, "$var(y)", "$var(y) is already used in route[AUTH]"},
+		{main, lsp.Position{Line: 0, Character: 3}, "x", "Only routes, #!defines and $var(...) variables can be renamed"},
+	} {
+		_, err := state.Rename(context.Background(), lsp.NewIntID(3), test.uri, test.position, test.newName)
+		responseError, ok := err.(*lsp.ResponseError)
+		if !ok || responseError.Message != test.expected {
+			t.Fatalf("Expected: %s,\ngot: %v", test.expected, err)
+		}
+	}
+
+	response, err = state.Rename(context.Background(), lsp.NewIntID(4), routes, lsp.Position{Line: 2, Character: 18}, "z")
+	if err != nil || len(response.Result.Changes) != 1 || len(response.Result.Changes[routes]) != 2 {
+		t.Fatalf("Expected: both uses of $var(x) renamed,\ngot: %+v %v", response.Result, err)
+	}
+}
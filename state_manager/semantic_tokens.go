@@ -0,0 +1,209 @@
+package state_manager
+
+import (
+	"KamaiZen/document_manager"
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"context"
+	"strconv"
+)
+
+// semanticTokenTypes maps the types of the semantic tokens of the analysis to the types of the legend.
+var semanticTokenTypes = map[kamailio_cfg.SemanticTokenType]lsp.SemanticTokenType{
+	kamailio_cfg.NamespaceToken: lsp.NAMESPACE_TOKEN,
+	kamailio_cfg.ParameterToken: lsp.PARAMETER_TOKEN,
+	kamailio_cfg.VariableToken:  lsp.VARIABLE_TOKEN,
+	kamailio_cfg.PropertyToken:  lsp.PROPERTY_TOKEN,
+	kamailio_cfg.FunctionToken:  lsp.FUNCTION_TOKEN,
+	kamailio_cfg.MacroToken:     lsp.MACRO_TOKEN,
+	kamailio_cfg.KeywordToken:   lsp.KEYWORD_TOKEN,
+	kamailio_cfg.CommentToken:   lsp.COMMENT_TOKEN,
+	kamailio_cfg.StringToken:    lsp.STRING_TOKEN,
+	kamailio_cfg.NumberToken:    lsp.NUMBER_TOKEN,
+	kamailio_cfg.RegexpToken:    lsp.REGEXP_TOKEN,
+	kamailio_cfg.OperatorToken:  lsp.OPERATOR_TOKEN,
+}
+
+// semanticTokenModifiers maps the modifiers of the semantic tokens of the analysis to the modifiers of the legend.
+var semanticTokenModifiers = map[kamailio_cfg.SemanticTokenModifiers]lsp.SemanticTokenModifiers{
+	kamailio_cfg.ReadonlyModifier:       lsp.READONLY_MODIFIER,
+	kamailio_cfg.DeprecatedModifier:     lsp.DEPRECATED_MODIFIER,
+	kamailio_cfg.DefaultLibraryModifier: lsp.DEFAULT_LIBRARY_MODIFIER,
+}
+
+// SemanticTokens returns the semantic tokens of the document with the given URI,
+// and keeps them as the previous result of the following delta request.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the semantic tokens request.
+//	id lsp.ID - The ID of the semantic tokens request.
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	lsp.SemanticTokensResponse - The semantic tokens of the document, none if it is not known.
+//	error - The context error if the request was cancelled.
+func (s *State) SemanticTokens(ctx context.Context, id lsp.ID, uri lsp.DocumentURI) (lsp.SemanticTokensResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewSemanticTokensResponse(id, lsp.SemanticTokens{}), nil
+	}
+	defer document.Unlock()
+	tokens, err := document.nextSemanticTokens(ctx, encoding, s.modules)
+	if err != nil {
+		return lsp.SemanticTokensResponse{}, err
+	}
+	return lsp.NewSemanticTokensResponse(id, tokens), nil
+}
+
+// SemanticTokensDelta returns the changes of the semantic tokens of the document with the given URI
+// since the previous result, or all of them if the previous result is not the last one sent.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the semantic tokens delta request.
+//	id lsp.ID - The ID of the semantic tokens delta request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	previous string - The ID of the previous result, as sent by the client.
+//
+// Returns:
+//
+//	lsp.SemanticTokensDeltaResponse - The changes of the semantic tokens, or all of them.
+//	error - The context error if the request was cancelled.
+func (s *State) SemanticTokensDelta(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, previous string) (lsp.SemanticTokensDeltaResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewSemanticTokensFullDeltaResponse(id, lsp.SemanticTokens{}), nil
+	}
+	defer document.Unlock()
+	last := document.semanticTokens
+	tokens, err := document.nextSemanticTokens(ctx, encoding, s.modules)
+	if err != nil {
+		return lsp.SemanticTokensDeltaResponse{}, err
+	}
+	if last.ResultID == "" || last.ResultID != previous {
+		return lsp.NewSemanticTokensFullDeltaResponse(id, tokens), nil
+	}
+	return lsp.NewSemanticTokensDeltaResponse(id, lsp.SemanticTokensDelta{
+		ResultID: tokens.ResultID,
+		Edits:    semanticTokensEdits(last.Data, tokens.Data),
+	}), nil
+}
+
+// SemanticTokensRange returns the semantic tokens of the document with the given URI within the range.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the semantic tokens range request.
+//	id lsp.ID - The ID of the semantic tokens range request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	rng lsp.Range - The range, the tokens overlapping it are returned.
+//
+// Returns:
+//
+//	lsp.SemanticTokensResponse - The semantic tokens within the range, none if the document is not known.
+//	error - The context error if the request was cancelled.
+func (s *State) SemanticTokensRange(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, rng lsp.Range) (lsp.SemanticTokensResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewSemanticTokensResponse(id, lsp.SemanticTokens{}), nil
+	}
+	defer document.Unlock()
+	index := document.LineIndex(encoding)
+	start, end := index.PointAt(rng.Start), index.PointAt(rng.End)
+	list, err := document.semanticTokenList(ctx, s.modules)
+	if err != nil {
+		return lsp.SemanticTokensResponse{}, err
+	}
+	var tokens []kamailio_cfg.SemanticToken
+	for _, token := range list {
+		if token.Overlaps(start, end) {
+			tokens = append(tokens, token)
+		}
+	}
+	return lsp.NewSemanticTokensResponse(id, lsp.SemanticTokens{Data: encodeSemanticTokens(tokens, index)}), nil
+}
+
+// semanticTokenList highlights the locked document, see kamailio_cfg.SemanticTokens.
+// The functions documented by the core cookbook or by a module of the given index are the default library.
+func (d *Document) semanticTokenList(ctx context.Context, modules *document_manager.ModuleIndex) ([]kamailio_cfg.SemanticToken, error) {
+	library := make(map[string]bool)
+	return kamailio_cfg.SemanticTokens(ctx, d.Analyzer, []byte(d.Text), func(name string) bool {
+		found, known := library[name]
+		if !known {
+			found = document_manager.GetCookBookDocs(name) != "" ||
+				modules.FindFunctionInAllModules(name) != "Function not found"
+			library[name] = found
+		}
+		return found
+	})
+}
+
+// nextSemanticTokens encodes the semantic tokens of the locked document as a new result,
+// kept as the previous result of the following delta request unless the context is done.
+func (d *Document) nextSemanticTokens(ctx context.Context, encoding lsp.PositionEncodingKind, modules *document_manager.ModuleIndex) (lsp.SemanticTokens, error) {
+	tokens, err := d.semanticTokenList(ctx, modules)
+	if err != nil {
+		return lsp.SemanticTokens{}, err
+	}
+	d.semanticTokensID++
+	d.semanticTokens = lsp.SemanticTokens{
+		ResultID: strconv.Itoa(d.semanticTokensID),
+		Data:     encodeSemanticTokens(tokens, d.LineIndex(encoding)),
+	}
+	return d.semanticTokens, nil
+}
+
+// encodeSemanticTokens encodes the tokens as five integers each, their positions relative to the previous token,
+// with the columns converted to the position encoding of the line index.
+func encodeSemanticTokens(tokens []kamailio_cfg.SemanticToken, index *lsp.LineIndex) []uint32 {
+	data := make([]uint32, 0, 5*len(tokens))
+	var line, character uint32
+	for _, token := range tokens {
+		rng := index.Range(token.StartPoint, token.EndPoint)
+		start := uint32(rng.Start.Character)
+		if uint32(rng.Start.Line) == line {
+			start -= character
+		}
+		var modifiers lsp.SemanticTokenModifiers
+		for modifier, bit := range semanticTokenModifiers {
+			if token.Modifiers&modifier != 0 {
+				modifiers |= bit
+			}
+		}
+		data = append(data,
+			uint32(rng.Start.Line)-line,
+			start,
+			uint32(rng.End.Character-rng.Start.Character),
+			uint32(semanticTokenTypes[token.Type]),
+			uint32(modifiers),
+		)
+		line, character = uint32(rng.Start.Line), uint32(rng.Start.Character)
+	}
+	return data
+}
+
+// semanticTokensEdits returns the edit replacing the tokens that changed between the previous data
+// and the new one, none if they are equal. The edit keeps the common tokens at both ends.
+func semanticTokensEdits(previous []uint32, data []uint32) []lsp.SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(previous) && prefix < len(data) && previous[prefix] == data[prefix] {
+		prefix++
+	}
+	prefix -= prefix % 5
+	if prefix == len(previous) && prefix == len(data) {
+		return nil
+	}
+	suffix := 0
+	for suffix < len(previous)-prefix && suffix < len(data)-prefix &&
+		previous[len(previous)-1-suffix] == data[len(data)-1-suffix] {
+		suffix++
+	}
+	suffix -= suffix % 5
+	return []lsp.SemanticTokensEdit{{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(previous) - prefix - suffix),
+		Data:        data[prefix : len(data)-suffix],
+	}}
+}
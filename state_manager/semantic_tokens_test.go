@@ -0,0 +1,67 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/state_manager"
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSemanticTokensDelta(t *testing.T) {
+	state := state_manager.NewState()
+	uri := lsp.DocumentURI("file:///tmp/kamailio.cfg")
+	state.OpenDocument(context.Background(), uri, 1, "#!define A 1\nrequest_route {\n  $var(a) = 1;\n}\n")
+	response, err := state.SemanticTokens(context.Background(), lsp.NewIntID(1), uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := response.Result
+	if full.ResultID == "" || len(full.Data)%5 != 0 || len(full.Data) == 0 {
+		t.Fatalf("Expected: tokens with a result ID,\ngot: %+v", full)
+	}
+	// #!define A is the first token, a macro on the first line
+	if expected := []uint32{0, 0, 10, uint32(lsp.MACRO_TOKEN), 0}; !reflect.DeepEqual(full.Data[:5], expected) {
+		t.Fatalf("Expected: %v,\ngot: %v", expected, full.Data[:5])
+	}
+
+	state.UpdateDocument(context.Background(), uri, 2, []lsp.TextDocumentContentChangeEvent{{
+		Range: &lsp.Range{Start: lsp.Position{Line: 2, Character: 12}, End: lsp.Position{Line: 2, Character: 12}},
+		Text:  "\n  $var(b) = 2;",
+	}})
+	deltaResponse, err := state.SemanticTokensDelta(context.Background(), lsp.NewIntID(2), uri, full.ResultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, ok := deltaResponse.Result.(lsp.SemanticTokensDelta)
+	if !ok || len(delta.Edits) != 1 || delta.ResultID == full.ResultID {
+		t.Fatalf("Expected: a single edit,\ngot: %+v", delta)
+	}
+	edit := delta.Edits[0]
+	if edit.DeleteCount != 0 || len(edit.Data) == 0 || edit.Start%5 != 0 {
+		t.Fatalf("Expected: inserted tokens,\ngot: %+v", edit)
+	}
+
+	deltaResponse, err = state.SemanticTokensDelta(context.Background(), lsp.NewIntID(3), uri, full.ResultID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := deltaResponse.Result.(lsp.SemanticTokens); !ok {
+		t.Fatalf("Expected: all the tokens for an outdated result ID")
+	}
+
+	line := lsp.Range{Start: lsp.Position{Line: 3, Character: 0}, End: lsp.Position{Line: 4, Character: 0}}
+	response, err = state.SemanticTokensRange(context.Background(), lsp.NewIntID(4), uri, line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ranged := response.Result
+	if len(ranged.Data) == 0 || ranged.Data[0] != 3 {
+		t.Fatalf("Expected: the tokens of line 3,\ngot: %v", ranged.Data)
+	}
+	for i := 5; i < len(ranged.Data); i += 5 {
+		if ranged.Data[i] != 0 {
+			t.Fatalf("Expected: the tokens of line 3 only,\ngot: %v", ranged.Data)
+		}
+	}
+}
@@ -1,97 +1,137 @@
 package state_manager
 
 import (
+	"KamaiZen/document_manager"
 	"KamaiZen/kamailio_cfg"
 	"KamaiZen/lsp"
 	"KamaiZen/settings"
+	"context"
 	"fmt"
+	"sync"
 
 	"github.com/rs/zerolog/log"
+	sitter "github.com/smacker/go-tree-sitter"
 )
 
+// State holds the documents known to a session, along with the settings of the session
+// and the documentation of the Kamailio modules it indexed.
+// It is shared by the handlers of the session running concurrently: the state lock guards
+// the documents map, and every document has its own lock guarding its content and analysis.
 type State struct {
-	Documents map[lsp.DocumentURI]string // A map of document URIs to their corresponding text content.
-	Analyzer  *kamailio_cfg.Analyzer     // The analyzer used for parsing and analyzing the documents.
-}
+	mu        sync.Mutex
+	documents map[lsp.DocumentURI]*Document // A map of document URIs to their corresponding documents.
+	encoding  lsp.PositionEncodingKind      // The position encoding negotiated with the client.
+	workspace workspace                     // The workspace folders and the globs of their configuration files.
+	settings  settings.LSPSettings          // The settings of the session.
+	modules   *document_manager.ModuleIndex // The documentation of the Kamailio modules, see document_manager.ModuleIndex.
 
-var state State
+	graph        *IncludeGraph // The include graph of the known documents, nil until built, see includeGraph.
+	graphVersion int           // Bumped whenever the include graph is dropped, see dropIncludeGraph.
+}
 
-// GetState returns the current state.
+// NewState creates and returns a new instance of State.
+// It initializes the documents map and an empty module documentation index.
 //
 // Returns:
 //
-//	*State - The current state.
-func GetState() *State {
-	return &state
+//	*State - The initialized state.
+func NewState() *State {
+	return &State{
+		documents: make(map[lsp.DocumentURI]*Document),
+		encoding:  lsp.UTF16,
+		modules:   document_manager.NewModuleIndex(),
+	}
 }
 
-// SetState sets the current state to the given state.
+// ApplySettings replaces the settings of the session.
+// The documents are not analysed again, see AnalyseAll.
 //
 // Parameters:
 //
-//	s State - The new state to be set.
-func SetState(s State) {
-	state = s
-}
-
-// updateState updates the state with the given document URI and text.
-// It also reinitializes the analyzer.
+//	config settings.LSPSettings - The new settings.
 //
-// Parameters:
+// Returns:
 //
-//	DocumentURI lsp.DocumentURI - The URI of the document to be updated.
-//	text string - The new text content of the document.
-func (s *State) updateState(DocumentURI lsp.DocumentURI, text string) {
-	state.Documents[DocumentURI] = text
-	s.Analyzer = kamailio_cfg.NewAnalyzer()
+//	settings.LSPSettings - The settings previously in effect.
+func (s *State) ApplySettings(config settings.LSPSettings) settings.LSPSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous := s.settings
+	s.settings = config
+	return previous
 }
 
-// InitializeState initializes and returns a new state.
-// It creates a new state, initializes the analyzer, and logs the initialization.
+// Settings returns the settings of the session.
 //
 // Returns:
 //
-//	State - The initialized state.
-func InitializeState() State {
-	state = NewState()
-	state.Analyzer = kamailio_cfg.NewAnalyzer()
-	kamailio_cfg.InitialiseVariables()
-	return state
+//	settings.LSPSettings - A copy of the settings in effect.
+func (s *State) Settings() settings.LSPSettings {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.settings
 }
 
-// NewState creates and returns a new instance of State.
-// It initializes the Documents map.
+// Modules returns the documentation of the Kamailio modules indexed for the session.
 //
 // Returns:
 //
-//	State - The initialized state.
-func NewState() State {
-	return State{
-		Documents: make(map[lsp.DocumentURI]string),
-	}
+//	*document_manager.ModuleIndex - The module documentation index.
+func (s *State) Modules() *document_manager.ModuleIndex {
+	return s.modules
 }
 
-// GetDocument returns the text content of the document with the given URI.
+// SetPositionEncoding sets the encoding of the positions exchanged with the client.
 //
 // Parameters:
 //
-//	uri lsp.DocumentURI - The URI of the document.
+//	encoding lsp.PositionEncodingKind - The position encoding negotiated with the client.
+func (s *State) SetPositionEncoding(encoding lsp.PositionEncodingKind) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.encoding = encoding
+	// the ranges of the include directives depend on the encoding
+	s.dropIncludeGraph()
+}
+
+// PositionEncoding returns the encoding of the positions exchanged with the client.
 //
 // Returns:
 //
-//	string - The text content of the document.
-func (s *State) GetDocument(uri lsp.DocumentURI) string {
-	return s.Documents[uri]
+//	lsp.PositionEncodingKind - The position encoding negotiated with the client.
+func (s *State) PositionEncoding() lsp.PositionEncodingKind {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoding
 }
 
-// SetDocument sets the text content of the document with the given URI.
+// GetDocument returns the document with the given URI.
+// The document must be locked before its fields are accessed.
 //
 // Parameters:
 //
 //	uri lsp.DocumentURI - The URI of the document.
-//	text string - The new text content of the document.
-func (s *State) SetDocument(uri lsp.DocumentURI, text string) {
-	s.Documents[uri] = text
+//
+// Returns:
+//
+//	*Document - The document, or nil if the document is not known.
+func (s *State) GetDocument(uri lsp.DocumentURI) *Document {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.documents[uri]
+}
+
+// lockedDocument returns the document with the given URI, locked, and the position encoding.
+// The caller must unlock the document. A nil document is returned if the document is not known.
+func (s *State) lockedDocument(uri lsp.DocumentURI) (*Document, lsp.PositionEncodingKind) {
+	document := s.GetDocument(uri)
+	encoding := s.PositionEncoding()
+	if document == nil {
+		log.Warn().Str("uri", string(uri)).Msg("Document is not open")
+		return nil, encoding
+	}
+	document.Lock()
+	return document, encoding
 }
 
 func (s *State) RegisterSubscribers() {
@@ -108,131 +148,506 @@ func (s *State) NotifySubsrcibers(uri lsp.DocumentURI, changes []lsp.TextDocumen
 }
 
 // OpenDocument opens the document with the given URI and text, and returns the diagnostics.
+// A document that is opened again, or that was read from disk, is replaced.
+// The files included by the document are read from disk if they are not known yet,
+// and the diagnostics of its include directives are added, see IncludeGraph.Diagnostics.
 //
 // Parameters:
 //
+//	ctx context.Context - Cancels the analysis of the document and of the files it includes.
 //	uri lsp.DocumentURI - The URI of the document.
+//	version int - The version of the text.
 //	text string - The text content of the document.
 //
 // Returns:
 //
 //	[]lsp.Diagnostic - The list of diagnostics.
-func (s *State) OpenDocument(uri lsp.DocumentURI, text string) []lsp.Diagnostic {
-	s.Documents[uri] = text
-	s.Analyzer.Build([]byte(text))
+func (s *State) OpenDocument(ctx context.Context, uri lsp.DocumentURI, version int, text string) []lsp.Diagnostic {
+	document := NewDocument(uri, version, text)
+	document.Open = true
+	var diagnostics []lsp.Diagnostic
+	document.locked(func() {
+		s.addDocument(document)
+		document.analyse(ctx, s.PositionEncoding(), s.Settings())
+		s.invalidateIncludeGraph()
+		diagnostics = document.Diagnostics
+	})
+	return s.withIncludeDiagnostics(s.loadIncludes(ctx), uri, diagnostics)
+}
+
+// addDocument adds the given document to the state, replacing the document with the same URI.
+func (s *State) addDocument(document *Document) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.documents[document.URI] = document
+	s.dropIncludeGraph()
+}
+
+// addDocumentIfAbsent adds the given document to the state, unless a document with the same URI is known.
+// It returns whether the document was added.
+func (s *State) addDocumentIfAbsent(document *Document) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, found := s.documents[document.URI]; found {
+		return false
+	}
+	s.documents[document.URI] = document
+	s.dropIncludeGraph()
+	return true
+}
 
-	visitor := kamailio_cfg.NewDiagnosticVisitor()
-	s.Analyzer.GetAST().Accept(visitor, s.Analyzer)
-	kamailio_cfg.ExtractVariables(s.Analyzer, []byte(text))
-	visitor.GetQueryDiagnostics(s.Analyzer.GetAST(), s.Analyzer)
-	if settings.GlobalSettings.EnableDiagnostics {
-		return visitor.GetDiagnostics()
+// removeDocument removes the document with the given URI from the state and closes its parse tree.
+// The caller must not hold any document lock.
+func (s *State) removeDocument(uri lsp.DocumentURI) {
+	s.mu.Lock()
+	document := s.documents[uri]
+	delete(s.documents, uri)
+	s.dropIncludeGraph()
+	s.mu.Unlock()
+	if document != nil {
+		document.locked(document.close)
 	}
-	return []lsp.Diagnostic{}
 }
 
-// UpdateDocument updates the document with the given URI and text, and returns the diagnostics.
+// UpdateDocument applies the given content changes, in order, to the document with the given URI,
+// and returns the diagnostics.
+// Range changes are applied to the parse tree as well, so that the document is parsed incrementally.
+// The document is parsed from scratch if a change replaces the whole text.
+// The diagnostics of its include directives are added, see IncludeGraph.Diagnostics.
 //
 // Parameters:
 //
+//	ctx context.Context - Cancels the analysis, the document then keeps its previous analysis.
 //	uri lsp.DocumentURI - The URI of the document.
-//	text string - The new text content of the document.
+//	version int - The version of the text after the changes.
+//	changes []lsp.TextDocumentContentChangeEvent - The content changes sent by the client.
 //
 // Returns:
 //
 //	[]lsp.Diagnostic - The list of diagnostics.
-func (s *State) UpdateDocument(uri lsp.DocumentURI, text string) []lsp.Diagnostic {
-	s.updateState(uri, text)
-	// for now we will parse the whole document
-	s.Analyzer.Build([]byte(text))
-	visitor := kamailio_cfg.NewDiagnosticVisitor()
-	s.Analyzer.GetAST().Accept(visitor, s.Analyzer)
-	kamailio_cfg.ExtractVariables(s.Analyzer, []byte(text))
-	visitor.GetQueryDiagnostics(s.Analyzer.GetAST(), s.Analyzer)
-	return visitor.GetDiagnostics()
+func (s *State) UpdateDocument(ctx context.Context, uri lsp.DocumentURI, version int, changes []lsp.TextDocumentContentChangeEvent) []lsp.Diagnostic {
+	document, encoding := s.GetDocument(uri), s.PositionEncoding()
+	known := document != nil
+	if !known {
+		// the changes are applied to an empty text, which is only right if they replace the whole text
+		log.Warn().Str("uri", string(uri)).Msg("Document is not open")
+		document = NewDocument(uri, version, "")
+	}
+	var diagnostics []lsp.Diagnostic
+	document.locked(func() {
+		if !known {
+			s.addDocument(document)
+		}
+		document.Open = true
+		includes := document.Includes
+		document.applyChanges(changes, encoding)
+		document.Version = version
+		document.analyse(ctx, encoding, s.Settings())
+		if known && sameIncludes(includes, document.Includes) {
+			s.moveIncludes(document)
+		} else {
+			s.invalidateIncludeGraph()
+		}
+		diagnostics = document.Diagnostics
+	})
+	return s.withIncludeDiagnostics(s.loadIncludes(ctx), uri, diagnostics)
 }
 
 // Hover returns the hover information for the given document URI and position.
 //
 // Parameters:
 //
-//	id int - The ID of the hover request.
+//	ctx context.Context - The context of the hover request.
+//	id lsp.ID - The ID of the hover request.
 //	uri lsp.DocumentURI - The URI of the document.
 //	position lsp.Position - The position within the document.
 //
 // Returns:
 //
 //	lsp.HoverResponse - The hover response.
-func (s *State) Hover(id int, uri lsp.DocumentURI, position lsp.Position) lsp.HoverResponse {
+//	error - The context error if the request was cancelled.
+func (s *State) Hover(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position) (lsp.HoverResponse, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return lsp.HoverResponse{}, err
+	}
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewHoverResponse(id, "Documentation not found"), nil
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
 	return lsp.NewHoverResponse(id,
-		fmt.Sprintf("%s", GetNodeDocsAtPosition(uri, position, []byte(s.Documents[uri]))))
+		fmt.Sprintf("%s", GetNodeDocsAtPosition(s.modules, document, point, views))), nil
 }
 
 // Definition returns the definition information for the given document URI and position.
+// The definitions of the routes, the variables and the defines are looked up in the whole configuration
+// the document belongs to. A route defined more than once has several definitions.
 //
 // Parameters:
 //
-//	id int - The ID of the definition request.
+//	ctx context.Context - The context of the definition request.
+//	id lsp.ID - The ID of the definition request.
 //	uri lsp.DocumentURI - The URI of the document.
 //	position lsp.Position - The position within the document.
 //
 // Returns:
 //
-//	lsp.DefinitionProviderResponse - The definition response.
+//	lsp.DefinitionProviderResponse - The definition response, with a null result if there is no definition.
+//	error - The context error if the request was cancelled.
 func (s *State) Definition(
-	id int,
+	ctx context.Context,
+	id lsp.ID,
 	uri lsp.DocumentURI,
 	position lsp.Position,
-) lsp.DefinitionProviderResponse {
-	r := GetRouteDefinitionAtPosition(uri, position, []byte(s.Documents[uri]))
-	if r == nil {
-		return lsp.NewDefintionProviderResponse(
-			id,
-			"No definition found",
-			uri,
-			lsp.Position{},
-			lsp.Position{},
-		)
-	}
-	return lsp.NewDefintionProviderResponse(
-		id,
-		r.Content,
-		uri,
-		lsp.Position{
-			Line:      int(r.StartPoint.Row),
-			Character: int(r.StartPoint.Column),
-		},
-		lsp.Position{
-			Line:      int(r.EndPoint.Row),
-			Character: int(r.EndPoint.Column),
-		},
-	)
+) (lsp.DefinitionProviderResponse, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return lsp.DefinitionProviderResponse{}, err
+	}
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewDefinitionProviderResponse(id, nil), nil
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
+	if locations, found := GetRouteDefinitionAtPosition(ctx, document, views, point, encoding); found {
+		if err := ctx.Err(); err != nil {
+			return lsp.DefinitionProviderResponse{}, err
+		}
+		return lsp.NewDefinitionProviderResponse(id, locations), nil
+	}
+	if location, found := definitionInConfiguration(document, views, point, encoding); found {
+		return lsp.NewDefinitionProviderResponse(id, []lsp.Location{location}), nil
+	}
+	return lsp.NewDefinitionProviderResponse(id, nil), nil
+}
+
+// References returns the references to the route, the variable, the define or the module
+// at the given document URI and position, looked up in the whole configuration the document belongs to.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the references request.
+//	id lsp.ID - The ID of the references request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	position lsp.Position - The position within the document.
+//	includeDeclaration bool - Whether the declarations are returned along with the uses.
+//
+// Returns:
+//
+//	lsp.ReferencesResponse - The references response, with a null result if there is nothing to refer to.
+//	error - The context error if the request was cancelled.
+func (s *State) References(
+	ctx context.Context,
+	id lsp.ID,
+	uri lsp.DocumentURI,
+	position lsp.Position,
+	includeDeclaration bool,
+) (lsp.ReferencesResponse, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return lsp.ReferencesResponse{}, err
+	}
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewReferencesResponse(id, nil), nil
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
+	locations := GetReferencesAtPosition(ctx, document, views, point, includeDeclaration, encoding)
+	if err := ctx.Err(); err != nil {
+		return lsp.ReferencesResponse{}, err
+	}
+	return lsp.NewReferencesResponse(id, locations), nil
+}
+
+// DocumentHighlights returns the occurrences of the route, the variable, the define or the module
+// at the given document URI and position, within the document.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the document highlight request.
+//	id lsp.ID - The ID of the document highlight request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	position lsp.Position - The position within the document.
+//
+// Returns:
+//
+//	lsp.DocumentHighlightResponse - The occurrences, with a null result if there is no symbol at the position.
+//	error - The context error if the request was cancelled.
+func (s *State) DocumentHighlights(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position) (lsp.DocumentHighlightResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewDocumentHighlightResponse(id, nil), nil
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
+	highlights := GetDocumentHighlights(document, point, encoding)
+	if err := ctx.Err(); err != nil {
+		return lsp.DocumentHighlightResponse{}, err
+	}
+	return lsp.NewDocumentHighlightResponse(id, highlights), nil
+}
+
+// PrepareRename checks whether the name at the given document URI and position can be renamed.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the prepare rename request.
+//	id lsp.ID - The ID of the prepare rename request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	position lsp.Position - The position within the document.
+//
+// Returns:
+//
+//	lsp.PrepareRenameResponse - The range of the name and the name.
+//	error - The reason the name cannot be renamed, sent back to the client, or the context error if the request was cancelled.
+func (s *State) PrepareRename(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position) (lsp.PrepareRenameResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.PrepareRenameResponse{}, renameError("Document not found")
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
+	rng, name, err := PrepareRename(document, point, encoding)
+	if err != nil {
+		return lsp.PrepareRenameResponse{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return lsp.PrepareRenameResponse{}, err
+	}
+	return lsp.NewPrepareRenameResponse(id, rng, name), nil
+}
+
+// Rename returns the edits renaming the name at the given document URI and position,
+// across the whole configuration the document belongs to.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the rename request.
+//	id lsp.ID - The ID of the rename request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	position lsp.Position - The position within the document.
+//	newName string - The new name.
+//
+// Returns:
+//
+//	lsp.RenameResponse - The edits of every document using the name.
+//	error - The reason the name cannot be renamed or the new name is refused, sent back to the client,
+//	or the context error if the request was cancelled.
+func (s *State) Rename(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position, newName string) (lsp.RenameResponse, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return lsp.RenameResponse{}, err
+	}
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.RenameResponse{}, renameError("Document not found")
+	}
+	defer document.Unlock()
+	point := document.LineIndex(encoding).PointAt(position)
+	changes, err := Rename(ctx, document, views, point, newName, encoding)
+	if err != nil {
+		return lsp.RenameResponse{}, err
+	}
+	return lsp.NewRenameResponse(id, changes), nil
+}
+
+// DocumentSymbols returns the outline of the document with the given URI.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the document symbol request.
+//	id lsp.ID - The ID of the document symbol request.
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	lsp.DocumentSymbolResponse - The symbols of the document, none if it is not known.
+//	error - The context error if the request was cancelled.
+func (s *State) DocumentSymbols(ctx context.Context, id lsp.ID, uri lsp.DocumentURI) (lsp.DocumentSymbolResponse, error) {
+	document, encoding := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewDocumentSymbolResponse(id, nil), nil
+	}
+	defer document.Unlock()
+	symbols := GetDocumentSymbols(document, encoding)
+	if err := ctx.Err(); err != nil {
+		return lsp.DocumentSymbolResponse{}, err
+	}
+	return lsp.NewDocumentSymbolResponse(id, symbols), nil
+}
+
+// FoldingRanges returns the folding ranges of the document with the given URI: its routing blocks,
+// its compound statements and cases, its #!ifdef regions, its comments and its module groups.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the folding range request.
+//	id lsp.ID - The ID of the folding range request.
+//	uri lsp.DocumentURI - The URI of the document.
+//
+// Returns:
+//
+//	lsp.FoldingRangeResponse - The folding ranges of the document, none if it is not known.
+//	error - The context error if the request was cancelled.
+func (s *State) FoldingRanges(ctx context.Context, id lsp.ID, uri lsp.DocumentURI) (lsp.FoldingRangeResponse, error) {
+	document, _ := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewFoldingRangeResponse(id, nil), nil
+	}
+	defer document.Unlock()
+	ranges := GetFoldingRanges(document)
+	if err := ctx.Err(); err != nil {
+		return lsp.FoldingRangeResponse{}, err
+	}
+	return lsp.NewFoldingRangeResponse(id, ranges), nil
+}
+
+// PrepareCallHierarchy returns the routing blocks at the given document URI and position:
+// the routing blocks called at the position, or else the routing block containing it.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the prepare call hierarchy request.
+//	id lsp.ID - The ID of the prepare call hierarchy request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	position lsp.Position - The position within the document.
+//
+// Returns:
+//
+//	lsp.PrepareCallHierarchyResponse - The items of the routing blocks, with a null result if there are none.
+//	error - The context error if the request was cancelled.
+func (s *State) PrepareCallHierarchy(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position) (lsp.PrepareCallHierarchyResponse, error) {
+	views, found, err := s.configurationView(ctx, uri)
+	switch {
+	case err != nil:
+		return lsp.PrepareCallHierarchyResponse{}, err
+	case !found:
+		return lsp.NewPrepareCallHierarchyResponse(id, nil), nil
+	}
+	point := views[0].index.PointAt(position)
+	return lsp.NewPrepareCallHierarchyResponse(id, newCallHierarchy(views).Prepare(uri, point)), nil
+}
+
+// IncomingCalls returns the routing blocks calling the routing block of the given item,
+// looked up in the whole configuration the document of the item belongs to.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the incoming calls request.
+//	id lsp.ID - The ID of the incoming calls request.
+//	item lsp.CallHierarchyItem - The item returned by PrepareCallHierarchy.
+//
+// Returns:
+//
+//	lsp.CallHierarchyIncomingCallsResponse - The callers, with the ranges of their calls.
+//	error - The context error if the request was cancelled.
+func (s *State) IncomingCalls(ctx context.Context, id lsp.ID, item lsp.CallHierarchyItem) (lsp.CallHierarchyIncomingCallsResponse, error) {
+	views, found, err := s.configurationView(ctx, item.URI)
+	switch {
+	case err != nil:
+		return lsp.CallHierarchyIncomingCallsResponse{}, err
+	case !found:
+		return lsp.NewCallHierarchyIncomingCallsResponse(id, nil), nil
+	}
+	incoming := newCallHierarchy(views).IncomingCalls(ctx, item)
+	if err := ctx.Err(); err != nil {
+		return lsp.CallHierarchyIncomingCallsResponse{}, err
+	}
+	return lsp.NewCallHierarchyIncomingCallsResponse(id, incoming), nil
+}
+
+// OutgoingCalls returns the routing blocks called by the routing block of the given item,
+// looked up in the whole configuration the document of the item belongs to.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the outgoing calls request.
+//	id lsp.ID - The ID of the outgoing calls request.
+//	item lsp.CallHierarchyItem - The item returned by PrepareCallHierarchy.
+//
+// Returns:
+//
+//	lsp.CallHierarchyOutgoingCallsResponse - The called routing blocks, with the ranges of the calls.
+//	error - The context error if the request was cancelled.
+func (s *State) OutgoingCalls(ctx context.Context, id lsp.ID, item lsp.CallHierarchyItem) (lsp.CallHierarchyOutgoingCallsResponse, error) {
+	views, found, err := s.configurationView(ctx, item.URI)
+	switch {
+	case err != nil:
+		return lsp.CallHierarchyOutgoingCallsResponse{}, err
+	case !found:
+		return lsp.NewCallHierarchyOutgoingCallsResponse(id, nil), nil
+	}
+	outgoing := newCallHierarchy(views).OutgoingCalls(item)
+	if err := ctx.Err(); err != nil {
+		return lsp.CallHierarchyOutgoingCallsResponse{}, err
+	}
+	return lsp.NewCallHierarchyOutgoingCallsResponse(id, outgoing), nil
 }
 
 // TextDocumentCompletion returns the completion items for the given document URI and position.
 //
 // Parameters:
 //
-//	id int - The ID of the completion request.
+//	ctx context.Context - The context of the completion request.
+//	id lsp.ID - The ID of the completion request.
 //	uri lsp.DocumentURI - The URI of the document.
 //	position lsp.Position - The position within the document.
 //
 // Returns:
 //
 //	lsp.CompletionResponse - The completion response.
-func (s *State) TextDocumentCompletion(id int, uri lsp.DocumentURI, position lsp.Position) lsp.CompletionResponse {
-	items := GetCompletionItems(uri)
-	return lsp.NewCompletionResponse(id, items)
+//	error - The context error if the request was cancelled.
+func (s *State) TextDocumentCompletion(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, position lsp.Position) (lsp.CompletionResponse, error) {
+	views, err := s.combinedView(ctx, uri)
+	if err != nil {
+		return lsp.CompletionResponse{}, err
+	}
+	document, encoding := s.lockedDocument(uri)
+	var point sitter.Point
+	if document != nil {
+		defer document.Unlock()
+		point = document.LineIndex(encoding).PointAt(position)
+	}
+	items := GetCompletionItems(ctx, s.modules, document, point, views)
+	if err := ctx.Err(); err != nil {
+		return lsp.CompletionResponse{}, err
+	}
+	return lsp.NewCompletionResponse(id, items), nil
 }
 
-func (s *State) Formatting(id int, uri lsp.DocumentURI, options lsp.FormattingOptions) lsp.DocumentFormattingResponse {
+// Formatting returns the formatting edits for the given document URI.
+// The document is analysed again once the client applies the edits and sends them back as changes.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the formatting request.
+//	id lsp.ID - The ID of the formatting request.
+//	uri lsp.DocumentURI - The URI of the document.
+//	options lsp.FormattingOptions - The formatting options sent by the client.
+//
+// Returns:
+//
+//	lsp.DocumentFormattingResponse - The formatting response.
+//	error - The context error if the request was cancelled.
+func (s *State) Formatting(ctx context.Context, id lsp.ID, uri lsp.DocumentURI, options lsp.FormattingOptions) (lsp.DocumentFormattingResponse, error) {
+	document, _ := s.lockedDocument(uri)
+	if document == nil {
+		return lsp.NewDocumentFormattingResponse(id, []lsp.TextEdit{}), nil
+	}
+	defer document.Unlock()
 	// TODO: Implement formatting
 	// visitor := kamailio_cfg.NewFormattingVisitor()
-	// s.Analyzer.GetAST().Accept(visitor, s.Analyzer)
+	// document.Analyzer.GetAST().Accept(visitor, document.Analyzer)
 	// edits := visitor.GetEdits()
 	// return lsp.NewDocumentFormattingResponse(id, edits)
 	log.Info().Msg("Formatting document")
-	new_text := kamailio_cfg.FixIndent(s.Documents[uri])
-	s.Analyzer.Build([]byte(new_text[0].NewText))
-	return lsp.NewDocumentFormattingResponse(id, kamailio_cfg.FixIndent(s.Documents[uri]))
+	new_text := kamailio_cfg.FixIndent(document.Text, document.LineIndex(s.PositionEncoding()))
+	if err := ctx.Err(); err != nil {
+		return lsp.DocumentFormattingResponse{}, err
+	}
+	return lsp.NewDocumentFormattingResponse(id, new_text), nil
 }
@@ -0,0 +1,134 @@
+package state_manager
+
+import (
+	"KamaiZen/kamailio_cfg"
+	"KamaiZen/lsp"
+	"context"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// max_workspace_symbols bounds the number of symbols returned for a query, the best matches are kept.
+const max_workspace_symbols = 200
+
+// indexedSymbolKinds maps the kinds of the indexed symbols to the kinds of the workspace symbols.
+var indexedSymbolKinds = map[kamailio_cfg.IndexedSymbolKind]lsp.SymbolKind{
+	kamailio_cfg.RouteSymbol:      lsp.FUNCTION_SYMBOL,
+	kamailio_cfg.EventRouteSymbol: lsp.EVENT_SYMBOL,
+	kamailio_cfg.DefineSymbol:     lsp.CONSTANT_SYMBOL,
+	kamailio_cfg.HTableSymbol:     lsp.STRUCT_SYMBOL,
+	kamailio_cfg.SetSymbol:        lsp.NUMBER_SYMBOL,
+}
+
+// WorkspaceSymbols returns the symbols of the known documents matching the query: the documents
+// open in the editor, the files they include and the configuration files of the workspace folders.
+// The query is matched fuzzily, the symbols are ranked by match quality, then by name.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the workspace symbol request.
+//	id lsp.ID - The ID of the workspace symbol request.
+//	query string - The query, every symbol matches an empty query.
+//
+// Returns:
+//
+//	lsp.WorkspaceSymbolResponse - The matching symbols, best matches first.
+//	error - The context error if the request was cancelled.
+func (s *State) WorkspaceSymbols(ctx context.Context, id lsp.ID, query string) (lsp.WorkspaceSymbolResponse, error) {
+	type match struct {
+		score  int
+		symbol lsp.SymbolInformation
+	}
+	encoding := s.PositionEncoding()
+	var matches []match
+	for _, document := range s.snapshot() {
+		if err := ctx.Err(); err != nil {
+			return lsp.WorkspaceSymbolResponse{}, err
+		}
+		var index *lsp.LineIndex
+		var indexed []kamailio_cfg.IndexedSymbol
+		document.locked(func() {
+			index, indexed = document.LineIndex(encoding), document.Indexed
+		})
+		for _, symbol := range indexed {
+			score, found := fuzzyScore(query, symbol.Name)
+			if !found {
+				continue
+			}
+			matches = append(matches, match{score, lsp.SymbolInformation{
+				Name:          symbol.Name,
+				Kind:          indexedSymbolKinds[symbol.Kind],
+				Location:      lsp.Location{URI: document.URI, Range: index.Range(symbol.StartPoint, symbol.EndPoint)},
+				ContainerName: symbol.Container,
+			}})
+		}
+	}
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.score != b.score {
+			return b.score - a.score
+		}
+		if c := strings.Compare(a.symbol.Name, b.symbol.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(string(a.symbol.Location.URI), string(b.symbol.Location.URI))
+	})
+	symbols := make([]lsp.SymbolInformation, 0, min(len(matches), max_workspace_symbols))
+	for _, m := range matches[:min(len(matches), max_workspace_symbols)] {
+		symbols = append(symbols, m.symbol)
+	}
+	return lsp.NewWorkspaceSymbolResponse(id, symbols), nil
+}
+
+// fuzzyScore matches the query against a name, case insensitively: the characters of the query
+// must appear in the name in order. Characters matched at the start of a word of the name,
+// e.g. the A of route[AUTH] or of MANAGE_AUTH, and consecutive characters score higher,
+// and exact, prefix and substring matches score higher still.
+//
+// Parameters:
+//
+//	query string - The query.
+//	name string - The name of the symbol.
+//
+// Returns:
+//
+//	int - The score of the match, higher is better.
+//	bool - False if the name does not match the query.
+func fuzzyScore(query string, name string) (int, bool) {
+	if query == "" {
+		return 0, true
+	}
+	q := []rune(strings.ToLower(query))
+	runes := []rune(name)
+	lower := []rune(strings.ToLower(name))
+	score, matched, last := 0, 0, -2
+	for i := 0; i < len(lower) && matched < len(q); i++ {
+		if lower[i] != q[matched] {
+			continue
+		}
+		score++
+		if i == 0 || !unicode.IsLetter(runes[i-1]) && !unicode.IsDigit(runes[i-1]) ||
+			unicode.IsUpper(runes[i]) && unicode.IsLower(runes[i-1]) {
+			score += 8
+		}
+		if last == i-1 {
+			score += 5
+		}
+		last = i
+		matched++
+	}
+	if matched < len(q) {
+		return 0, false
+	}
+	lowerName, lowerQuery := string(lower), string(q)
+	switch {
+	case lowerName == lowerQuery:
+		score += 100
+	case strings.HasPrefix(lowerName, lowerQuery):
+		score += 50
+	case strings.Contains(lowerName, lowerQuery):
+		score += 25
+	}
+	// shorter names are closer matches
+	return score - (len(lower)-len(q))/4, true
+}
@@ -0,0 +1,50 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/settings"
+	"KamaiZen/state_manager"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceSymbols(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"routes.cfg\"\n#!define WITH_AUTH\nmodparam(\"htable\", \"htable\", \"ipban=>size=8;\")\nrequest_route {\n  ds_select_dst(\"1\", \"4\");\n}\n",
+		"routes.cfg":   "route[AUTH] {\n}\nfailure_route[MANAGE_AUTH_FAILURE] {\n}\nevent_route[xhttp:request] {\n}\n",
+	})
+	state := state_manager.NewState()
+	state.ApplySettings(settings.LSPSettings{EnableDiagnostics: true})
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	text, _ := os.ReadFile(filepath.Join(dir, "kamailio.cfg"))
+	state.OpenDocument(context.Background(), main, 1, string(text))
+	for _, test := range []struct {
+		query    string
+		expected []string
+	}{
+		{"auth", []string{"WITH_AUTH", "route[AUTH]", "failure_route[MANAGE_AUTH_FAILURE]"}},
+		{"maf", []string{"failure_route[MANAGE_AUTH_FAILURE]"}},
+		{"xhttp", []string{"event_route[xhttp:request]"}},
+		{"ipban", []string{"ipban"}},
+		{"dispatcher 1", []string{"dispatcher set 1"}},
+	} {
+		response, err := state.WorkspaceSymbols(context.Background(), lsp.NewIntID(1), test.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, symbol := range response.Result {
+			names = append(names, symbol.Name)
+		}
+		if len(names) != len(test.expected) {
+			t.Fatalf("Expected: %v,\ngot: %v", test.expected, names)
+		}
+		for i, e := range test.expected {
+			if names[i] != e {
+				t.Fatalf("Expected: %v,\ngot: %v", test.expected, names)
+			}
+		}
+	}
+}
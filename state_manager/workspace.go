@@ -0,0 +1,248 @@
+package state_manager
+
+import (
+	"KamaiZen/lsp"
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// workspace holds the folders of the workspace and the globs matching their configuration files.
+// It is guarded by the state lock.
+type workspace struct {
+	folders []lsp.DocumentURI
+	globs   []string
+}
+
+// AddWorkspaceFolder adds a folder to the workspace, its configuration files are read once it is scanned.
+//
+// Parameters:
+//
+//	folder lsp.DocumentURI - The URI of the folder.
+func (s *State) AddWorkspaceFolder(folder lsp.DocumentURI) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.workspace.folders, folder) {
+		s.workspace.folders = append(s.workspace.folders, folder)
+	}
+}
+
+// RemoveWorkspaceFolder removes a folder from the workspace.
+// The documents read from disk because they were found in the folder are dropped,
+// unless they are found in another folder or belong to the configuration of an open document.
+//
+// Parameters:
+//
+//	folder lsp.DocumentURI - The URI of the folder.
+func (s *State) RemoveWorkspaceFolder(folder lsp.DocumentURI) {
+	s.mu.Lock()
+	s.workspace.folders = slices.DeleteFunc(s.workspace.folders, func(f lsp.DocumentURI) bool {
+		return f == folder
+	})
+	s.mu.Unlock()
+	graph := s.includeGraph()
+	included := make(map[lsp.DocumentURI]bool)
+	var candidates []lsp.DocumentURI
+	for _, document := range s.snapshot() {
+		document.Lock()
+		open := document.Open
+		document.Unlock()
+		if open {
+			for _, uri := range graph.Combined(document.URI) {
+				included[uri] = true
+			}
+		} else if _, found := relativePath(folder, document.URI); found {
+			candidates = append(candidates, document.URI)
+		}
+	}
+	for _, uri := range candidates {
+		if !included[uri] && !s.inWorkspace(uri) {
+			s.removeDocument(uri)
+		}
+	}
+}
+
+// WorkspaceFolders returns the folders of the workspace.
+//
+// Returns:
+//
+//	[]lsp.DocumentURI - The URIs of the folders, in the order they were added.
+func (s *State) WorkspaceFolders() []lsp.DocumentURI {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.workspace.folders)
+}
+
+// SetConfigGlobs sets the globs matching the configuration files of the workspace folders.
+// A glob without a slash matches the name of the files, other globs match their path relative
+// to the folder, and may start with '**/' to match in any subfolder.
+//
+// Parameters:
+//
+//	globs []string - The globs.
+//
+// Returns:
+//
+//	bool - True if the globs changed, the folders must then be scanned again.
+func (s *State) SetConfigGlobs(globs []string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if slices.Equal(s.workspace.globs, globs) {
+		return false
+	}
+	s.workspace.globs = slices.Clone(globs)
+	return true
+}
+
+// ScanWorkspaceFolder reads and analyses the configuration files found in a folder of the workspace,
+// so that they are known without being open in the editor. Known documents are left untouched,
+// and hidden folders are skipped.
+//
+// Parameters:
+//
+//	ctx context.Context - The context of the scan, cancelled when the folder is removed.
+//	folder lsp.DocumentURI - The URI of the folder.
+//	progress func(scanned int, total int) - Called once each configuration file is read.
+//
+// Returns:
+//
+//	int - The number of configuration files found in the folder.
+//	error - An error if the folder cannot be read, or the context error.
+func (s *State) ScanWorkspaceFolder(ctx context.Context, folder lsp.DocumentURI, progress func(scanned int, total int)) (int, error) {
+	root, err := folder.Path()
+	if err != nil {
+		return 0, err
+	}
+	s.mu.Lock()
+	globs := s.workspace.globs
+	s.mu.Unlock()
+	var files []string
+	err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if file == root {
+				return err
+			}
+			log.Debug().Err(err).Str("path", file).Msg("Cannot read workspace path")
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if file != root && strings.HasPrefix(entry.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if relative, err := filepath.Rel(root, file); err == nil && matchGlobs(globs, filepath.ToSlash(relative)) {
+			files = append(files, file)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for i, file := range files {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		uri := lsp.PathToURI(file)
+		if s.GetDocument(uri) == nil {
+			_, err := s.loadDocument(ctx, uri, func(document *Document) bool {
+				return s.addScannedDocument(folder, document)
+			})
+			if err != nil {
+				log.Debug().Err(err).Str("uri", string(uri)).Msg("Cannot read workspace file")
+			}
+		}
+		progress(i+1, len(files))
+	}
+	s.loadIncludes(ctx)
+	return len(files), nil
+}
+
+// addScannedDocument adds a document found by the scan of a workspace folder, unless a document with
+// the same URI is known or the folder was removed in the meantime, so that a scan still running once
+// its folder is removed does not bring back the files RemoveWorkspaceFolder dropped.
+// It returns whether the document was added.
+func (s *State) addScannedDocument(folder lsp.DocumentURI, document *Document) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !slices.Contains(s.workspace.folders, folder) {
+		return false
+	}
+	if _, found := s.documents[document.URI]; found {
+		return false
+	}
+	s.documents[document.URI] = document
+	s.dropIncludeGraph()
+	return true
+}
+
+// inWorkspace checks whether the file with the given URI is a configuration file of a workspace folder.
+func (s *State) inWorkspace(uri lsp.DocumentURI) bool {
+	s.mu.Lock()
+	folders, globs := s.workspace.folders, s.workspace.globs
+	s.mu.Unlock()
+	for _, folder := range folders {
+		if relative, found := relativePath(folder, uri); found && matchGlobs(globs, relative) {
+			return true
+		}
+	}
+	return false
+}
+
+// relativePath returns the slash separated path of a file relative to a folder,
+// and false if the file is not in the folder.
+func relativePath(folder lsp.DocumentURI, uri lsp.DocumentURI) (string, bool) {
+	root, err := folder.Path()
+	if err != nil {
+		return "", false
+	}
+	file, err := uri.Path()
+	if err != nil {
+		return "", false
+	}
+	relative, err := filepath.Rel(root, file)
+	if err != nil || relative == ".." || strings.HasPrefix(relative, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(relative), true
+}
+
+// matchGlobs checks whether a slash separated path, relative to a workspace folder, matches one of the globs.
+func matchGlobs(globs []string, relative string) bool {
+	for _, glob := range globs {
+		if !strings.Contains(glob, "/") {
+			glob = "**/" + glob
+		}
+		if matchGlob(glob, relative) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob checks whether a slash separated path matches a glob, which may start with '**/'.
+func matchGlob(glob string, relative string) bool {
+	rest, anywhere := strings.CutPrefix(glob, "**/")
+	if !anywhere {
+		matched, _ := path.Match(glob, relative)
+		return matched
+	}
+	for {
+		if matched, _ := path.Match(rest, relative); matched {
+			return true
+		}
+		_, after, found := strings.Cut(relative, "/")
+		if !found {
+			return false
+		}
+		relative = after
+	}
+}
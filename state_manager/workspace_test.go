@@ -0,0 +1,61 @@
+package state_manager_test
+
+import (
+	"KamaiZen/lsp"
+	"KamaiZen/state_manager"
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveWorkspaceFolder(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "request_route {\n}\n",
+	})
+	state := state_manager.NewState()
+	state.SetConfigGlobs([]string{"*.cfg"})
+	folder := lsp.PathToURI(dir)
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	state.AddWorkspaceFolder(folder)
+	if _, err := state.ScanWorkspaceFolder(context.Background(), folder, func(int, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if state.GetDocument(main) == nil {
+		t.Fatalf("Expected: %s scanned,\ngot: unknown", main)
+	}
+	state.RemoveWorkspaceFolder(folder)
+	if state.GetDocument(main) != nil {
+		t.Fatalf("Expected: %s dropped,\ngot: still known", main)
+	}
+	// a scan still running once its folder is removed does not bring its files back
+	if _, err := state.ScanWorkspaceFolder(context.Background(), folder, func(int, int) {}); err != nil {
+		t.Fatal(err)
+	}
+	if state.GetDocument(main) != nil {
+		t.Fatalf("Expected: %s left out,\ngot: added by the scan of a removed folder", main)
+	}
+}
+
+func TestCloseDocument(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"kamailio.cfg": "include_file \"routes.cfg\"\nrequest_route {\n  route(AUTH);\n}\n",
+		"routes.cfg":   "route[AUTH] {\n}\n",
+	})
+	state := state_manager.NewState()
+	main := lsp.PathToURI(filepath.Join(dir, "kamailio.cfg"))
+	routes := lsp.PathToURI(filepath.Join(dir, "routes.cfg"))
+	state.OpenDocument(context.Background(), main, 1, "include_file \"routes.cfg\"\nrequest_route {\n  route(AUTH);\n}\n")
+	state.OpenDocument(context.Background(), routes, 1, "route[AUTH] {\n}\n")
+	opened := state.GetDocument(routes)
+	state.CloseDocument(context.Background(), routes)
+	if document := state.GetDocument(routes); document == nil || document == opened {
+		t.Fatalf("Expected: %s read again from disk,\ngot: %v", routes, document)
+	}
+	if opened.Analyzer.GetAST() != nil {
+		t.Fatalf("Expected: the parse tree of the closed document closed,\ngot: still held")
+	}
+	state.CloseDocument(context.Background(), main)
+	if state.GetDocument(main) != nil {
+		t.Fatalf("Expected: %s dropped,\ngot: still known", main)
+	}
+}